@@ -0,0 +1,38 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+// default page of the audit log, it only grows
+const auditLimit = "100"
+
+// the newest changes first unless ?order=asc, 100 at a time unless ?limit=
+func AuditLog(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if !params.Has("order") {
+			params.Set("order", "desc")
+		}
+		if !params.Has("limit") {
+			params.Set("limit", auditLimit)
+		}
+		q, err := database.ParseListQuery(params, database.AuditListFields())
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		entries, total, err := database.AuditLog(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, entries, total)
+	})
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/james-mcallister/may/backup"
+	"github.com/james-mcallister/may/respond"
+)
+
+// response body for a restore
+type Restored struct {
+	Restored string      `json:"restored"`
+	Before   backup.Info `json:"before"` // backup taken just before, to undo the restore
+}
+
+func backupStatus(err error) int {
+	switch {
+	case errors.Is(err, backup.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, backup.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func Backups(dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backups, err := backup.List(dir)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, backups, int64(len(backups)))
+	})
+}
+
+func NewBackup(db *sql.DB, dir string, keep int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, err := backup.Create(r.Context(), db, dir)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if _, err := backup.Prune(dir, keep); err != nil {
+			slog.Warn("backup retention failed", "dir", dir, "error", err)
+		}
+
+		writeJSON(w, http.StatusCreated, info)
+	})
+}
+
+// downloads the backup file, ex: to keep a copy off the server
+func DownloadBackup(dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		path, err := backup.Path(dir, name)
+		if err != nil {
+			respond.Message(w, r, backupStatus(err), err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		http.ServeFile(w, r, path)
+	})
+}
+
+// replaces the database with one of the backups. Users and sessions come
+// from the backup too, so the caller may have to sign in again.
+func RestoreBackup(db *sql.DB, dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		path, err := backup.Path(dir, name)
+		if err != nil {
+			respond.Message(w, r, backupStatus(err), err.Error())
+			return
+		}
+
+		before, err := backup.Restore(r.Context(), db, dir, path)
+		if err != nil {
+			respond.Message(w, r, backupStatus(err), err.Error())
+			return
+		}
+		slog.Info("database restored", "name", name, "before", before.Name)
+
+		writeJSON(w, http.StatusOK, Restored{Restored: name, Before: before})
+	})
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+func Calendars(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cals, err := database.AllCalendars(r.Context(), db)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, cals, int64(len(cals)))
+	})
+}
+
+func Calendar(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		cal, err := database.GetCalendar(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, cal)
+	})
+}
+
+func NewCalendar(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var c database.Calendar
+		if err := readJSON(r, &c); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		if err := c.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		id, err := database.InsertCalendar(r.Context(), db, c)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, Created{Id: id})
+	})
+}
+
+func UpdateCalendar(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		var c database.Calendar
+		if err := readJSON(r, &c); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		c.Id = id
+
+		if err := c.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		rows, err := database.UpdateCalendar(r.Context(), db, c)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("calendar id=%d: no such row", id))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, c)
+	})
+}
+
+func DeleteCalendar(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		rows, err := database.DeleteRow(r.Context(), db, "Calendar", id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("calendar id=%d: no such row", id))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
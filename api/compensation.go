@@ -0,0 +1,119 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+func AllCompensation(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comps, err := database.AllCompensation(r.Context(), db)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, comps, int64(len(comps)))
+	})
+}
+
+func Compensation(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		comp, err := database.GetCompensation(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, comp)
+	})
+}
+
+func NewCompensation(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var c database.Compensation
+		if err := readJSON(r, &c); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		if err := c.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		id, err := database.InsertCompensation(r.Context(), db, c)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, Created{Id: id})
+	})
+}
+
+func UpdateCompensation(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		var c database.Compensation
+		if err := readJSON(r, &c); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		c.Id = id
+
+		if err := c.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		rows, err := database.UpdateCompensation(r.Context(), db, c)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("compensation id=%d: no such row", id))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, c)
+	})
+}
+
+func DeleteCompensation(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		rows, err := database.RecycleRow(r.Context(), db, "Compensation", id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("compensation id=%d: no such row", id))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+func Employees(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := database.ParseListQuery(r.URL.Query(), database.EmployeeListFields())
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		emps, total, err := database.AllEmployees(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, emps, total)
+	})
+}
+
+func Employee(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		emp, err := database.GetEmployee(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, emp)
+	})
+}
+
+func NewEmployee(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// same defaults as a blank employee form
+		e := database.Employee{
+			LaborCapacity: 1.0,
+			Active:        true,
+			CoverageStart: time.Now().Format("2006-01-02"),
+			CoverageEnd:   "2040-12-28",
+		}
+		if err := readJSON(r, &e); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		setDisplayName(&e)
+
+		if err := e.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		id, err := database.InsertEmployee(r.Context(), db, e)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, Created{Id: id})
+	})
+}
+
+func UpdateEmployee(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		var e database.Employee
+		if err := readJSON(r, &e); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		e.Id = id
+		setDisplayName(&e)
+
+		if err := e.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		if e.Manager.Valid {
+			if err := database.CheckReportsTo(r.Context(), db, id, e.Manager.Int64); err != nil {
+				respond.Error(w, r, err)
+				return
+			}
+		}
+
+		rows, err := database.UpdateEmployee(r.Context(), db, e)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("employee id=%d: no such row", id))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, e)
+	})
+}
+
+func DeleteEmployee(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		rows, err := database.RecycleRow(r.Context(), db, "Employee", id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("employee id=%d: no such row", id))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// display name format used by the employee form: Last, First (myid)
+func setDisplayName(e *database.Employee) {
+	if len(e.DisplayName) == 0 {
+		e.DisplayName = e.LastName + ", " + e.FirstName + " (" + e.Myid + ")"
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+func Ipts(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ipts, err := database.AllIpts(r.Context(), db)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, ipts, int64(len(ipts)))
+	})
+}
+
+func Ipt(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		ipt, err := database.GetIpt(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ipt)
+	})
+}
+
+func NewIpt(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var i database.Ipt
+		if err := readJSON(r, &i); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		if err := i.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		id, err := database.InsertIpt(r.Context(), db, i)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, Created{Id: id})
+	})
+}
+
+func UpdateIpt(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		var i database.Ipt
+		if err := readJSON(r, &i); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		i.Id = id
+
+		if err := i.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		rows, err := database.UpdateIpt(r.Context(), db, i)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("ipt id=%d: no such row", id))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, i)
+	})
+}
+
+func DeleteIpt(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		rows, err := database.RecycleRow(r.Context(), db, "Ipt", id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("ipt id=%d: no such row", id))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+const (
+	lookupLimit    = "20" // a dropdown's worth
+	lookupMaxLimit = 200
+)
+
+// dropdown options of the entity (ex: employees), filtered by
+// database.LookupFields. ?q= searches the names, ?active=true leaves out
+// inactive rows.
+func Lookup(db *sql.DB, entity string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if !params.Has("limit") {
+			params.Set("limit", lookupLimit)
+		}
+		if l, err := strconv.Atoi(params.Get("limit")); err != nil || l < 1 || l > lookupMaxLimit {
+			respond.Message(w, r, http.StatusBadRequest, fmt.Sprintf("invalid limit %q: 1 to %d", params.Get("limit"), lookupMaxLimit))
+			return
+		}
+		q, err := database.ParseListQuery(params, database.LookupFields(entity))
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		options, err := database.Lookup(r.Context(), db, entity, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, options)
+	})
+}
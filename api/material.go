@@ -0,0 +1,125 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+func Materials(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := database.ParseListQuery(r.URL.Query(), database.MaterialListFields())
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		mats, total, err := database.AllMaterials(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, mats, total)
+	})
+}
+
+func Material(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		mat, err := database.GetMaterial(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, mat)
+	})
+}
+
+func NewMaterial(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m database.Material
+		if err := readJSON(r, &m); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		if err := m.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		id, err := database.InsertMaterial(r.Context(), db, m)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, Created{Id: id})
+	})
+}
+
+func UpdateMaterial(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		var m database.Material
+		if err := readJSON(r, &m); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		m.Id = id
+
+		if err := m.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		rows, err := database.UpdateMaterial(r.Context(), db, m)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("material id=%d: no such row", id))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, m)
+	})
+}
+
+func DeleteMaterial(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		rows, err := database.RecycleRow(r.Context(), db, "Material", id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("material id=%d: no such row", id))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+func Networks(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, err := database.ParseListQuery(r.URL.Query(), database.NetworkListFields())
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		nets, total, err := database.AllNetworks(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, nets, total)
+	})
+}
+
+func Network(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		net, err := database.GetNetwork(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, net)
+	})
+}
+
+func NewNetwork(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := database.Network{
+			Status: "40 - Open All",
+		}
+		if err := readJSON(r, &n); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		if err := n.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		id, err := database.InsertNetwork(r.Context(), db, n)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, Created{Id: id})
+	})
+}
+
+func UpdateNetwork(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		var n database.Network
+		if err := readJSON(r, &n); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		n.Id = id
+
+		if err := n.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		rows, err := database.UpdateNetwork(r.Context(), db, n)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("network id=%d: no such row", id))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, n)
+	})
+}
+
+func DeleteNetwork(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		rows, err := database.RecycleRow(r.Context(), db, "Network", id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("network id=%d: no such row", id))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
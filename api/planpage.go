@@ -0,0 +1,155 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/live"
+	"github.com/james-mcallister/may/respond"
+)
+
+func PlanPages(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plans, err := database.AllPlanPages(r.Context(), db)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		if !auth.CanSeeCost(r.Context()) {
+			database.RedactAll(plans)
+		}
+
+		writeList(w, plans, int64(len(plans)))
+	})
+}
+
+func PlanPage(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		plan, err := database.GetPlanPage(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		if !auth.CanSeeCost(r.Context()) {
+			plan.Redact()
+		}
+
+		writeJSON(w, http.StatusOK, plan)
+	})
+}
+
+func NewPlanPage(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p database.PlanPage
+		if err := readJSON(r, &p); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		// only finance sets the target cost, anyone else gets the default
+		if !auth.CanSeeCost(r.Context()) {
+			p.Redact()
+		}
+
+		if err := p.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		id, err := database.InsertPlanPage(r.Context(), db, p)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, Created{Id: id})
+	})
+}
+
+// open pages are told about the new targets
+func UpdatePlanPage(db *sql.DB, hub *live.Hub) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		var p database.PlanPage
+		if err := readJSON(r, &p); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		p.Id = id
+		// a redacted (null) target cost is left unchanged
+		if !auth.CanSeeCost(r.Context()) {
+			p.Redact()
+		}
+
+		if err := p.Validate(); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		rows, err := database.UpdatePlanPage(r.Context(), db, p)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("plan page id=%d: no such row", id))
+			return
+		}
+
+		// the stored target cost, a redacted update kept it
+		saved, err := database.GetPlanPage(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		var by string
+		if u, ok := auth.UserFrom(r.Context()); ok {
+			by = u.Username
+		}
+		hub.Publish(id, live.Event{
+			Name:   live.TargetSet,
+			Data:   live.Target{PageId: id, TargetCost: saved.TargetCost, TargetHours: saved.TargetHours, Actor: by},
+			Origin: r.Header.Get(live.ClientHeader),
+		})
+
+		writeJSON(w, http.StatusOK, p)
+	})
+}
+
+func DeletePlanPage(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		rows, err := database.RecycleRow(r.Context(), db, "PlanPage", id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("plan page id=%d: no such row", id))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
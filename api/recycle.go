@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+// response body for a restore
+type RecycleRestored struct {
+	Restored database.BinEntry `json:"restored"`
+	Skipped  int               `json:"skipped"` // dependents that no longer fit
+}
+
+// only the finance role deletes compensation, the other records are the
+// planner's
+func CanRestore(ctx context.Context, e database.BinEntry) bool {
+	if e.Table == "Compensation" {
+		return auth.HasRole(ctx, auth.Finance)
+	}
+	return auth.HasRole(ctx, auth.Planner)
+}
+
+// the most recently deleted first unless ?order=asc
+func RecycleBin(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if !params.Has("order") {
+			params.Set("order", "desc")
+		}
+		q, err := database.ParseListQuery(params, database.RecycleListFields())
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		entries, total, err := database.RecycledEntries(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, entries, total)
+	})
+}
+
+func RestoreRecycled(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		entry, err := database.GetRecycled(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if !CanRestore(r.Context(), entry) {
+			respond.Message(w, r, http.StatusForbidden, "forbidden: restoring "+entry.Table+" requires the role that deletes it")
+			return
+		}
+
+		entry, skipped, err := database.RestoreRecycled(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, RecycleRestored{Restored: entry, Skipped: skipped})
+	})
+}
+
+func PurgeRecycled(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		if err := database.PurgeRecycled(r.Context(), db, id); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// empties the recycle bin
+func PurgeAllRecycled(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := database.PurgeAllRecycled(r.Context(), db); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+const (
+	searchLimit    = 10 // a type-ahead list
+	searchMaxLimit = 100
+)
+
+// ranked matches of ?q= across the entities, every word is a prefix so it
+// works while typing. ?type= (repeatable) narrows the entity types.
+func Search(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		limit := searchLimit
+		if params.Has("limit") {
+			l, err := strconv.Atoi(params.Get("limit"))
+			if err != nil || l < 1 || l > searchMaxLimit {
+				respond.Message(w, r, http.StatusBadRequest, fmt.Sprintf("invalid limit %q: 1 to %d", params.Get("limit"), searchMaxLimit))
+				return
+			}
+			limit = l
+		}
+
+		types := params["type"]
+		for _, t := range types {
+			if !slices.Contains(database.SearchTypes(), t) {
+				respond.Message(w, r, http.StatusBadRequest, fmt.Sprintf("invalid type %q: expecting one of %v", t, database.SearchTypes()))
+				return
+			}
+		}
+
+		results, err := database.Search(r.Context(), db, params.Get("q"), types, limit)
+		if errors.Is(err, database.ErrSearchUnavailable) {
+			respond.Message(w, r, http.StatusNotImplemented, err.Error())
+			return
+		}
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, results)
+	})
+}
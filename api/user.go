@@ -0,0 +1,198 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+// request body for creating or updating a user. The password is only
+// changed when it is set.
+type UserRequest struct {
+	database.User
+	Password string `json:"password,omitempty"`
+}
+
+type PasswordChange struct {
+	Current string `json:"current"`
+	New     string `json:"new"`
+}
+
+func Users(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		users, err := database.AllUsers(r.Context(), db)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeList(w, users, int64(len(users)))
+	})
+}
+
+func User(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		u, err := database.GetUser(r.Context(), db, id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, u)
+	})
+}
+
+func NewUser(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := UserRequest{User: database.NewUser()}
+		if err := readJSON(r, &u); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		// only single sign-on creates sso users
+		u.SSO = false
+		if len(u.Username) == 0 || !auth.ValidRole(u.Role) {
+			respond.Message(w, r, http.StatusBadRequest, "invalid user: username and role (viewer, planner, finance, admin) are required")
+			return
+		}
+
+		hash, err := auth.HashPassword(u.Password)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		u.PasswordHash = hash
+
+		id, err := database.InsertUser(r.Context(), db, u.User)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, Created{Id: id})
+	})
+}
+
+func UpdateUser(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		var u UserRequest
+		if err := readJSON(r, &u); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		u.Id = id
+		if len(u.Username) == 0 || !auth.ValidRole(u.Role) {
+			respond.Message(w, r, http.StatusBadRequest, "invalid user: username and role (viewer, planner, finance, admin) are required")
+			return
+		}
+
+		// an admin locking themselves out is almost certainly a mistake
+		if me, ok := auth.UserFrom(r.Context()); ok && me.Id == id && (!u.Active || u.Role != string(auth.Admin)) {
+			respond.Message(w, r, http.StatusBadRequest, "can not deactivate or change the role of your own account")
+			return
+		}
+
+		var hash string
+		if len(u.Password) > 0 {
+			if hash, err = auth.HashPassword(u.Password); err != nil {
+				respond.BadRequest(w, r, err)
+				return
+			}
+		}
+
+		rows, err := database.UpdateUser(r.Context(), db, u.User)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("user id=%d: no such row", id))
+			return
+		}
+
+		if len(hash) > 0 {
+			if _, err := database.SetPassword(r.Context(), db, id, hash); err != nil {
+				respond.Error(w, r, err)
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, u.User)
+	})
+}
+
+func DeleteUser(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		if me, ok := auth.UserFrom(r.Context()); ok && me.Id == id {
+			respond.Message(w, r, http.StatusBadRequest, "can not delete your own account")
+			return
+		}
+
+		rows, err := database.DeleteRow(r.Context(), db, "User", id)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if rows == 0 {
+			respond.Message(w, r, http.StatusNotFound, fmt.Sprintf("user id=%d: no such row", id))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// lets any signed in user change their own password
+func ChangePassword(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		me, ok := auth.UserFrom(r.Context())
+		if !ok {
+			respond.Message(w, r, http.StatusUnauthorized, "authentication required")
+			return
+		}
+
+		var p PasswordChange
+		if err := readJSON(r, &p); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		if !auth.CheckPassword(me.PasswordHash, p.Current) {
+			respond.Message(w, r, http.StatusBadRequest, "current password is incorrect")
+			return
+		}
+
+		hash, err := auth.HashPassword(p.New)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		if _, err := database.SetPassword(r.Context(), db, me.Id, hash); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,52 @@
+// Package audit attributes database changes to the signed in user. The
+// changes themselves are recorded by triggers (see database.AuditLog); this
+// package puts who and where they came from in the context the writes are
+// made with.
+package audit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/james-mcallister/may/database"
+)
+
+// where a change was made
+const (
+	UI     = "ui"     // the web pages
+	API    = "api"    // the /api/v1 rest api
+	Import = "import" // may import
+	CLI    = "cli"    // other may commands
+	SSO    = "sso"    // accounts created or updated by single sign-on
+)
+
+const maxBody = 32 << 20
+
+type sourceKey struct{}
+
+// middleware for the state-changing routes of a mux. Their writes are
+// attributed to the user SetActor adds once it is known.
+func Middleware(source string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sourceKey{}, source)))
+		})
+	}
+}
+
+// ctx with username as the actor of the changes made with it. Only requests
+// through Middleware are attributed.
+func SetActor(ctx context.Context, username string) context.Context {
+	source, ok := ctx.Value(sourceKey{}).(string)
+	if !ok {
+		return ctx
+	}
+	return database.WithActor(ctx, username, source)
+}
+
+// ctx with its changes attributed to actor, for writes outside of a request
+// (ex: the cli)
+func As(ctx context.Context, actor, source string) context.Context {
+	return database.WithActor(ctx, actor, source)
+}
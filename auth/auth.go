@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/james-mcallister/may/audit"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/logging"
+	"github.com/james-mcallister/may/respond"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Role string
+
+const (
+	Viewer  Role = "viewer"  // read only
+	Planner Role = "planner" // edits entities and labor plans
+	Finance Role = "finance" // edits compensation and calendars
+	Admin   Role = "admin"   // everything, including user accounts
+)
+
+var AllRoles = []Role{Viewer, Planner, Finance, Admin}
+
+func ValidRole(r string) bool {
+	return slices.Contains(AllRoles, Role(r))
+}
+
+const (
+	CookieName        = "may_session"
+	SessionTTL        = 12 * time.Hour
+	MinPasswordLength = 8
+)
+
+var ErrInvalidLogin = errors.New("invalid username or password")
+
+// a request without a session, a 401
+var ErrAuthRequired error = &database.Error{Kind: database.ErrUnauthorized, Err: errors.New("authentication required")}
+
+// compared against when the username does not exist so a failed login takes
+// the same time either way
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("may-dummy-password"), bcrypt.DefaultCost)
+
+type Auth struct {
+	db  *sql.DB
+	SSO bool // show the single sign-on button on the login page
+}
+
+func New(db *sql.DB) Auth {
+	return Auth{db: db}
+}
+
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("password hash error: %v", err)
+	}
+	return string(h), nil
+}
+
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// session tokens are only stored hashed so a copy of the database can't be
+// used to sign in
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("random token error: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// checks the credentials and starts a session, returning the cookie to set
+func (a Auth) Login(ctx context.Context, username, password string) (*http.Cookie, database.User, error) {
+	u, err := database.GetUserByName(ctx, a.db, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+			return nil, u, ErrInvalidLogin
+		}
+		return nil, u, err
+	}
+	if !CheckPassword(u.PasswordHash, password) || !u.Active {
+		return nil, u, ErrInvalidLogin
+	}
+
+	c, err := a.startSession(ctx, u)
+	return c, u, err
+}
+
+func (a Auth) startSession(ctx context.Context, u database.User) (*http.Cookie, error) {
+	if _, err := database.DeleteExpiredSessions(ctx, a.db); err != nil {
+		return nil, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(SessionTTL)
+	if err := database.InsertSession(ctx, a.db, hashToken(token), u.Id, expires); err != nil {
+		return nil, err
+	}
+
+	c := &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	return c, nil
+}
+
+// ends the session for the request cookie (if any) and returns a cookie
+// that clears it in the browser
+func (a Auth) Logout(r *http.Request) (*http.Cookie, error) {
+	if c, err := r.Cookie(CookieName); err == nil {
+		if err := database.DeleteSession(r.Context(), a.db, hashToken(c.Value)); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Cookie{Name: CookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true}, nil
+}
+
+type ctxKey struct{}
+
+// the signed in user, set by Require
+func UserFrom(ctx context.Context) (database.User, bool) {
+	u, ok := ctx.Value(ctxKey{}).(database.User)
+	return u, ok
+}
+
+func WithUser(ctx context.Context, u database.User) context.Context {
+	return context.WithValue(ctx, ctxKey{}, u)
+}
+
+// true when the signed in user has one of the roles (or is an admin)
+func HasRole(ctx context.Context, roles ...Role) bool {
+	u, ok := UserFrom(ctx)
+	return ok && (Role(u.Role) == Admin || slices.Contains(roles, Role(u.Role)))
+}
+
+// rates, costs and target costs are only shown to the finance role
+func CanSeeCost(ctx context.Context) bool {
+	return HasRole(ctx, Finance)
+}
+
+// middleware allowing signed in users with one of the roles through. Admins
+// are always allowed. Browser page loads without a session are redirected
+// to the login page, everything else gets a 401.
+func (a Auth) Require(roles ...Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, err := a.sessionUser(r)
+			if err != nil {
+				if !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, http.ErrNoCookie) {
+					respond.Error(w, r, err)
+					return
+				}
+				if isPageLoad(r) {
+					// RequestURI is the path before any StripPrefix
+					http.Redirect(w, r, "/login?next="+url.QueryEscape(r.RequestURI), http.StatusSeeOther)
+					return
+				}
+				respond.Error(w, r, ErrAuthRequired)
+				return
+			}
+
+			logging.SetUser(r.Context(), u.Username)
+			ctx := WithUser(r.Context(), u)
+			if !HasRole(ctx, roles...) {
+				path, _, _ := strings.Cut(r.RequestURI, "?")
+				respond.Error(w, r, &database.Error{
+					Kind: database.ErrForbidden,
+					Err:  fmt.Errorf("forbidden: role %s can not %s %s", u.Role, r.Method, path),
+				})
+				return
+			}
+			ctx = audit.SetActor(ctx, u.Username)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Require for endpoints scraped by other programs, ex: /metrics. A request
+// with the token as its bearer token is let through without a session. An
+// empty token only allows sessions.
+func (a Auth) RequireToken(token string, roles ...Role) func(http.Handler) http.Handler {
+	require := a.Require(roles...)
+	return func(next http.Handler) http.Handler {
+		session := require(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sent, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if ok && len(token) > 0 && subtle.ConstantTimeCompare([]byte(sent), []byte(token)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			session.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (a Auth) sessionUser(r *http.Request) (database.User, error) {
+	c, err := r.Cookie(CookieName)
+	if err != nil {
+		return database.User{}, err
+	}
+	return database.GetSessionUser(r.Context(), a.db, hashToken(c.Value))
+}
+
+// top level browser navigation, as opposed to the frontend's ajax requests
+func isPageLoad(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		r.Header.Get("X-Requested-With") == "" &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// creates an admin account when there are no users so a new install can be
+// signed in to. The password is taken from password or generated.
+func Bootstrap(ctx context.Context, db *sql.DB, password string) (string, error) {
+	n, err := database.CountUsers(ctx, db)
+	if err != nil || n > 0 {
+		return "", err
+	}
+
+	if len(password) == 0 {
+		password, err = newToken()
+		if err != nil {
+			return "", err
+		}
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	u := database.NewUser()
+	u.Username = "admin"
+	u.Role = string(Admin)
+	u.PasswordHash = hash
+	if _, err := database.InsertUser(ctx, db, u); err != nil {
+		return "", err
+	}
+	return password, nil
+}
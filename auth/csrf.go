@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+// double submit csrf protection: every response carries a random token in
+// the may_csrf cookie and state-changing requests must echo it back in the
+// X-CSRF-Token header (ajax) or the csrf_token form field (html forms). A
+// cross-site page can make the browser send the cookie but can't read it.
+const (
+	CSRFCookieName = "may_csrf"
+	CSRFHeader     = "X-CSRF-Token"
+	CSRFField      = "csrf_token"
+)
+
+var ErrCSRF error = &database.Error{Kind: database.ErrForbidden, Err: errors.New("csrf token missing or invalid, reload the page and try again")}
+
+type csrfKey struct{}
+
+// the csrf token for templates rendering html forms
+func CSRFToken(ctx context.Context) string {
+	t, _ := ctx.Value(csrfKey{}).(string)
+	return t
+}
+
+func safeMethod(m string) bool {
+	return m == http.MethodGet || m == http.MethodHead || m == http.MethodOptions
+}
+
+func CSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if c, err := r.Cookie(CSRFCookieName); err == nil && len(c.Value) > 0 {
+			token = c.Value
+		}
+
+		if !safeMethod(r.Method) {
+			sent := r.Header.Get(CSRFHeader)
+			if len(sent) == 0 {
+				sent = r.PostFormValue(CSRFField)
+			}
+			if len(token) == 0 || subtle.ConstantTimeCompare([]byte(token), []byte(sent)) != 1 {
+				respond.Error(w, r, ErrCSRF)
+				return
+			}
+		}
+
+		if len(token) == 0 {
+			t, err := newToken()
+			if err != nil {
+				respond.Error(w, r, err)
+				return
+			}
+			token = t
+			// readable by the frontend so it can copy it into the header
+			http.SetCookie(w, &http.Cookie{
+				Name:     CSRFCookieName,
+				Value:    token,
+				Path:     "/",
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfKey{}, token)))
+	})
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/james-mcallister/may/logging"
+	"github.com/james-mcallister/may/respond"
+)
+
+type LoginData struct {
+	Username string
+	Next     string
+	Error    string
+	SSO      bool
+	CSRF     string
+}
+
+// only local paths are followed after login
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func LoginPage(t *template.Template, a Auth) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := LoginData{
+			Next: safeNext(r.URL.Query().Get("next")),
+			SSO:  a.SSO,
+			CSRF: CSRFToken(r.Context()),
+		}
+		if err := t.ExecuteTemplate(w, "login.html", data); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+	})
+}
+
+func Login(t *template.Template, a Auth) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		data := LoginData{
+			Username: r.FormValue("username"),
+			Next:     safeNext(r.FormValue("next")),
+			SSO:      a.SSO,
+			CSRF:     CSRFToken(r.Context()),
+		}
+
+		c, u, err := a.Login(r.Context(), data.Username, r.FormValue("password"))
+		if err != nil {
+			if !errors.Is(err, ErrInvalidLogin) {
+				respond.Error(w, r, err)
+				return
+			}
+			data.Error = err.Error()
+			logging.SetError(r.Context(), data.Error)
+			w.WriteHeader(http.StatusUnauthorized)
+			if err := t.ExecuteTemplate(w, "login.html", data); err != nil {
+				respond.Error(w, r, err)
+			}
+			return
+		}
+		c.Secure = r.TLS != nil
+		logging.SetUser(r.Context(), u.Username)
+
+		http.SetCookie(w, c)
+		http.Redirect(w, r, data.Next, http.StatusSeeOther)
+	})
+}
+
+func Logout(a Auth) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := a.Logout(r)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		http.SetCookie(w, c)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// the signed in user
+func Me() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := UserFrom(r.Context())
+		if !ok {
+			respond.Error(w, r, ErrAuthRequired)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		encoder.Encode(u)
+	})
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// minimal JSON Web Token and JSON Web Key support for verifying OIDC id
+// tokens. Only the RS256 and ES256 algorithms are accepted.
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+var errUnknownKey = errors.New("id token signed with an unknown key")
+
+func b64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64Int(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: invalid modulus: %v", k.Kid, err)
+		}
+		e, err := b64Int(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("jwk %s: invalid exponent", k.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("jwk %s: unsupported curve %s", k.Kid, k.Crv)
+		}
+		x, err := b64Int(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: invalid x: %v", k.Kid, err)
+		}
+		y, err := b64Int(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: invalid y: %v", k.Kid, err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("jwk %s: unsupported key type %s", k.Kid, k.Kty)
+}
+
+// checks the signature of a compact JWT with the key from keys matching its
+// kid and decodes the claims into v
+func verifyJWT(token string, keys map[string]crypto.PublicKey, v any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed id token")
+	}
+
+	hb, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("id token header: %v", err)
+	}
+	var h jwtHeader
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return fmt.Errorf("id token header: %v", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("id token signature: %v", err)
+	}
+
+	key, ok := keys[h.Kid]
+	if !ok {
+		return errUnknownKey
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch h.Alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("id token: key %s is not an RSA key", h.Kid)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("id token: invalid signature")
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return fmt.Errorf("id token: key %s is not a P-256 key", h.Kid)
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return fmt.Errorf("id token: invalid signature")
+		}
+	default:
+		return fmt.Errorf("id token: unsupported algorithm %s", h.Alg)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("id token payload: %v", err)
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// the aud claim is either a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("invalid aud claim: %v", err)
+	}
+	*a = list
+	return nil
+}
@@ -0,0 +1,526 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/james-mcallister/may/audit"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/logging"
+	"github.com/james-mcallister/may/respond"
+)
+
+// OpenID Connect single sign-on using the authorization code flow with PKCE.
+// The id token identifies the employee (by myid) and its group claim picks
+// the role, so roles are managed in the identity provider and refreshed on
+// every sign in.
+
+type OIDCConfig struct {
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string // defaults to /login/oidc/callback on the request host
+	Scopes       []string
+	MyidClaim    string          // claim matched against Employee.myid
+	Domains      []string        // stripped from a user@domain myid claim
+	GroupsClaim  string          // claim with the user's groups
+	RoleGroups   map[string]Role // group -> role
+	DefaultRole  Role            // role when no group matches, empty denies sign in
+}
+
+// the highest role from the groups wins
+var rolePriority = []Role{Admin, Finance, Planner, Viewer}
+
+// the defaults for a provider that only needs an issuer and client id. SSO
+// is disabled unless an issuer is set.
+func DefaultOIDCConfig() OIDCConfig {
+	return OIDCConfig{
+		Scopes:      []string{"openid", "profile", "email"},
+		MyidClaim:   "preferred_username",
+		GroupsClaim: "groups",
+		RoleGroups:  make(map[string]Role),
+	}
+}
+
+func (c OIDCConfig) Enabled() bool {
+	return len(c.Issuer) > 0
+}
+
+// parses group=role pairs separated by commas, replacing the current ones:
+//
+//	may-admins=admin,may-finance=finance,may-planners=planner
+func (c *OIDCConfig) ParseRoleGroups(v string) error {
+	c.RoleGroups = make(map[string]Role)
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		if !ok || !ValidRole(role) {
+			return fmt.Errorf("invalid oidc role group %q: expecting group=viewer|planner|finance|admin", pair)
+		}
+		c.RoleGroups[strings.TrimSpace(group)] = Role(role)
+	}
+	return nil
+}
+
+func (c OIDCConfig) Validate() error {
+	if len(c.ClientId) == 0 {
+		return errors.New("oidc: client id is required")
+	}
+	if len(c.DefaultRole) > 0 && !ValidRole(string(c.DefaultRole)) {
+		return fmt.Errorf("oidc: invalid default role %s", c.DefaultRole)
+	}
+	if _, err := url.Parse(c.Issuer); err != nil {
+		return fmt.Errorf("oidc: invalid issuer: %v", err)
+	}
+	return nil
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// state kept between the redirect to the provider and the callback
+type pendingLogin struct {
+	nonce    string
+	verifier string
+	redirect string
+	next     string
+	expires  time.Time
+}
+
+type OIDC struct {
+	auth   Auth
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	meta    *discovery
+	keys    map[string]crypto.PublicKey
+	pending map[string]pendingLogin
+}
+
+const (
+	oidcStateCookie = "may_oidc_state"
+	oidcLoginTTL    = 10 * time.Minute
+)
+
+func NewOIDC(a Auth, cfg OIDCConfig) *OIDC {
+	return &OIDC{
+		auth:    a,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 15 * time.Second},
+		pending: make(map[string]pendingLogin),
+	}
+}
+
+func (o *OIDC) getJSON(u string, v any) error {
+	res, err := o.client.Get(u)
+	if err != nil {
+		return fmt.Errorf("oidc: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s: %s", u, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+// provider metadata, fetched on first use so the server starts even when
+// the issuer is unreachable
+func (o *OIDC) discover() (discovery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.meta != nil {
+		return *o.meta, nil
+	}
+
+	var d discovery
+	u := strings.TrimSuffix(o.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := o.getJSON(u, &d); err != nil {
+		return d, err
+	}
+	if d.Issuer != o.cfg.Issuer {
+		return d, fmt.Errorf("oidc: discovery issuer %s does not match %s", d.Issuer, o.cfg.Issuer)
+	}
+	o.meta = &d
+	return d, nil
+}
+
+// signing keys by kid, refetched when a token uses a key that isn't cached
+// (the provider rotated its keys)
+func (o *OIDC) signingKeys(refresh bool) (map[string]crypto.PublicKey, error) {
+	d, err := o.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.keys != nil && !refresh {
+		return o.keys, nil
+	}
+
+	var set jwkSet
+	if err := o.getJSON(d.JwksUri, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	o.keys = keys
+	return keys, nil
+}
+
+func (o *OIDC) redirectUrl(r *http.Request) string {
+	if len(o.cfg.RedirectUrl) > 0 {
+		return o.cfg.RedirectUrl
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/login/oidc/callback"
+}
+
+// redirects the browser to the provider's authorization endpoint
+func (o *OIDC) Start() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := o.discover()
+		if err != nil {
+			respond.Message(w, r, http.StatusBadGateway, err.Error())
+			return
+		}
+
+		state, err := newToken()
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		nonce, err := newToken()
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		verifier, err := newToken()
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		challenge := sha256.Sum256([]byte(verifier))
+
+		p := pendingLogin{
+			nonce:    nonce,
+			verifier: verifier,
+			redirect: o.redirectUrl(r),
+			next:     safeNext(r.URL.Query().Get("next")),
+			expires:  time.Now().Add(oidcLoginTTL),
+		}
+
+		o.mu.Lock()
+		for k, v := range o.pending {
+			if time.Now().After(v.expires) {
+				delete(o.pending, k)
+			}
+		}
+		o.pending[state] = p
+		o.mu.Unlock()
+
+		// binds the callback to this browser
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    state,
+			Path:     "/login/oidc",
+			MaxAge:   int(oidcLoginTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		q := url.Values{}
+		q.Set("response_type", "code")
+		q.Set("client_id", o.cfg.ClientId)
+		q.Set("redirect_uri", p.redirect)
+		q.Set("scope", strings.Join(o.cfg.Scopes, " "))
+		q.Set("state", state)
+		q.Set("nonce", nonce)
+		q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+		q.Set("code_challenge_method", "S256")
+
+		sep := "?"
+		if strings.Contains(d.AuthorizationEndpoint, "?") {
+			sep = "&"
+		}
+		http.Redirect(w, r, d.AuthorizationEndpoint+sep+q.Encode(), http.StatusFound)
+	})
+}
+
+// finishes the sign in: exchanges the code, verifies the id token, maps it
+// to an employee and role and starts a session
+func (o *OIDC) Callback(t *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fail := func(status int, msg string) {
+			logging.SetError(r.Context(), msg)
+			w.WriteHeader(status)
+			data := LoginData{Next: "/", Error: msg, SSO: true, CSRF: CSRFToken(r.Context())}
+			if err := t.ExecuteTemplate(w, "login.html", data); err != nil {
+				respond.Error(w, r, err)
+			}
+		}
+
+		params := r.URL.Query()
+		if e := params.Get("error"); len(e) > 0 {
+			fail(http.StatusUnauthorized, "single sign-on failed: "+e+" "+params.Get("error_description"))
+			return
+		}
+
+		state := params.Get("state")
+		c, err := r.Cookie(oidcStateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(c.Value), []byte(state)) != 1 {
+			fail(http.StatusBadRequest, "single sign-on failed: state mismatch, please try again")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/login/oidc", MaxAge: -1})
+
+		o.mu.Lock()
+		p, ok := o.pending[state]
+		delete(o.pending, state)
+		o.mu.Unlock()
+		if !ok || time.Now().After(p.expires) {
+			fail(http.StatusBadRequest, "single sign-on failed: login expired, please try again")
+			return
+		}
+
+		claims, err := o.exchange(params.Get("code"), p)
+		if err != nil {
+			fail(http.StatusUnauthorized, "single sign-on failed: "+err.Error())
+			return
+		}
+
+		u, err := o.mapUser(r.Context(), claims)
+		if err != nil {
+			fail(http.StatusForbidden, "single sign-on failed: "+err.Error())
+			return
+		}
+
+		cookie, err := o.auth.startSession(r.Context(), u)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		cookie.Secure = r.TLS != nil
+		logging.SetUser(r.Context(), u.Username)
+
+		http.SetCookie(w, cookie)
+		http.Redirect(w, r, p.next, http.StatusSeeOther)
+	})
+}
+
+type tokenResponse struct {
+	IdToken   string `json:"id_token"`
+	Error     string `json:"error"`
+	ErrorDesc string `json:"error_description"`
+}
+
+type idClaims struct {
+	Issuer   string   `json:"iss"`
+	Audience audience `json:"aud"`
+	Expires  int64    `json:"exp"`
+	Nonce    string   `json:"nonce"`
+}
+
+func (o *OIDC) exchange(code string, p pendingLogin) (map[string]any, error) {
+	d, err := o.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirect)
+	form.Set("client_id", o.cfg.ClientId)
+	form.Set("code_verifier", p.verifier)
+	if len(o.cfg.ClientSecret) > 0 {
+		form.Set("client_secret", o.cfg.ClientSecret)
+	}
+
+	res, err := o.client.PostForm(d.TokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %v", err)
+	}
+	defer res.Body.Close()
+
+	var tr tokenResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("token response: %v", err)
+	}
+	if res.StatusCode != http.StatusOK || len(tr.Error) > 0 {
+		return nil, fmt.Errorf("token response %s: %s %s", res.Status, tr.Error, tr.ErrorDesc)
+	}
+
+	keys, err := o.signingKeys(false)
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]any
+	err = verifyJWT(tr.IdToken, keys, &claims)
+	if errors.Is(err, errUnknownKey) {
+		if keys, err = o.signingKeys(true); err != nil {
+			return nil, err
+		}
+		err = verifyJWT(tr.IdToken, keys, &claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// decode the registered claims again with their json types
+	b, _ := json.Marshal(claims)
+	var std idClaims
+	if err := json.Unmarshal(b, &std); err != nil {
+		return nil, fmt.Errorf("id token claims: %v", err)
+	}
+	switch {
+	case std.Issuer != d.Issuer:
+		return nil, fmt.Errorf("id token issuer %s is not %s", std.Issuer, d.Issuer)
+	case !slices.Contains(std.Audience, o.cfg.ClientId):
+		return nil, errors.New("id token was not issued for this client")
+	case time.Now().Unix() > std.Expires+60: // allow a minute of clock skew
+		return nil, errors.New("id token expired")
+	case subtle.ConstantTimeCompare([]byte(std.Nonce), []byte(p.nonce)) != 1:
+		return nil, errors.New("id token nonce mismatch")
+	}
+	return claims, nil
+}
+
+// group claims are usually an array but some providers send a single string
+func claimStrings(v any) []string {
+	switch g := v.(type) {
+	case string:
+		return []string{g}
+	case []any:
+		out := make([]string, 0, len(g))
+		for _, s := range g {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func (o *OIDC) roleFor(groups []string) Role {
+	for _, role := range rolePriority {
+		for _, g := range groups {
+			if o.cfg.RoleGroups[g] == role {
+				return role
+			}
+		}
+	}
+	return o.cfg.DefaultRole
+}
+
+// finds the employee for the myid claim and creates or updates the user
+// linked to it with the role from the group claim
+func (o *OIDC) mapUser(ctx context.Context, claims map[string]any) (database.User, error) {
+	var u database.User
+
+	myid, _ := claims[o.cfg.MyidClaim].(string)
+	if len(myid) == 0 {
+		return u, fmt.Errorf("id token has no %s claim", o.cfg.MyidClaim)
+	}
+	// some providers send user@domain for the username claim, only our own
+	// domains are dropped so another domain's user can't pass as ours
+	if name, domain, ok := strings.Cut(myid, "@"); ok && slices.ContainsFunc(o.cfg.Domains, func(d string) bool {
+		return strings.EqualFold(d, domain)
+	}) {
+		myid = name
+	}
+
+	emp, err := database.GetEmployeeByMyid(ctx, o.auth.db, myid)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return u, fmt.Errorf("no employee with myid %s", myid)
+		}
+		return u, err
+	}
+	if !emp.Active {
+		return u, fmt.Errorf("employee %s is not active", emp.Myid)
+	}
+
+	role := o.roleFor(claimStrings(claims[o.cfg.GroupsClaim]))
+	if len(role) == 0 {
+		return u, fmt.Errorf("%s is not in a group with access to may", emp.Myid)
+	}
+
+	// linked by employee, never by name, so a local account (ex: admin)
+	// isn't taken over by an employee with the same myid
+	u, err = database.GetSSOUser(ctx, o.auth.db, emp.Id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return o.newUser(ctx, emp, role)
+	case err != nil:
+		return u, err
+	case !u.Active:
+		return u, fmt.Errorf("user %s is disabled", u.Username)
+	case len(u.PasswordHash) > 0:
+		return u, fmt.Errorf("user %s has a password, sign in with it", u.Username)
+	}
+
+	u.Role = string(role)
+	_, err = database.UpdateUser(audit.As(ctx, u.Username, audit.SSO), o.auth.db, u)
+	return u, err
+}
+
+// the sso user for a first sign in, the employee's myid can't already be
+// someone else's user name
+func (o *OIDC) newUser(ctx context.Context, emp database.Employee, role Role) (database.User, error) {
+	u, err := database.GetUserByName(ctx, o.auth.db, emp.Myid)
+	switch {
+	case err == nil:
+		return u, fmt.Errorf("user name %s belongs to another account", emp.Myid)
+	case !errors.Is(err, sql.ErrNoRows):
+		return u, err
+	}
+
+	u = database.NewUser()
+	u.Username = emp.Myid
+	u.Role = string(role)
+	u.EmpId = database.NewNullInt64(emp.Id)
+	u.SSO = true
+	u.Id, err = database.InsertUser(audit.As(ctx, u.Username, audit.SSO), o.auth.db, u)
+	return u, err
+}
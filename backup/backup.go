@@ -0,0 +1,243 @@
+// Package backup keeps timestamped, checksummed copies of the database in a
+// directory, restores them and takes them on a schedule.
+package backup
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/james-mcallister/may/database"
+)
+
+const (
+	prefix     = "may-"
+	timeLayout = "20060102-150405"
+	// checksum next to each backup, in the sha256sum format
+	checksumExt = ".sha256"
+)
+
+type Info struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+	Created time.Time `json:"created"`
+}
+
+var (
+	ErrNotFound = errors.New("backup not found")
+	ErrInvalid  = errors.New("invalid backup")
+)
+
+// backups are may-<time>.db files, other names can't escape the directory
+func validName(name string) bool {
+	return strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".db") &&
+		filepath.Base(name) == name
+}
+
+// path of the backup name in dir
+func Path(dir, name string) (string, error) {
+	if !validName(name) {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// takes a backup of the running database into dir
+func Create(ctx context.Context, db *sql.DB, dir string) (Info, error) {
+	now := time.Now()
+	name := prefix + now.Format(timeLayout) + ".db"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, name)); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		name = fmt.Sprintf("%s%s-%d.db", prefix, now.Format(timeLayout), i)
+	}
+	return Write(ctx, db, filepath.Join(dir, name))
+}
+
+// takes a backup of the running database to path with its checksum file
+func Write(ctx context.Context, db *sql.DB, path string) (Info, error) {
+	if err := database.Backup(ctx, db, path); err != nil {
+		return Info{}, err
+	}
+	sum, err := checksum(path)
+	if err != nil {
+		os.Remove(path)
+		return Info{}, err
+	}
+	line := sum + "  " + filepath.Base(path) + "\n"
+	if err := os.WriteFile(path+checksumExt, []byte(line), 0644); err != nil {
+		os.Remove(path)
+		return Info{}, fmt.Errorf("backup checksum error: %v", err)
+	}
+	return stat(path, sum)
+}
+
+func checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("backup checksum error: %v", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("backup checksum error: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// the checksum recorded for the backup at path, empty when there is none
+func recorded(path string) (string, error) {
+	f, err := os.Open(path + checksumExt)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("backup checksum error: %v", err)
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("backup checksum error: %v", err)
+	}
+	sum, _, _ := strings.Cut(line, " ")
+	return strings.TrimSpace(sum), nil
+}
+
+func stat(path, sum string) (Info, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Info{}, fmt.Errorf("backup stat error: %v", err)
+	}
+	return Info{Name: filepath.Base(path), Size: fi.Size(), SHA256: sum, Created: fi.ModTime()}, nil
+}
+
+// checks the file against its checksum file, when it has one
+func Verify(path string) error {
+	want, err := recorded(path)
+	if err != nil || len(want) == 0 {
+		return err
+	}
+	got, err := checksum(path)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("backup %s: checksum mismatch, the file is damaged", filepath.Base(path))
+	}
+	return nil
+}
+
+// the backups in dir, newest first
+func List(dir string) ([]Info, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, prefix+"*.db"))
+	if err != nil {
+		return nil, fmt.Errorf("backup list error: %v", err)
+	}
+
+	backups := make([]Info, 0, len(paths))
+	for _, p := range paths {
+		sum, err := recorded(p)
+		if err != nil {
+			return nil, err
+		}
+		info, err := stat(p, sum)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, info)
+	}
+	// may-<time>.db before may-<time>-2.db taken in the same second
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i].Name, ".db") > strings.TrimSuffix(backups[j].Name, ".db")
+	})
+	return backups, nil
+}
+
+// removes all but the newest keep backups, keep 0 keeps them all. Returns
+// the names removed.
+func Prune(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	backups, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for i := keep; i < len(backups); i++ {
+		p := filepath.Join(dir, backups[i].Name)
+		if err := os.Remove(p); err != nil {
+			return removed, fmt.Errorf("backup prune error: %v", err)
+		}
+		os.Remove(p + checksumExt)
+		removed = append(removed, backups[i].Name)
+	}
+	return removed, nil
+}
+
+// replaces the running database with the backup at path. A backup of the
+// current database is taken into dir first so the restore can be undone.
+// Returns that backup.
+func Restore(ctx context.Context, db *sql.DB, dir, path string) (Info, error) {
+	if err := Verify(path); err != nil {
+		return Info{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if _, err := database.Validate(path); err != nil {
+		return Info{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	before, err := Create(ctx, db, dir)
+	if err != nil {
+		return Info{}, fmt.Errorf("backup before restore: %v", err)
+	}
+	return before, database.Restore(ctx, db, path)
+}
+
+// takes a backup every interval and prunes to keep until ctx is done
+func Schedule(ctx context.Context, db *sql.DB, dir string, every time.Duration, keep int, logger *slog.Logger) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := Create(ctx, db, dir)
+		if err != nil {
+			logger.Error("scheduled backup failed", "dir", dir, "error", err)
+			continue
+		}
+		logger.Info("scheduled backup", "name", info.Name, "size", info.Size, "sha256", info.SHA256)
+
+		removed, err := Prune(dir, keep)
+		if err != nil {
+			logger.Error("backup retention failed", "dir", dir, "error", err)
+		}
+		if len(removed) > 0 {
+			logger.Info("removed old backups", "names", removed)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+// Package cli has the subcommands for running may without a browser, ex: a
+// nightly backup or an import from a cron job.
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/james-mcallister/may/audit"
+	"github.com/james-mcallister/may/backup"
+	"github.com/james-mcallister/may/config"
+	"github.com/james-mcallister/may/database"
+)
+
+const usage = `usage: may [command] [flags]
+
+commands:
+  serve                   run the web server (the default)
+  import <file>           add the records in a json file
+  export plan <id>        write the plan rows of a plan page as csv
+  backup                  take a backup (or -list them)
+  restore <file|name>     replace the database with a backup
+  migrate                 create or upgrade the database tables
+  calendar extend         add fiscal years to the calendar
+
+Every command takes the server flags (ex: -db-path, -config); run
+may <command> -h for the list.
+`
+
+type command struct {
+	name string
+	run  func(args []string) error
+}
+
+var commands = []command{
+	{"import", runImport},
+	{"export", runExport},
+	{"backup", runBackup},
+	{"restore", runRestore},
+	{"migrate", runMigrate},
+	{"calendar", runCalendar},
+}
+
+// runs the named command and returns the exit code
+func Run(name string, args []string) int {
+	if name == "help" {
+		fmt.Fprint(os.Stdout, usage)
+		return 0
+	}
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		err := c.run(args)
+		if err == nil || errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "may %s: %v\n", name, err)
+		if errors.As(err, new(usageErr)) {
+			return 2
+		}
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "may: unknown command %s\n\n%s", name, usage)
+	return 2
+}
+
+// bad arguments, exits with 2 like a flag error
+type usageErr struct {
+	msg string
+}
+
+func (e usageErr) Error() string {
+	return e.msg
+}
+
+func usageError(format string, a ...any) error {
+	return usageErr{fmt.Sprintf(format, a...)}
+}
+
+// config and positional arguments of a command
+func load(name string, args []string, extra func(fs *flag.FlagSet)) (config.Config, []string, error) {
+	cfg, rest, err := config.LoadArgs("may "+name, args, extra)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		err = usageError("%v", err)
+	}
+	database.SetTimeouts(database.Timeouts{Query: cfg.QueryTimeout, Report: cfg.ReportTimeout})
+	return cfg, rest, err
+}
+
+// opens the database at path. The tables are created or migrated unless the
+// file must already exist (ex: taking a backup).
+func openDB(path string, mustExist bool) (*sql.DB, error) {
+	if _, err := os.Stat(path); mustExist && err != nil {
+		return nil, fmt.Errorf("database %s: %v", path, err)
+	}
+	d, err := database.NewDB(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := d.Connect()
+	if err != nil {
+		return nil, err
+	}
+	if !mustExist {
+		if err := database.InitDB(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// the actor recorded in the audit log for changes made by a command
+func osUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// stdout or the file given with -o
+func output(path string) (io.WriteCloser, error) {
+	if len(path) == 0 {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func runExport(args []string) error {
+	var out string
+	cfg, rest, err := load("export", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&out, "o", "", "write to `file` instead of stdout")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) != 2 || rest[0] != "plan" {
+		return usageError("expecting: export plan <id>")
+	}
+	pageId, err := strconv.ParseInt(rest[1], 10, 64)
+	if err != nil {
+		return usageError("invalid plan page id %s", rest[1])
+	}
+
+	db, err := openDB(cfg.DBPath, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := database.GetPlanPage(context.Background(), db, pageId); err != nil {
+		return err
+	}
+	rows, err := database.ExportPlanPage(context.Background(), db, pageId)
+	if err != nil {
+		return err
+	}
+
+	f, err := output(out)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"plan", "myid", "display_name", "cal_date", "planned_hours", "description"})
+	for _, r := range rows {
+		w.Write([]string{r.Plan, r.Myid, r.DisplayName, r.CalDate,
+			strconv.FormatFloat(r.PlanHours, 'f', -1, 64), r.Description})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runBackup(args []string) error {
+	var out string
+	var list bool
+	cfg, rest, err := load("backup", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&out, "o", "", "write the backup to `file` instead of the backup directory")
+		fs.BoolVar(&list, "list", false, "list the backups in the backup directory")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usageError("unexpected arguments %v", rest)
+	}
+
+	if list {
+		backups, err := backup.List(cfg.BackupDir)
+		if err != nil {
+			return err
+		}
+		for _, b := range backups {
+			fmt.Printf("%s  %10d  %s  %s\n", b.Created.Format(time.DateTime), b.Size, b.SHA256, b.Name)
+		}
+		return nil
+	}
+
+	db, err := openDB(cfg.DBPath, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var info backup.Info
+	if len(out) > 0 {
+		info, err = backup.Write(context.Background(), db, out)
+	} else {
+		info, err = backup.Create(context.Background(), db, cfg.BackupDir)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s  %s\n", info.SHA256, info.Name)
+
+	if len(out) == 0 {
+		removed, err := backup.Prune(cfg.BackupDir, cfg.BackupKeep)
+		for _, name := range removed {
+			fmt.Printf("removed %s\n", name)
+		}
+		return err
+	}
+	return nil
+}
+
+func runRestore(args []string) error {
+	var timeout time.Duration
+	cfg, rest, err := load("restore", args, func(fs *flag.FlagSet) {
+		fs.DurationVar(&timeout, "timeout", time.Minute, "give up when the database stays busy this long")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("expecting: restore <file or backup name>")
+	}
+	path, err := backup.Path(cfg.BackupDir, rest[0])
+	if err != nil {
+		path = rest[0]
+	}
+
+	// the server can keep running, the backup is copied into the open
+	// database
+	d, err := database.NewDB(cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	db, err := d.Connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	before, err := backup.Restore(ctx, db, cfg.BackupDir, path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %s to %s, previous database saved as %s\n", path, cfg.DBPath, before.Name)
+	return nil
+}
+
+func runMigrate(args []string) error {
+	cfg, rest, err := load("migrate", args, nil)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usageError("unexpected arguments %v", rest)
+	}
+
+	d, err := database.NewDB(cfg.DBPath)
+	if err != nil {
+		return err
+	}
+	db, err := d.Connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	from, to, err := database.Migrate(db)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		fmt.Printf("schema version %d, up to date\n", to)
+	} else {
+		fmt.Printf("schema version %d -> %d\n", from, to)
+	}
+	return nil
+}
+
+func runCalendar(args []string) error {
+	var years int
+	cfg, rest, err := load("calendar", args, func(fs *flag.FlagSet) {
+		fs.IntVar(&years, "years", 1, "number of fiscal years to add")
+	})
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 || rest[0] != "extend" {
+		return usageError("expecting: calendar extend")
+	}
+	if years < 1 {
+		return usageError("-years must be at least 1")
+	}
+
+	db, err := openDB(cfg.DBPath, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	added, err := database.ExtendCalendar(audit.As(context.Background(), osUser(), audit.CLI), db, years)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("added %d days\n", added)
+	return nil
+}
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/james-mcallister/may/audit"
+	"github.com/james-mcallister/may/database"
+)
+
+// an import file holds arrays of records in the /api/v1 json format. Ids
+// referring to a record in the same file (ex: an employee's manager) are
+// mapped to the id it gets when inserted; other ids must already exist.
+type importFile struct {
+	Compensation []database.Compensation `json:"compensation"`
+	Ipts         []database.Ipt          `json:"ipts"`
+	Employees    []database.Employee     `json:"employees"`
+	Projects     []database.Project      `json:"projects"`
+	Networks     []database.Network      `json:"networks"`
+	Materials    []database.Material     `json:"materials"`
+}
+
+// file ids to inserted ids
+type idMap map[int64]int64
+
+func (m idMap) remap(n *database.NullInt64) {
+	if !n.Valid {
+		return
+	}
+	if id, ok := m[n.Int64]; ok {
+		n.Int64 = id
+	}
+}
+
+func readImport(path string) (importFile, error) {
+	var f importFile
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+
+	// same defaults as a blank employee form
+	var raw struct {
+		Employees []json.RawMessage `json:"employees"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return f, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return f, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, r := range raw.Employees {
+		e := database.Employee{
+			LaborCapacity: 1.0,
+			Active:        true,
+			CoverageStart: time.Now().Format("2006-01-02"),
+			CoverageEnd:   "2040-12-28",
+		}
+		if err := json.Unmarshal(r, &e); err != nil {
+			return f, fmt.Errorf("%s: employee %d: %v", path, i+1, err)
+		}
+		if len(e.Myid) == 0 {
+			return f, fmt.Errorf("%s: employee %d: myid is required", path, i+1)
+		}
+		if len(e.DisplayName) == 0 {
+			e.DisplayName = e.LastName + ", " + e.FirstName + " (" + e.Myid + ")"
+		}
+		f.Employees[i] = e
+	}
+	return f, nil
+}
+
+func runImport(args []string) error {
+	cfg, rest, err := load("import", args, nil)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("expecting: import <file>")
+	}
+
+	f, err := readImport(rest[0])
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(cfg.DBPath, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// the database functions take a *sql.DB, so the import runs on a single
+	// connection to keep it in one transaction: all records or none
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("BEGIN IMMEDIATE;"); err != nil {
+		return err
+	}
+	ctx := audit.As(context.Background(), osUser(), audit.Import)
+	if err := importRecords(ctx, db, f); err != nil {
+		db.Exec("ROLLBACK;")
+		return fmt.Errorf("%v, nothing imported", err)
+	}
+	if _, err := db.Exec("COMMIT;"); err != nil {
+		return err
+	}
+	fmt.Printf("imported %d compensation, %d ipts, %d employees, %d projects, %d networks, %d materials\n",
+		len(f.Compensation), len(f.Ipts), len(f.Employees), len(f.Projects), len(f.Networks), len(f.Materials))
+	return nil
+}
+
+// inserts the records in dependency order. Managers and parent projects can
+// refer to records later in the file so they are set once all are inserted.
+func importRecords(ctx context.Context, db *sql.DB, f importFile) error {
+	comps, ipts, emps, projs := idMap{}, idMap{}, idMap{}, idMap{}
+
+	for i, c := range f.Compensation {
+		id, err := database.InsertCompensation(ctx, db, c)
+		if err != nil {
+			return fmt.Errorf("compensation %d: %v", i+1, err)
+		}
+		comps[c.Id] = id
+	}
+	for i, t := range f.Ipts {
+		id, err := database.InsertIpt(ctx, db, t)
+		if err != nil {
+			return fmt.Errorf("ipt %d: %v", i+1, err)
+		}
+		ipts[t.Id] = id
+	}
+
+	for i, e := range f.Employees {
+		comps.remap(&e.Comp)
+		ipts.remap(&e.Ipt)
+		manager := e.Manager
+		e.Manager = database.NullInt64{}
+		id, err := database.InsertEmployee(ctx, db, e)
+		if err != nil {
+			return fmt.Errorf("employee %d (%s): %v", i+1, e.Myid, err)
+		}
+		emps[e.Id] = id
+		e.Id, e.Manager = id, manager
+		f.Employees[i] = e
+	}
+
+	for i, p := range f.Projects {
+		parent := p.ParentProject
+		p.ParentProject = database.NullInt64{}
+		id, err := database.InsertProject(ctx, db, p)
+		if err != nil {
+			return fmt.Errorf("project %d (%s): %v", i+1, p.Title, err)
+		}
+		projs[p.Id] = id
+		p.Id, p.ParentProject = id, parent
+		f.Projects[i] = p
+	}
+
+	for _, e := range f.Employees {
+		if !e.Manager.Valid {
+			continue
+		}
+		emps.remap(&e.Manager)
+		if _, err := database.UpdateEmployee(ctx, db, e); err != nil {
+			return fmt.Errorf("employee %s manager: %v", e.Myid, err)
+		}
+	}
+	for _, p := range f.Projects {
+		if !p.ParentProject.Valid {
+			continue
+		}
+		projs.remap(&p.ParentProject)
+		if _, err := database.UpdateProject(ctx, db, p); err != nil {
+			return fmt.Errorf("project %s parent: %v", p.Title, err)
+		}
+	}
+
+	for i, n := range f.Networks {
+		projs.remap(&n.Proj)
+		if _, err := database.InsertNetwork(ctx, db, n); err != nil {
+			return fmt.Errorf("network %d (%s): %v", i+1, n.ChargeNumber, err)
+		}
+	}
+	for i, m := range f.Materials {
+		projs.remap(&m.WorkPackage)
+		if _, err := database.InsertMaterial(ctx, db, m); err != nil {
+			return fmt.Errorf("material %d (%s): %v", i+1, m.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+// mock-oidc is a local OpenID Connect issuer for trying the single sign-on
+// flow without a real identity provider. Every sign in shows a form to pick
+// the username and groups that go into the id token.
+//
+//	go run ./cmd/mock-oidc -addr 127.0.0.1:9000
+//	go run . -oidc-issuer http://127.0.0.1:9000 -oidc-client-id may \
+//		-oidc-role-groups may-admins=admin,may-planners=planner
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type authCode struct {
+	clientId    string
+	redirectUri string
+	nonce       string
+	challenge   string
+	username    string
+	groups      []string
+	expires     time.Time
+}
+
+type issuer struct {
+	url      string
+	clientId string
+	key      *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authCode
+}
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8" /><title>mock-oidc</title></head>
+<body>
+<h1>mock-oidc sign in</h1>
+<form method="POST" action="/authorize">
+  {{ range $k, $v := .Params }}<input type="hidden" name="{{ $k }}" value="{{ index $v 0 }}" />
+  {{ end }}
+  <p><label>Username (myid) <input name="username" value="x00010" /></label></p>
+  <p><label>Groups (comma separated) <input name="groups" value="may-planners" /></label></p>
+  <p><button type="submit">Sign in</button></p>
+</form>
+</body>
+</html>`))
+
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func tokenError(w http.ResponseWriter, code, desc string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": desc})
+}
+
+func (s *issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.url,
+		"authorization_endpoint":                s.url + "/authorize",
+		"token_endpoint":                        s.url + "/token",
+		"jwks_uri":                              s.url + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *issuer) authorizeForm(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != s.clientId || q.Get("response_type") != "code" {
+		http.Error(w, "unknown client or response type", http.StatusBadRequest)
+		return
+	}
+	authorizePage.Execute(w, map[string]any{"Params": q})
+}
+
+func (s *issuer) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var groups []string
+	for _, g := range strings.Split(r.FormValue("groups"), ",") {
+		if g = strings.TrimSpace(g); len(g) > 0 {
+			groups = append(groups, g)
+		}
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = authCode{
+		clientId:    r.FormValue("client_id"),
+		redirectUri: r.FormValue("redirect_uri"),
+		nonce:       r.FormValue("nonce"),
+		challenge:   r.FormValue("code_challenge"),
+		username:    r.FormValue("username"),
+		groups:      groups,
+		expires:     time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	u, err := url.Parse(r.FormValue("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := u.Query()
+	q.Set("code", code)
+	q.Set("state", r.FormValue("state"))
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (s *issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	c, ok := s.codes[r.FormValue("code")]
+	delete(s.codes, r.FormValue("code"))
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	switch {
+	case !ok || time.Now().After(c.expires):
+		tokenError(w, "invalid_grant", "unknown or expired code")
+		return
+	case c.clientId != r.FormValue("client_id") || c.redirectUri != r.FormValue("redirect_uri"):
+		tokenError(w, "invalid_grant", "client or redirect uri mismatch")
+		return
+	case c.challenge != base64.RawURLEncoding.EncodeToString(sum[:]):
+		tokenError(w, "invalid_grant", "pkce verification failed")
+		return
+	}
+
+	now := time.Now().Unix()
+	idToken, err := s.sign(map[string]any{
+		"iss":                s.url,
+		"sub":                c.username,
+		"aud":                c.clientId,
+		"iat":                now,
+		"exp":                now + 300,
+		"nonce":              c.nonce,
+		"preferred_username": c.username,
+		"groups":             c.groups,
+	})
+	if err != nil {
+		tokenError(w, "server_error", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *issuer) sign(claims map[string]any) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "mock", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9000", "listen address")
+	clientId := flag.String("client", "may", "client id accepted by the issuer")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := &issuer{
+		url:      "http://" + *addr,
+		clientId: *clientId,
+		key:      key,
+		codes:    make(map[string]authCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("GET /authorize", s.authorizeForm)
+	mux.HandleFunc("POST /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+
+	log.Printf("mock oidc issuer %s (client id %s)", s.url, s.clientId)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
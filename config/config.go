@@ -0,0 +1,456 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/database"
+)
+
+// server settings. Every setting has a key used in the config file (ex:
+// tls.cert), an environment variable (MAY_TLS_CERT) and a flag (-tls-cert).
+// Flags override environment variables which override the config file.
+type Config struct {
+	Listen          string
+	TLSCert         string
+	TLSKey          string
+	TLSSelfSigned   bool     // generate the cert and key when missing
+	TLSHosts        []string // names and addresses in a generated cert
+	TLSRedirect     string   // plain http address redirecting to https
+	DBPath          string
+	BackupDir       string        // default backups next to the database
+	BackupInterval  time.Duration // scheduled backups, 0 disables them
+	BackupKeep      int           // newest backups kept, 0 keeps all
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	QueryTimeout    time.Duration // a database query, 0 is no limit
+	ReportTimeout   time.Duration // rollups and exports, 0 is no limit
+	LogLevel        slog.Level
+	LogFormat       string // json or text
+	AdminPassword   string // first run admin password, generated when empty
+	MetricsToken    string // bearer token for scraping /metrics without a session
+	Features        Features
+	OIDC            auth.OIDCConfig
+
+	File    string            // config file the settings were read from
+	sources map[string]string // key -> default, file, env or flag
+}
+
+// optional parts of the server
+type Features struct {
+	APIDocs bool // /api/openapi.json and /api/docs
+	APIv1   bool // the /api/v1 rest api
+	Metrics bool // the prometheus /metrics endpoint
+}
+
+func Default() Config {
+	return Config{
+		Listen:          "127.0.0.1:54321",
+		TLSHosts:        []string{"localhost", "127.0.0.1"},
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    15 * time.Second,
+		IdleTimeout:     60 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+		QueryTimeout:    5 * time.Second,
+		ReportTimeout:   30 * time.Second,
+		BackupKeep:      14,
+		LogLevel:        slog.LevelInfo,
+		LogFormat:       "json",
+		Features:        Features{APIDocs: true, APIv1: true, Metrics: true},
+		OIDC:            auth.DefaultOIDCConfig(),
+	}
+}
+
+type setting struct {
+	key    string
+	usage  string
+	bool   bool // flag can be given without a value
+	secret bool // hidden when the config is printed
+	set    func(c *Config, v string) error
+	get    func(c Config) string
+}
+
+func stringSetting(key, usage string, field func(c *Config) *string) setting {
+	return setting{
+		key:   key,
+		usage: usage,
+		set:   func(c *Config, v string) error { *field(c) = v; return nil },
+		get:   func(c Config) string { return *field(&c) },
+	}
+}
+
+func durationSetting(key, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{
+		key:   key,
+		usage: usage,
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = d
+			return nil
+		},
+		get: func(c Config) string { return field(&c).String() },
+	}
+}
+
+func intSetting(key, usage string, field func(c *Config) *int) setting {
+	return setting{
+		key:   key,
+		usage: usage,
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = n
+			return nil
+		},
+		get: func(c Config) string { return strconv.Itoa(*field(&c)) },
+	}
+}
+
+func boolSetting(key, usage string, field func(c *Config) *bool) setting {
+	return setting{
+		key:   key,
+		usage: usage,
+		bool:  true,
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = b
+			return nil
+		},
+		get: func(c Config) string { return strconv.FormatBool(*field(&c)) },
+	}
+}
+
+func secret(s setting) setting {
+	s.secret = true
+	return s
+}
+
+var settings = []setting{
+	stringSetting("listen", "address to listen on", func(c *Config) *string { return &c.Listen }),
+	stringSetting("tls.cert", "TLS certificate file, serves https with tls.key", func(c *Config) *string { return &c.TLSCert }),
+	stringSetting("tls.key", "TLS private key file", func(c *Config) *string { return &c.TLSKey }),
+	boolSetting("tls.self_signed", "generate a self-signed tls.cert and tls.key when they don't exist (default next to the database)", func(c *Config) *bool { return &c.TLSSelfSigned }),
+	{
+		key:   "tls.hosts",
+		usage: "comma separated host names and ip addresses for a generated certificate",
+		set: func(c *Config, v string) error {
+			c.TLSHosts = strings.Fields(strings.ReplaceAll(v, ",", " "))
+			return nil
+		},
+		get: func(c Config) string { return strings.Join(c.TLSHosts, ",") },
+	},
+	stringSetting("tls.redirect", "address for a plain http listener redirecting to https (ex: :80)", func(c *Config) *string { return &c.TLSRedirect }),
+	stringSetting("db.path", "sqlite database file (default in the user cache directory)", func(c *Config) *string { return &c.DBPath }),
+	stringSetting("backup.dir", "directory for backups (default backups next to the database)", func(c *Config) *string { return &c.BackupDir }),
+	durationSetting("backup.interval", "take a backup this often while serving (ex: 24h), 0 disables", func(c *Config) *time.Duration { return &c.BackupInterval }),
+	intSetting("backup.keep", "number of backups kept, older ones are removed after a backup; 0 keeps all", func(c *Config) *int { return &c.BackupKeep }),
+	durationSetting("timeout.read", "max duration for reading a request", func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationSetting("timeout.write", "max duration for writing a response", func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	durationSetting("timeout.idle", "max keep-alive idle duration", func(c *Config) *time.Duration { return &c.IdleTimeout }),
+	durationSetting("timeout.shutdown", "max duration for a graceful shutdown", func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	durationSetting("timeout.query", "max duration of a database query, 0 for none", func(c *Config) *time.Duration { return &c.QueryTimeout }),
+	durationSetting("timeout.report", "max duration of a report query (rollups, exports), 0 for none", func(c *Config) *time.Duration { return &c.ReportTimeout }),
+	{
+		key:   "log.level",
+		usage: "debug, info, warn or error",
+		set: func(c *Config, v string) error {
+			return c.LogLevel.UnmarshalText([]byte(v))
+		},
+		get: func(c Config) string { return strings.ToLower(c.LogLevel.String()) },
+	},
+	{
+		key:   "log.format",
+		usage: "json or text",
+		set: func(c *Config, v string) error {
+			if v != "json" && v != "text" {
+				return errors.New("expecting json or text")
+			}
+			c.LogFormat = v
+			return nil
+		},
+		get: func(c Config) string { return c.LogFormat },
+	},
+	secret(stringSetting("admin_password", "password for the admin user created on first run", func(c *Config) *string { return &c.AdminPassword })),
+	boolSetting("features.api_docs", "serve the openapi document and viewer", func(c *Config) *bool { return &c.Features.APIDocs }),
+	boolSetting("features.api_v1", "serve the /api/v1 rest api", func(c *Config) *bool { return &c.Features.APIv1 }),
+	boolSetting("features.metrics", "serve prometheus metrics on /metrics", func(c *Config) *bool { return &c.Features.Metrics }),
+	secret(stringSetting("metrics.token", "bearer token for scraping /metrics, otherwise an admin session is required", func(c *Config) *string { return &c.MetricsToken })),
+	stringSetting("oidc.issuer", "OpenID Connect issuer url, enables single sign-on", func(c *Config) *string { return &c.OIDC.Issuer }),
+	stringSetting("oidc.client_id", "OpenID Connect client id", func(c *Config) *string { return &c.OIDC.ClientId }),
+	secret(stringSetting("oidc.client_secret", "OpenID Connect client secret", func(c *Config) *string { return &c.OIDC.ClientSecret })),
+	stringSetting("oidc.redirect_url", "callback url registered with the provider (default /login/oidc/callback on the request host)", func(c *Config) *string { return &c.OIDC.RedirectUrl }),
+	{
+		key:   "oidc.scopes",
+		usage: "comma or space separated scopes to request",
+		set: func(c *Config, v string) error {
+			c.OIDC.Scopes = strings.Fields(strings.ReplaceAll(v, ",", " "))
+			return nil
+		},
+		get: func(c Config) string { return strings.Join(c.OIDC.Scopes, ",") },
+	},
+	stringSetting("oidc.myid_claim", "id token claim matched against the employee myid", func(c *Config) *string { return &c.OIDC.MyidClaim }),
+	{
+		key:   "oidc.domains",
+		usage: "comma separated domains dropped from a user@domain myid claim",
+		set: func(c *Config, v string) error {
+			c.OIDC.Domains = strings.Fields(strings.ReplaceAll(v, ",", " "))
+			return nil
+		},
+		get: func(c Config) string { return strings.Join(c.OIDC.Domains, ",") },
+	},
+	stringSetting("oidc.groups_claim", "id token claim with the user's groups", func(c *Config) *string { return &c.OIDC.GroupsClaim }),
+	{
+		key:   "oidc.role_groups",
+		usage: "comma separated group=role pairs",
+		set: func(c *Config, v string) error {
+			return c.OIDC.ParseRoleGroups(v)
+		},
+		get: func(c Config) string {
+			pairs := make([]string, 0, len(c.OIDC.RoleGroups))
+			for g, r := range c.OIDC.RoleGroups {
+				pairs = append(pairs, g+"="+string(r))
+			}
+			slices.Sort(pairs)
+			return strings.Join(pairs, ",")
+		},
+	},
+	{
+		key:   "oidc.default_role",
+		usage: "role for users without a matching group, empty denies sign in",
+		set: func(c *Config, v string) error {
+			c.OIDC.DefaultRole = auth.Role(v)
+			return nil
+		},
+		get: func(c Config) string { return string(c.OIDC.DefaultRole) },
+	},
+}
+
+// tls.cert -> MAY_TLS_CERT
+func envName(key string) string {
+	return "MAY_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// tls.cert -> tls-cert
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+func findSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// reads the config file (-config or MAY_CONFIG), the environment and the
+// command line flags in args
+func Load(name string, args []string) (Config, error) {
+	c, rest, err := LoadArgs(name, args, nil)
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+	return c, err
+}
+
+// Load for subcommands: flags can come before, between or after the
+// positional arguments, which are returned. extra adds the command's own
+// flags to the set.
+func LoadArgs(name string, args []string, extra func(fs *flag.FlagSet)) (Config, []string, error) {
+	c := Default()
+	c.sources = make(map[string]string)
+
+	type flagValue struct {
+		s setting
+		v string
+	}
+	var flagValues []flagValue
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("MAY_CONFIG"), "config file (.json, .toml, .yaml or .yml), env MAY_CONFIG")
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s, env %s", s.usage, envName(s.key))
+		capture := func(v string) error {
+			flagValues = append(flagValues, flagValue{s, v})
+			return nil
+		}
+		if s.bool {
+			fs.BoolFunc(flagName(s.key), usage, capture)
+		} else {
+			fs.Func(flagName(s.key), usage, capture)
+		}
+	}
+	if extra != nil {
+		extra(fs)
+	}
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return c, nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(*file) > 0 {
+		values, err := readFile(*file)
+		if err != nil {
+			return c, nil, err
+		}
+		for k, v := range values {
+			s, ok := findSetting(k)
+			if !ok {
+				return c, nil, fmt.Errorf("config %s: unknown setting %s", *file, k)
+			}
+			if err := c.apply(s, v, "file"); err != nil {
+				return c, nil, fmt.Errorf("config %s: %v", *file, err)
+			}
+		}
+		c.File = *file
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(envName(s.key)); ok {
+			if err := c.apply(s, v, "env"); err != nil {
+				return c, nil, fmt.Errorf("%s: %v", envName(s.key), err)
+			}
+		}
+	}
+
+	for _, f := range flagValues {
+		if err := c.apply(f.s, f.v, "flag"); err != nil {
+			return c, nil, fmt.Errorf("-%s: %v", flagName(f.s.key), err)
+		}
+	}
+
+	if len(c.DBPath) == 0 {
+		p, err := database.DefaultPath()
+		if err != nil {
+			return c, nil, err
+		}
+		c.DBPath = p
+	}
+	if len(c.BackupDir) == 0 {
+		c.BackupDir = filepath.Join(filepath.Dir(c.DBPath), "backups")
+	}
+	if c.TLSSelfSigned && len(c.TLSCert) == 0 && len(c.TLSKey) == 0 {
+		dir := filepath.Dir(c.DBPath)
+		c.TLSCert = filepath.Join(dir, "may.crt")
+		c.TLSKey = filepath.Join(dir, "may.key")
+	}
+	return c, positional, c.Validate()
+}
+
+func (c *Config) apply(s setting, v, source string) error {
+	if err := s.set(c, strings.TrimSpace(v)); err != nil {
+		return fmt.Errorf("invalid %s %q: %v", s.key, v, err)
+	}
+	c.sources[s.key] = source
+	return nil
+}
+
+func (c Config) Validate() error {
+	if len(c.Listen) == 0 {
+		return errors.New("listen address is required")
+	}
+	if (len(c.TLSCert) == 0) != (len(c.TLSKey) == 0) {
+		return errors.New("tls.cert and tls.key must be set together")
+	}
+	if len(c.TLSRedirect) > 0 && !c.TLS() {
+		return errors.New("tls.redirect requires tls.cert and tls.key or tls.self_signed")
+	}
+	if c.TLSSelfSigned && len(c.TLSHosts) == 0 {
+		return errors.New("tls.hosts is required for a self-signed certificate")
+	}
+	for _, d := range []time.Duration{c.ReadTimeout, c.WriteTimeout, c.IdleTimeout, c.ShutdownTimeout, c.QueryTimeout, c.ReportTimeout} {
+		if d < 0 {
+			return errors.New("timeouts can't be negative")
+		}
+	}
+	if c.BackupInterval < 0 || c.BackupKeep < 0 {
+		return errors.New("backup.interval and backup.keep can't be negative")
+	}
+	if c.OIDC.Enabled() {
+		return c.OIDC.Validate()
+	}
+	return nil
+}
+
+func (c Config) TLS() bool {
+	return len(c.TLSCert) > 0
+}
+
+type value struct {
+	key    string
+	value  string
+	source string // default, file, env or flag
+	bool   bool
+}
+
+// the settings in order with secrets masked
+func (c Config) values() []value {
+	values := make([]value, len(settings))
+	for i, s := range settings {
+		v := value{key: s.key, value: s.get(c), source: c.sources[s.key], bool: s.bool}
+		if s.secret && len(v.value) > 0 {
+			v.value = "********"
+		}
+		if len(v.source) == 0 {
+			v.source = "default"
+		}
+		values[i] = v
+	}
+	return values
+}
+
+// writes the effective settings in the config file format with where each
+// came from. Secrets are masked.
+func (c Config) Print(w io.Writer) {
+	if len(c.File) > 0 {
+		fmt.Fprintf(w, "# config file %s\n", filepath.Clean(c.File))
+	}
+	for _, v := range c.values() {
+		s := v.value
+		if !v.bool {
+			s = strconv.Quote(s)
+		}
+		fmt.Fprintf(w, "%s = %s # %s\n", v.key, s, v.source)
+	}
+}
+
+// the effective settings as a log attribute group, secrets masked
+func (c Config) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(settings)+1)
+	if len(c.File) > 0 {
+		attrs = append(attrs, slog.String("file", filepath.Clean(c.File)))
+	}
+	for _, v := range c.values() {
+		attrs = append(attrs, slog.String(v.key, v.value))
+	}
+	return slog.GroupValue(attrs...)
+}
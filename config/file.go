@@ -0,0 +1,243 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// config files are flat key/value settings, nested one level or more by
+// section. The same file in each format:
+//
+//	{"listen": "0.0.0.0:8443", "tls": {"cert": "may.crt", "key": "may.key"}}
+//
+//	listen = "0.0.0.0:8443"
+//	[tls]
+//	cert = "may.crt"
+//	key = "may.key"
+//
+//	listen: 0.0.0.0:8443
+//	tls:
+//	  cert: may.crt
+//	  key: may.key
+//
+// Only the subset of TOML and YAML needed for that is understood: strings,
+// numbers, booleans and lists of them, which are joined with commas.
+
+// setting key -> value
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file error: %v", err)
+	}
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		values, err = parseJSON(b)
+	case ".toml":
+		values, err = parseTOML(b)
+	case ".yaml", ".yml":
+		values, err = parseYAML(b)
+	default:
+		return nil, fmt.Errorf("config file %s: expecting a .json, .toml, .yaml or .yml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %v", path, err)
+	}
+	return values, nil
+}
+
+func joinKey(prefix, key string) string {
+	if len(prefix) == 0 {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func parseJSON(b []byte) (map[string]string, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var doc map[string]any
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	var flatten func(prefix string, m map[string]any) error
+	flatten = func(prefix string, m map[string]any) error {
+		for k, v := range m {
+			key := joinKey(prefix, k)
+			switch v := v.(type) {
+			case map[string]any:
+				if err := flatten(key, v); err != nil {
+					return err
+				}
+			case []any:
+				items := make([]string, len(v))
+				for i, item := range v {
+					items[i] = fmt.Sprint(item)
+				}
+				values[key] = strings.Join(items, ",")
+			case nil:
+				values[key] = ""
+			default:
+				values[key] = fmt.Sprint(v)
+			}
+		}
+		return nil
+	}
+	return values, flatten("", doc)
+}
+
+// strips a trailing # comment outside of quotes
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// a quoted string, a [list, of, values] or a bare value
+func parseValue(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	switch {
+	case strings.HasPrefix(v, `"`):
+		return strconv.Unquote(v)
+	case strings.HasPrefix(v, "'"):
+		if len(v) < 2 || !strings.HasSuffix(v, "'") {
+			return "", fmt.Errorf("unterminated string %s", v)
+		}
+		return v[1 : len(v)-1], nil
+	case strings.HasPrefix(v, "["):
+		if !strings.HasSuffix(v, "]") {
+			return "", fmt.Errorf("unterminated list %s", v)
+		}
+		var items []string
+		for _, item := range strings.Split(v[1:len(v)-1], ",") {
+			if strings.TrimSpace(item) == "" {
+				continue
+			}
+			s, err := parseValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return v, nil
+}
+
+func parseTOML(b []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	var section string
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(s.Text()))
+		if len(line) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table %s", n, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expecting key = value", n)
+		}
+		v, err := parseValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		values[joinKey(section, strings.TrimSpace(key))] = v
+	}
+	return values, s.Err()
+}
+
+func parseYAML(b []byte) (map[string]string, error) {
+	values := make(map[string]string)
+
+	// keys of the mappings enclosing the current line
+	type parent struct {
+		indent int
+		key    string
+	}
+	var parents []parent
+	var listKey string // key of an open block list (- item lines)
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		raw := stripComment(s.Text())
+		line := strings.TrimSpace(raw)
+		if len(line) == 0 || line == "---" {
+			continue
+		}
+		if strings.ContainsRune(raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))], '\t') {
+			return nil, fmt.Errorf("line %d: tabs can't be used for indentation", n)
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+
+		if item, ok := strings.CutPrefix(line, "- "); ok || line == "-" {
+			if len(listKey) == 0 {
+				return nil, fmt.Errorf("line %d: list item without a key", n)
+			}
+			v, err := parseValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			values[listKey] = strings.TrimPrefix(values[listKey]+","+v, ",")
+			continue
+		}
+		listKey = ""
+
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		var prefix string
+		if len(parents) > 0 {
+			prefix = parents[len(parents)-1].key
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expecting key: value", n)
+		}
+		key = joinKey(prefix, strings.TrimSpace(key))
+
+		if strings.TrimSpace(value) == "" {
+			// a nested mapping or block list follows
+			parents = append(parents, parent{indent, key})
+			listKey = key
+			continue
+		}
+		v, err := parseValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		values[key] = v
+	}
+	return values, s.Err()
+}
@@ -0,0 +1,370 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// every insert, update and delete on the audited tables is recorded by
+// triggers with the row before and after as json objects. The actor and
+// source come from the single AuditActor row, which each write sets for the
+// actor in its context inside its own transaction and clears before the
+// commit (see beginWrite); changes made outside of it (ex: the sqlite3
+// shell) have an empty actor.
+const auditTablesQuery = `
+CREATE TABLE IF NOT EXISTS AuditActor (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	actor TEXT NOT NULL DEFAULT '',
+	source TEXT NOT NULL DEFAULT ''
+);
+INSERT OR IGNORE INTO AuditActor (id) VALUES (1);
+CREATE TABLE IF NOT EXISTS AuditLog (
+	id INTEGER PRIMARY KEY,
+	changed_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+	actor TEXT NOT NULL DEFAULT '',
+	source TEXT NOT NULL DEFAULT '', -- ui, api, import, cli or sso
+	tbl TEXT NOT NULL,
+	row_id INTEGER NOT NULL,
+	action TEXT NOT NULL, -- insert, update or delete
+	before TEXT, -- json object, NULL for an insert
+	after TEXT -- json object, NULL for a delete
+);
+CREATE INDEX IF NOT EXISTS idx_audit_row ON AuditLog(tbl,row_id);
+CREATE INDEX IF NOT EXISTS idx_audit_time ON AuditLog(changed_at);
+`
+
+type Audit struct{}
+
+func (a Audit) Init(db *sql.DB) error {
+	if _, err := db.Exec(auditTablesQuery); err != nil {
+		return fmt.Errorf("error executing CREATE TABLE: %v", err)
+	}
+	return nil
+}
+
+type auditedTable struct {
+	name    string
+	exclude []string // columns left out of the json (secrets, timestamps)
+	when    string   // only rows matching are recorded on insert and delete
+}
+
+var auditedTables = []auditedTable{
+	{name: "Calendar"},
+	{name: "CalendarHours"},
+	{name: "Compensation"},
+	{name: "Ipt"},
+	{name: "Employee"},
+	{name: "Project"},
+	{name: "Network"},
+	{name: "Material"},
+	{name: "PlanPage"},
+	{name: "Plan"},
+	// a new plan row inserts a day for the whole period, only hours matter
+	{name: "PlanDay", exclude: []string{"updated_at"}, when: "planned_hours != 0"},
+	{name: "User", exclude: []string{"password_hash"}},
+}
+
+func columnNames(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?);", table)
+	if err != nil {
+		return nil, fmt.Errorf("table info error: %v", err)
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, fmt.Errorf("table info scan error: %v", err)
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+// json_object('id',NEW.id,...) for the row
+func jsonRow(row string, cols []string) string {
+	pairs := make([]string, len(cols))
+	for i, c := range cols {
+		pairs[i] = fmt.Sprintf("'%s',%s.%s", c, row, c)
+	}
+	return "json_object(" + strings.Join(pairs, ",") + ")"
+}
+
+// (re)creates the audit triggers from the current columns, so they follow
+// the tables through migrations
+func createAuditTriggers(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	actor := "(SELECT actor FROM AuditActor WHERE id=1),(SELECT source FROM AuditActor WHERE id=1)"
+	for _, t := range auditedTables {
+		all, err := columnNames(db, t.name)
+		if err != nil {
+			return err
+		}
+		var cols []string
+		for _, c := range all {
+			if !contains(t.exclude, c) {
+				cols = append(cols, c)
+			}
+		}
+
+		when := func(row string) string {
+			if len(t.when) == 0 {
+				return ""
+			}
+			return " WHEN " + row + "." + t.when
+		}
+		oldRow, newRow := jsonRow("OLD", cols), jsonRow("NEW", cols)
+		triggers := []string{
+			fmt.Sprintf(`CREATE TRIGGER audit_%[1]s_insert AFTER INSERT ON %[1]s%[2]s BEGIN
+				INSERT INTO AuditLog (actor,source,tbl,row_id,action,after)
+				VALUES (%[3]s,'%[1]s',NEW.id,'insert',%[4]s);
+			END;`, t.name, when("NEW"), actor, newRow),
+			fmt.Sprintf(`CREATE TRIGGER audit_%[1]s_update AFTER UPDATE ON %[1]s WHEN %[4]s IS NOT %[5]s BEGIN
+				INSERT INTO AuditLog (actor,source,tbl,row_id,action,before,after)
+				VALUES (%[3]s,'%[1]s',NEW.id,'update',%[4]s,%[5]s);
+			END;`, t.name, "", actor, oldRow, newRow),
+			fmt.Sprintf(`CREATE TRIGGER audit_%[1]s_delete AFTER DELETE ON %[1]s%[2]s BEGIN
+				INSERT INTO AuditLog (actor,source,tbl,row_id,action,before)
+				VALUES (%[3]s,'%[1]s',OLD.id,'delete',%[4]s);
+			END;`, t.name, when("OLD"), actor, oldRow),
+		}
+
+		for _, action := range []string{"insert", "update", "delete"} {
+			if _, err := tx.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS audit_%s_%s;", t.name, action)); err != nil {
+				return fmt.Errorf("audit trigger error: %v", err)
+			}
+		}
+		for _, q := range triggers {
+			if _, err := tx.Exec(q); err != nil {
+				return fmt.Errorf("audit trigger %s error: %v", t.name, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type actorKey struct{}
+
+type actor struct {
+	name   string
+	source string
+}
+
+// ctx with the writes made with it recorded for name, from source (ex: ui
+// or api)
+func WithActor(ctx context.Context, name, source string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor{name, source})
+}
+
+const (
+	setActorQuery   = "UPDATE AuditActor SET actor=?,source=? WHERE id=1;"
+	clearActorQuery = "UPDATE AuditActor SET actor='',source='' WHERE id=1;"
+)
+
+// a transaction with its changes recorded for the ctx's actor. The actor
+// row is only set while the transaction holds the write lock, commit with
+// commitWrite to clear it again.
+func beginWrite(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	a, _ := ctx.Value(actorKey{}).(actor)
+	if _, err := tx.ExecContext(ctx, setActorQuery, a.name, a.source); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("audit actor error: %v", err)
+	}
+	return tx, nil
+}
+
+func commitWrite(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, clearActorQuery); err != nil {
+		return fmt.Errorf("audit actor error: %v", err)
+	}
+	return tx.Commit()
+}
+
+// a single write statement recorded for the ctx's actor. It runs in a
+// savepoint rather than a transaction so it can also be part of a larger
+// one (ex: may import).
+func execWrite(ctx context.Context, db *sql.DB, query string, args ...any) (sql.Result, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SAVEPOINT write;"); err != nil {
+		return nil, err
+	}
+	a, _ := ctx.Value(actorKey{}).(actor)
+	_, err = conn.ExecContext(ctx, setActorQuery, a.name, a.source)
+	var result sql.Result
+	if err == nil {
+		result, err = conn.ExecContext(ctx, query, args...)
+	}
+	if err == nil {
+		_, err = conn.ExecContext(ctx, clearActorQuery)
+	}
+
+	// ends the savepoint even when ctx is done, a connection left in it
+	// goes back to the pool as a bad one instead
+	end := context.WithoutCancel(ctx)
+	if err != nil {
+		_, rbErr := conn.ExecContext(end, "ROLLBACK TO write;")
+		if _, relErr := conn.ExecContext(end, "RELEASE write;"); rbErr != nil || relErr != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		return nil, err
+	}
+	if _, err := conn.ExecContext(end, "RELEASE write;"); err != nil {
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+		return nil, err
+	}
+	return result, nil
+}
+
+type AuditEntry struct {
+	Id        int64           `json:"id,string"`
+	ChangedAt string          `json:"changed_at"`
+	Actor     string          `json:"actor"`
+	Source    string          `json:"source"`
+	Table     string          `json:"table"`
+	RowId     int64           `json:"row_id,string"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+}
+
+type FieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// the fields an update changed, or every field of an inserted or deleted row
+func (e AuditEntry) Changes() []FieldChange {
+	var before, after map[string]any
+	json.Unmarshal(e.Before, &before)
+	json.Unmarshal(e.After, &after)
+
+	fields := make(map[string]bool)
+	for k := range before {
+		fields[k] = true
+	}
+	for k := range after {
+		fields[k] = true
+	}
+
+	var changes []FieldChange
+	for k := range fields {
+		b, inBefore := before[k]
+		a, inAfter := after[k]
+		if inBefore && inAfter && fmt.Sprint(b) == fmt.Sprint(a) {
+			continue
+		}
+		c := FieldChange{Field: k}
+		if inBefore && b != nil {
+			c.Before = fmt.Sprint(b)
+		}
+		if inAfter && a != nil {
+			c.After = fmt.Sprint(a)
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// ?table=Employee&row=5&actor=admin&from=2025-01-01&to=2025-01-31
+func AuditListFields() ListFields {
+	return ListFields{
+		Filters: map[string]FilterField{
+			"table":  {Column: "tbl", Type: FilterText},
+			"row":    {Column: "row_id", Type: FilterId},
+			"actor":  {Column: "actor", Type: FilterText},
+			"source": {Column: "source", Type: FilterText},
+			"action": {Column: "action", Type: FilterText},
+			"from":   {Column: "changed_at", Type: FilterDateFrom},
+			"to":     {Column: "changed_at", Type: FilterDateTo},
+		},
+		Sorts: map[string][]string{
+			"time":  {"changed_at"},
+			"actor": {"actor", "changed_at"},
+			"table": {"tbl", "row_id", "changed_at"},
+		},
+		DefaultSort: "time",
+	}
+}
+
+func AuditLog(ctx context.Context, db *sql.DB, q ListQuery) ([]AuditEntry, int64, error) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+
+	total, err := countRows(ctx, db, "AuditLog", q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	getQuery := `
+	SELECT id,changed_at,actor,source,tbl,row_id,action,
+	  COALESCE(before,'null'),COALESCE(after,'null')
+	FROM AuditLog`
+
+	where, args := q.Where()
+	getQuery += where + q.OrderLimit() + ";"
+
+	rows, err := db.QueryContext(ctx, getQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("audit query error: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var before, after string
+		if err := rows.Scan(&e.Id, &e.ChangedAt, &e.Actor, &e.Source, &e.Table, &e.RowId, &e.Action, &before, &after); err != nil {
+			return nil, 0, fmt.Errorf("audit scan error: %v", err)
+		}
+		e.Before, e.After = json.RawMessage(before), json.RawMessage(after)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %v", err)
+	}
+	return entries, total, nil
+}
+
+// names of the tables with a change history
+func AuditedTables() []string {
+	names := make([]string, len(auditedTables))
+	for i, t := range auditedTables {
+		names[i] = t.name
+	}
+	return names
+}
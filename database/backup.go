@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// tables a backup must have to be restored. Only the original schema,
+// tables added since are created when the restored copy is migrated.
+var requiredTables = []string{"Calendar", "CalendarHours", "Compensation", "Ipt",
+	"Employee", "Project", "Network", "Material", "PlanPage", "Plan", "PlanDay"}
+
+// writes a consistent copy of the open database to path. The copy is made
+// by sqlite (VACUUM INTO) so the server can keep running.
+func Backup(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup error: %s already exists", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("backup mkdir error: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?;", path); err != nil {
+		return fmt.Errorf("backup error: %v", err)
+	}
+	return nil
+}
+
+// opens the database file at path read only and checks that it is intact
+// and has the may tables. Returns its schema version.
+func Validate(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("validate error: %v", err)
+	}
+	db, err := sql.Open(driverName, "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, fmt.Errorf("validate error: %v", err)
+	}
+	defer db.Close()
+
+	var check string
+	if err := db.QueryRow("PRAGMA integrity_check;").Scan(&check); err != nil {
+		return 0, fmt.Errorf("validate error: %v", err)
+	}
+	if check != "ok" {
+		return 0, fmt.Errorf("validate error: integrity check failed: %s", check)
+	}
+
+	for _, t := range requiredTables {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?;", t).Scan(&n); err != nil {
+			return 0, fmt.Errorf("validate error: %v", err)
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("validate error: %s is missing table %s", path, t)
+		}
+	}
+
+	v, err := GetSchemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+	if v > SchemaVersion {
+		return v, fmt.Errorf("validate error: schema version %d is newer than this build (%d)", v, SchemaVersion)
+	}
+	return v, nil
+}
+
+// replaces the contents of the open database with the backup in src while
+// the server keeps running. The backup is validated and migrated in a
+// temporary copy first, then copied in with sqlite's online backup api so a
+// failed restore leaves the database untouched.
+func Restore(ctx context.Context, db *sql.DB, src string) error {
+	if _, err := Validate(src); err != nil {
+		return err
+	}
+
+	var seq int
+	var name, path string
+	if err := db.QueryRowContext(ctx, "PRAGMA database_list;").Scan(&seq, &name, &path); err != nil {
+		return fmt.Errorf("restore error: %v", err)
+	}
+	tmp, err := copyTemp(src, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	tmpDB, err := sql.Open(driverName, "file:"+tmp+"?mode=rw&_foreign_keys=true")
+	if err != nil {
+		return fmt.Errorf("restore error: %v", err)
+	}
+	defer tmpDB.Close()
+	if _, _, err := Migrate(tmpDB); err != nil {
+		return fmt.Errorf("restore error: %v", err)
+	}
+	// the backup may have been taken during someone's change
+	if _, err := tmpDB.ExecContext(ctx, clearActorQuery); err != nil {
+		return fmt.Errorf("restore error: %v", err)
+	}
+
+	srcConn, err := tmpDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("restore error: %v", err)
+	}
+	defer srcConn.Close()
+	destConn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("restore error: %v", err)
+	}
+	defer destConn.Close()
+
+	return srcConn.Raw(func(s any) error {
+		return destConn.Raw(func(d any) error {
+			return copyDatabase(ctx, d, s)
+		})
+	})
+}
+
+// the sqlite connection under the metrics wrapper
+func sqliteConn(c any) (*sqlite3.SQLiteConn, error) {
+	for {
+		switch conn := c.(type) {
+		case *sqlite3.SQLiteConn:
+			return conn, nil
+		case interface{ Unwrap() driver.Conn }:
+			c = conn.Unwrap()
+		default:
+			return nil, fmt.Errorf("restore error: unexpected driver connection %T", c)
+		}
+	}
+}
+
+// copies every page of src into dest. Steps are retried while another
+// connection holds a lock, until ctx is done.
+func copyDatabase(ctx context.Context, dest, src any) error {
+	d, err := sqliteConn(dest)
+	if err != nil {
+		return err
+	}
+	s, err := sqliteConn(src)
+	if err != nil {
+		return err
+	}
+
+	b, err := d.Backup("main", s, "main")
+	if err != nil {
+		return fmt.Errorf("restore error: %v", err)
+	}
+	for {
+		done, err := b.Step(-1)
+		if err != nil {
+			b.Close()
+			return fmt.Errorf("restore error: %v", err)
+		}
+		if done {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			b.Close()
+			return fmt.Errorf("restore error: database busy: %v", ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if err := b.Close(); err != nil {
+		return fmt.Errorf("restore error: %v", err)
+	}
+	return nil
+}
+
+func copyTemp(src, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("restore mkdir error: %v", err)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("restore error: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(dir, ".restore-*.db")
+	if err != nil {
+		return "", fmt.Errorf("restore error: %v", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", fmt.Errorf("restore copy error: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("restore copy error: %v", err)
+	}
+	return out.Name(), nil
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -26,7 +27,10 @@ type CalDay struct {
 	Disabled  bool    `json:"disabled"`
 }
 
-func GetCalData(db *sql.DB, popStart, popEnd, fiscalPeriod string) ([]CalDay, error) {
+func GetCalData(ctx context.Context, db *sql.DB, popStart, popEnd, fiscalPeriod string) ([]CalDay, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var days []CalDay
 
 	getQuery := `
@@ -36,7 +40,7 @@ func GetCalData(db *sql.DB, popStart, popEnd, fiscalPeriod string) ([]CalDay, er
 	ORDER BY cal_date;
 	`
 
-	rows, err := db.Query(getQuery, fiscalPeriod)
+	rows, err := db.QueryContext(ctx, getQuery, fiscalPeriod)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -56,7 +60,7 @@ func GetCalData(db *sql.DB, popStart, popEnd, fiscalPeriod string) ([]CalDay, er
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows error: %v", err)
 	}
-	if err := disableDays(db, days, popStart, popEnd); err != nil {
+	if err := disableDays(ctx, db, days, popStart, popEnd); err != nil {
 		return nil, fmt.Errorf("server error in disable days: %v", err)
 	}
 	return days, nil
@@ -72,11 +76,11 @@ func GetDayNum(isodate string) string {
 	return dayNum
 }
 
-func disableDays(db *sql.DB, days []CalDay, popStart, popEnd string) error {
+func disableDays(ctx context.Context, db *sql.DB, days []CalDay, popStart, popEnd string) error {
 	// get the id for the pop start date and pop end date. The id is a monotonically
 	// increasing integer assigned by sqlite. Lower id numbers should be earlier
 	// dates simplifying date comparison for disabling days in the calendar view
-	popDates, err := checkPop(db, popStart, popEnd)
+	popDates, err := checkPop(ctx, db, popStart, popEnd)
 	if err != nil {
 		return fmt.Errorf("query error: %v", err)
 	}
@@ -105,7 +109,7 @@ type DateId struct {
 	DateId  int64
 }
 
-func checkPop(db *sql.DB, popStart, popEnd string) ([]DateId, error) {
+func checkPop(ctx context.Context, db *sql.DB, popStart, popEnd string) ([]DateId, error) {
 	var popDates []DateId
 
 	getQuery := `
@@ -115,7 +119,7 @@ func checkPop(db *sql.DB, popStart, popEnd string) ([]DateId, error) {
 	ORDER BY id;
 	`
 
-	rows, err := db.Query(getQuery, popStart, popEnd)
+	rows, err := db.QueryContext(ctx, getQuery, popStart, popEnd)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -15,6 +16,13 @@ func NewCalendar() Calendar {
 	return Calendar{}
 }
 
+// a calendar needs a name
+func (c Calendar) Validate() error {
+	return validate(
+		required("name", c.Name),
+	)
+}
+
 func (c Calendar) Init(db *sql.DB) error {
 	tableQuery := `
 	CREATE TABLE IF NOT EXISTS Calendar (
@@ -58,27 +66,33 @@ func CalendarDropdownQuery() string {
 	return "SELECT name,id FROM Calendar;"
 }
 
-func GetCalendar(db *sql.DB, id int64) (Calendar, error) {
+func GetCalendar(ctx context.Context, db *sql.DB, id int64) (Calendar, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var cal Calendar
 
 	getQuery := `SELECT id,name,description FROM Calendar WHERE id=?;`
 
-	row := db.QueryRow(getQuery, id)
+	row := db.QueryRowContext(ctx, getQuery, id)
 	if err := row.Scan(&cal.Id, &cal.Name, &cal.Description); err != nil {
 		if err == sql.ErrNoRows {
-			return cal, fmt.Errorf("calendar id=%d: no such row", id)
+			return cal, fmt.Errorf("calendar id=%d: no such row: %w", id, dbError(err))
 		}
 		return cal, fmt.Errorf("calendar: id=%d: %v", id, err)
 	}
 	return cal, nil
 }
 
-func AllCalendars(db *sql.DB) ([]Calendar, error) {
+func AllCalendars(ctx context.Context, db *sql.DB) ([]Calendar, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var cals []Calendar
 
 	getQuery := `SELECT id,name,description FROM Calendar ORDER BY name;`
 
-	rows, err := db.Query(getQuery)
+	rows, err := db.QueryContext(ctx, getQuery)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -100,14 +114,17 @@ func AllCalendars(db *sql.DB) ([]Calendar, error) {
 	return cals, nil
 }
 
-func UpdateCalendar(db *sql.DB, cal Calendar) (int64, error) {
+func UpdateCalendar(ctx context.Context, db *sql.DB, cal Calendar) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	updateQuery := `
 	UPDATE Calendar SET name=?, description=? WHERE id=?;
 	`
 
-	result, err := db.Exec(updateQuery, cal.Name, cal.Description, cal.Id)
+	result, err := execWrite(ctx, db, updateQuery, cal.Name, cal.Description, cal.Id)
 	if err != nil {
-		return 0, fmt.Errorf("update query error: %v", err)
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -117,19 +134,22 @@ func UpdateCalendar(db *sql.DB, cal Calendar) (int64, error) {
 	return rows, nil
 }
 
-func InsertCalendar(db *sql.DB, cal Calendar) (int64, error) {
+func InsertCalendar(ctx context.Context, db *sql.DB, cal Calendar) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	insertQuery := `
 	INSERT INTO Calendar (name,description) VALUES (?, ?);
 	`
 
-	result, err := db.Exec(insertQuery, cal.Name, cal.Description)
+	result, err := execWrite(ctx, db, insertQuery, cal.Name, cal.Description)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
 	}
 
-	rows, err := result.RowsAffected()
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("insert result error: %v", err)
 	}
-	return rows, nil
+	return id, nil
 }
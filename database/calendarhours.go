@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 )
 
 type CalendarHours struct {
@@ -7744,7 +7746,10 @@ func (ch CalendarHours) Init(db *sql.DB) error {
 	return nil
 }
 
-func UpdateCalendarHours(db *sql.DB, cal CalendarHours) (int64, error) {
+func UpdateCalendarHours(ctx context.Context, db *sql.DB, cal CalendarHours) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	updateQuery := `
 	UPDATE CalendarHours SET
 	  productive_hours=?
@@ -7752,9 +7757,9 @@ func UpdateCalendarHours(db *sql.DB, cal CalendarHours) (int64, error) {
 	  AND cal_id=?
 	`
 
-	result, err := db.Exec(updateQuery, cal.ProductiveHours, cal.CalDate, cal.CalId)
+	result, err := execWrite(ctx, db, updateQuery, cal.ProductiveHours, cal.CalDate, cal.CalId)
 	if err != nil {
-		return 0, fmt.Errorf("update query error: %v", err)
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -7764,7 +7769,10 @@ func UpdateCalendarHours(db *sql.DB, cal CalendarHours) (int64, error) {
 	return rows, nil
 }
 
-func GetProdHours(db *sql.DB, startDate, endDate string) ([]float64, error) {
+func GetProdHours(ctx context.Context, db *sql.DB, startDate, endDate string) ([]float64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var h []float64
 
 	getQuery := `
@@ -7773,7 +7781,7 @@ func GetProdHours(db *sql.DB, startDate, endDate string) ([]float64, error) {
 	WHERE cal_date BETWEEN ? AND ?;
 	`
 
-	rows, err := db.Query(getQuery, startDate, endDate)
+	rows, err := db.QueryContext(ctx, getQuery, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -7795,7 +7803,10 @@ func GetProdHours(db *sql.DB, startDate, endDate string) ([]float64, error) {
 	return h, nil
 }
 
-func GetProdHoursIndex(db *sql.DB, startDate, endDate string) (map[string]int, error) {
+func GetProdHoursIndex(ctx context.Context, db *sql.DB, startDate, endDate string) (map[string]int, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	cals := make(map[string]int)
 
 	getQuery := `
@@ -7804,7 +7815,7 @@ func GetProdHoursIndex(db *sql.DB, startDate, endDate string) (map[string]int, e
 	WHERE cal_date BETWEEN ? AND ?;
 	`
 
-	rows, err := db.Query(getQuery, startDate, endDate)
+	rows, err := db.QueryContext(ctx, getQuery, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -7826,3 +7837,169 @@ func GetProdHoursIndex(db *sql.DB, startDate, endDate string) (map[string]int, e
 	}
 	return cals, nil
 }
+
+// weekday (Monday-Friday) holidays with no productive hours in generated
+// fiscal years; company shutdown days are set afterwards on the calendar
+func holidays(year int) map[string]bool {
+	days := make(map[string]bool)
+	add := func(t time.Time) {
+		days[t.Format("2006-01-02")] = true
+	}
+	// fixed date holidays move to Friday or Monday when on a weekend
+	observed := func(month time.Month, day int) {
+		t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		switch t.Weekday() {
+		case time.Saturday:
+			t = t.AddDate(0, 0, -1)
+		case time.Sunday:
+			t = t.AddDate(0, 0, 1)
+		}
+		add(t)
+	}
+	// nth weekday of the month, n < 0 counts from the end of the month
+	nth := func(month time.Month, wd time.Weekday, n int) {
+		t := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		if n < 0 {
+			t = t.AddDate(0, 1, -1)
+			for t.Weekday() != wd {
+				t = t.AddDate(0, 0, -1)
+			}
+		} else {
+			for t.Weekday() != wd {
+				t = t.AddDate(0, 0, 1)
+			}
+			t = t.AddDate(0, 0, 7*(n-1))
+		}
+		add(t)
+	}
+
+	observed(time.January, 1)
+	nth(time.May, time.Monday, -1)
+	observed(time.July, 4)
+	nth(time.September, time.Monday, 1)
+	nth(time.November, time.Thursday, 4)
+	observed(time.December, 25)
+	return days
+}
+
+// last day of a fiscal year: the last Friday of December, or the first
+// Friday of January when that is Christmas day
+func fiscalYearEnd(year int) time.Time {
+	t := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	for t.Weekday() != time.Friday {
+		t = t.AddDate(0, 0, -1)
+	}
+	if t.Day() == 25 {
+		t = t.AddDate(0, 0, 7)
+	}
+	return t
+}
+
+// adds the next years fiscal years to the calendar. Months are 4-4-5 weeks;
+// a 53 week year starts with week 0 in a 5 week first month. Weekdays get 9
+// hours and every other Friday 8, continuing the alternation of the existing
+// calendar. Returns the number of days added.
+func ExtendCalendar(ctx context.Context, db *sql.DB, years int) (int64, error) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+
+	var lastDate, lastFriday string
+	var lastYear int
+	err := db.QueryRowContext(ctx, "SELECT MAX(cal_date),MAX(fiscal_year) FROM CalendarHours;").Scan(&lastDate, &lastYear)
+	if err != nil {
+		return 0, fmt.Errorf("calendar extend query error: %v", err)
+	}
+	err = db.QueryRowContext(ctx, "SELECT MAX(cal_date) FROM CalendarHours WHERE weekday_num=7 AND productive_hours > 0;").Scan(&lastFriday)
+	if err != nil {
+		return 0, fmt.Errorf("calendar extend query error: %v", err)
+	}
+
+	end, err := time.Parse("2006-01-02", lastDate)
+	if err != nil {
+		return 0, fmt.Errorf("calendar extend date error: %v", err)
+	}
+	eightFriday, err := time.Parse("2006-01-02", lastFriday)
+	if err != nil {
+		return 0, fmt.Errorf("calendar extend date error: %v", err)
+	}
+
+	insertQuery := `
+	INSERT INTO CalendarHours
+	  (cal_date,fiscal_period,fiscal_year,fiscal_month,week_num,weekday_num,productive_hours,cal_id)
+	VALUES
+	  (?, ?, ?, ?, ?, ?, ?, 1);
+	`
+
+	tx, err := beginWrite(ctx, db)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var added int64
+	monthWeeks := []int{4, 4, 5, 4, 4, 5, 4, 4, 5, 4, 4, 5}
+	for year := lastYear + 1; year <= lastYear+years; year++ {
+		start := end.AddDate(0, 0, 1)
+		end = fiscalYearEnd(year)
+		if start.Weekday() != time.Saturday {
+			return 0, fmt.Errorf("calendar extend error: fiscal year %d starts on %s, not a Saturday", year, start.Format("2006-01-02"))
+		}
+
+		days := int(end.Sub(start).Hours()/24) + 1
+		extraWeek := days == 371
+		// the fiscal year can start or end in another calendar year
+		off := holidays(year)
+		for _, d := range []time.Time{start, end} {
+			for k := range holidays(d.Year()) {
+				off[k] = true
+			}
+		}
+
+		for i := 0; i < days; i++ {
+			day := start.AddDate(0, 0, i)
+			week := i / 7
+			weekNum := week + 1
+			if extraWeek {
+				weekNum = week
+			}
+
+			month, weeks := 1, 0
+			for m, n := range monthWeeks {
+				if m == 0 && extraWeek {
+					n++
+				}
+				weeks += n
+				if week < weeks {
+					month = m + 1
+					break
+				}
+			}
+
+			hours := 9.0
+			switch day.Weekday() {
+			case time.Saturday, time.Sunday:
+				hours = 0
+			case time.Friday:
+				hours = 8
+				if int(day.Sub(eightFriday).Hours()/24)%14 != 0 {
+					hours = 0
+				}
+			}
+			if off[day.Format("2006-01-02")] {
+				hours = 0
+			}
+
+			_, err := tx.ExecContext(ctx, insertQuery, day.Format("2006-01-02"), fmt.Sprintf("%d%02d", year, month),
+				year, month, weekNum, i%7+1, hours)
+			if err != nil {
+				return 0, fmt.Errorf("calendar extend insert error: %v", err)
+			}
+			added++
+		}
+	}
+
+	if err := commitWrite(ctx, tx); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return added, nil
+}
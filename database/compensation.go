@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -17,6 +18,15 @@ func NewCompensation() Compensation {
 	return Compensation{}
 }
 
+// a resource code, grade and an hourly rate above 0
+func (c Compensation) Validate() error {
+	return validate(
+		required("resource_code", c.ResourceCode),
+		required("grade", c.Grade),
+		positive("hourly_rate", c.HourlyRate),
+	)
+}
+
 func (c Compensation) Init(db *sql.DB) error {
 	tableQuery := `
 	CREATE TABLE IF NOT EXISTS Compensation (
@@ -74,7 +84,10 @@ func CompensationDropdownQuery() string {
 	return "SELECT grade || ' - ' || labor_category AS name,id FROM Compensation ORDER BY name;"
 }
 
-func GetCompensation(db *sql.DB, id int64) (Compensation, error) {
+func GetCompensation(ctx context.Context, db *sql.DB, id int64) (Compensation, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var comp Compensation
 
 	getQuery := `
@@ -84,17 +97,20 @@ func GetCompensation(db *sql.DB, id int64) (Compensation, error) {
 	WHERE id=?;
 	`
 
-	row := db.QueryRow(getQuery, id)
+	row := db.QueryRowContext(ctx, getQuery, id)
 	if err := row.Scan(&comp.Id, &comp.ResourceCode, &comp.Grade, &comp.LaborCategory, &comp.HourlyRate); err != nil {
 		if err == sql.ErrNoRows {
-			return comp, fmt.Errorf("compensation id=%d: no such row", id)
+			return comp, fmt.Errorf("compensation id=%d: no such row: %w", id, dbError(err))
 		}
 		return comp, fmt.Errorf("compensation: id=%d: %v", id, err)
 	}
 	return comp, nil
 }
 
-func AllCompensation(db *sql.DB) ([]Compensation, error) {
+func AllCompensation(ctx context.Context, db *sql.DB) ([]Compensation, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var comps []Compensation
 
 	getQuery := `
@@ -103,7 +119,7 @@ func AllCompensation(db *sql.DB) ([]Compensation, error) {
 	ORDER BY grade;
 	`
 
-	rows, err := db.Query(getQuery)
+	rows, err := db.QueryContext(ctx, getQuery)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -125,14 +141,17 @@ func AllCompensation(db *sql.DB) ([]Compensation, error) {
 	return comps, nil
 }
 
-func UpdateCompensation(db *sql.DB, comp Compensation) (int64, error) {
+func UpdateCompensation(ctx context.Context, db *sql.DB, comp Compensation) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	updateQuery := `
 	UPDATE Compensation SET resource_code=?, grade=?, labor_category=?, hourly_rate=? WHERE id=?;
 	`
 
-	result, err := db.Exec(updateQuery, comp.ResourceCode, comp.Grade, comp.LaborCategory, comp.HourlyRate, comp.Id)
+	result, err := execWrite(ctx, db, updateQuery, comp.ResourceCode, comp.Grade, comp.LaborCategory, comp.HourlyRate, comp.Id)
 	if err != nil {
-		return 0, fmt.Errorf("update query error: %v", err)
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -142,19 +161,22 @@ func UpdateCompensation(db *sql.DB, comp Compensation) (int64, error) {
 	return rows, nil
 }
 
-func InsertCompensation(db *sql.DB, comp Compensation) (int64, error) {
+func InsertCompensation(ctx context.Context, db *sql.DB, comp Compensation) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	insertQuery := `
 	INSERT INTO Compensation (resource_code,grade,labor_category,hourly_rate) VALUES (?, ?, ?, ?);
 	`
 
-	result, err := db.Exec(insertQuery, comp.ResourceCode, comp.Grade, comp.LaborCategory, comp.HourlyRate)
+	result, err := execWrite(ctx, db, insertQuery, comp.ResourceCode, comp.Grade, comp.LaborCategory, comp.HourlyRate)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
 	}
 
-	rows, err := result.RowsAffected()
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("insert result error: %v", err)
 	}
-	return rows, nil
+	return id, nil
 }
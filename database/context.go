@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/james-mcallister/may/logging"
+	"github.com/james-mcallister/may/metrics"
+)
+
+// how long a call may run before its queries are canceled, on top of the
+// caller's context (ex: the http request's). Zero is no limit.
+type Timeouts struct {
+	Query  time.Duration // single rows, lists and updates
+	Report time.Duration // rollups, the org chart and exports
+}
+
+var timeouts = Timeouts{
+	Query:  5 * time.Second,
+	Report: 30 * time.Second,
+}
+
+var canceledCalls = metrics.NewCounterVec("may_db_canceled_total",
+	"Database calls stopped by a canceled request (canceled) or a timeout (timeout).", "call", "reason")
+
+// set once at startup, before serving
+func SetTimeouts(t Timeouts) {
+	timeouts = t
+}
+
+func queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, timeouts.Query)
+}
+
+func reportContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, timeouts.Report)
+}
+
+// marks the context of a database call, calls made from inside it (ex: a
+// rollup reading the ipts) run under the outer call's timeout
+type callKey struct{}
+
+// the cancel func logs and counts the call when its context ended first,
+// named after the database function deferring it
+func withTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if parent.Value(callKey{}) != nil {
+		return parent, func() {}
+	}
+
+	call := "unknown"
+	if pc, _, _, ok := runtime.Caller(2); ok {
+		if f := runtime.FuncForPC(pc); f != nil {
+			call = f.Name()[strings.LastIndex(f.Name(), ".")+1:]
+		}
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if d > 0 {
+		ctx, cancel = context.WithTimeout(parent, d)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	ctx = context.WithValue(ctx, callKey{}, call)
+	start := time.Now()
+	return ctx, func() {
+		if err := ctx.Err(); err != nil {
+			reason := "timeout"
+			if parent.Err() != nil {
+				reason = "canceled"
+			}
+			canceledCalls.Inc(call, reason)
+			slog.WarnContext(ctx, "database call "+reason,
+				"call", call,
+				"request_id", logging.RequestID(ctx),
+				"route", logging.Route(ctx),
+				"elapsed", time.Since(start),
+				"timeout", d)
+		}
+		cancel()
+	}
+}
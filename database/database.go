@@ -1,57 +1,80 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/james-mcallister/may/metrics"
+	"github.com/mattn/go-sqlite3"
 )
 
+// sqlite3 with every statement timed for the metrics endpoint
+const driverName = "sqlite3_metrics"
+
+func init() {
+	sql.Register(driverName, metrics.WrapDriver(&sqlite3.SQLiteDriver{}))
+}
+
+// bumped with every entry in migrations; stored in PRAGMA user_version
+const SchemaVersion = 7
+
 type Database struct {
 	Connstring string
 }
 
-func NewDB() (Database, error) {
+// opens the database file at path, creating its directory. An empty path
+// uses the default location in the user cache directory.
+func NewDB(path string) (Database, error) {
 	d := Database{}
-	p, err := dbPath()
-	if err != nil {
-		return d, fmt.Errorf("error creating db file: %v", err)
+	if len(path) == 0 {
+		p, err := DefaultPath()
+		if err != nil {
+			return d, fmt.Errorf("error creating db file: %v", err)
+		}
+		path = p
 	}
-	d.Connstring = "file:" + p + "?mode=rwc&_foreign_keys=true"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return d, fmt.Errorf("dbpath mkdir error: %v", err)
+	}
+	d.Connstring = "file:" + path + "?mode=rwc&_foreign_keys=true"
 	return d, nil
 }
 
 func (d Database) Connect() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", d.Connstring)
+	db, err := sql.Open(driverName, d.Connstring)
 	if err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
-func dbPath() (string, error) {
+// may_app/may.db in the user cache directory
+func DefaultPath() (string, error) {
 	baseDir, err := os.UserCacheDir()
 	if err != nil {
 		return "", fmt.Errorf("dbpath error: %v", err)
 	}
-	appDir := "may_app"
-	dbFile := "may.db"
-	fullPath := filepath.Join(baseDir, appDir)
-
-	err = os.MkdirAll(fullPath, 0755)
-	if err != nil {
-		return "", fmt.Errorf("dbpath mkdir error: %v", err)
-	}
-	return filepath.Join(fullPath, dbFile), nil
+	return filepath.Join(baseDir, "may_app", "may.db"), nil
 }
 
 type DBTable interface {
 	Init(*sql.DB) error
 }
 
+// creates the tables of a new database and migrates an existing one
 func InitDB(db *sql.DB) error {
+	if _, _, err := Migrate(db); err != nil {
+		return fmt.Errorf("database init error: %v", err)
+	}
+	return nil
+}
+
+func createTables(db *sql.DB) error {
 	tables := []DBTable{
 		Calendar{},
 		CalendarHours{},
@@ -64,26 +87,120 @@ func InitDB(db *sql.DB) error {
 		PlanPage{},
 		Plan{},
 		PlanDay{},
+		User{},
+		Audit{},
+		RecycleBin{},
+		PlanJournal{},
+		PlanRowVersion{},
 	}
 
 	for _, t := range tables {
 		err := t.Init(db)
 		if err != nil {
-			return fmt.Errorf("database init error: %v", err)
+			return fmt.Errorf("create tables error: %v", err)
 		}
 	}
 	return nil
 }
 
+func GetSchemaVersion(db *sql.DB) (int, error) {
+	var v int
+	if err := db.QueryRow("PRAGMA user_version;").Scan(&v); err != nil {
+		return 0, fmt.Errorf("schema version error: %v", err)
+	}
+	return v, nil
+}
+
+// nullable foreign key. JSON encodes the id as a string (like the Id fields)
+// or null when the key is not set
+type NullInt64 struct {
+	sql.NullInt64
+}
+
+func NewNullInt64(v int64) NullInt64 {
+	return NullInt64{sql.NullInt64{Int64: v, Valid: true}}
+}
+
+func (n NullInt64) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(strconv.FormatInt(n.Int64, 10))
+}
+
+func (n *NullInt64) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" || s == `""` {
+		*n = NullInt64{}
+		return nil
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		// not a string, accept a plain json number
+		s = string(b)
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id %s: %v", b, err)
+	}
+	*n = NewNullInt64(v)
+	return nil
+}
+
+// nullable amount, encoded as a json number or null. Cost fields use it so
+// they can be redacted (see Redact) without reporting a misleading zero.
+type NullFloat64 struct {
+	sql.NullFloat64
+}
+
+func NewNullFloat64(v float64) NullFloat64 {
+	return NullFloat64{sql.NullFloat64{Float64: v, Valid: true}}
+}
+
+func (n NullFloat64) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.Float64)
+}
+
+func (n *NullFloat64) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" || s == `""` {
+		*n = NullFloat64{}
+		return nil
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		// not a string, accept a plain json number
+		s = string(b)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s: %v", b, err)
+	}
+	*n = NewNullFloat64(v)
+	return nil
+}
+
+// template value, empty when not set
+func (n NullFloat64) String() string {
+	if !n.Valid {
+		return ""
+	}
+	return strconv.FormatFloat(n.Float64, 'f', -1, 64)
+}
+
 type Dropdown struct {
 	Id   int64  `json:"id,string"`
 	Name string `json:"name"`
 }
 
-func NewDropdown(db *sql.DB, query string) ([]Dropdown, error) {
+func NewDropdown(ctx context.Context, db *sql.DB, query string) ([]Dropdown, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var names []Dropdown
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("dropdown query error: %v", err)
 	}
@@ -105,12 +222,15 @@ func NewDropdown(db *sql.DB, query string) ([]Dropdown, error) {
 	return names, nil
 }
 
-func DeleteRow(db *sql.DB, table string, id int64) (int64, error) {
+func DeleteRow(ctx context.Context, db *sql.DB, table string, id int64) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id=?;", table)
 
-	result, err := db.Exec(deleteQuery, id)
+	result, err := execWrite(ctx, db, deleteQuery, id)
 	if err != nil {
-		return 0, fmt.Errorf("delete query exec error: %v", err)
+		return 0, fmt.Errorf("delete query exec error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -120,12 +240,15 @@ func DeleteRow(db *sql.DB, table string, id int64) (int64, error) {
 	return rows, nil
 }
 
-func DeleteAll(db *sql.DB, table string) (int64, error) {
+func DeleteAll(ctx context.Context, db *sql.DB, table string) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	deleteAllQuery := fmt.Sprintf("DELETE FROM %s;", table)
 
-	result, err := db.Exec(deleteAllQuery)
+	result, err := execWrite(ctx, db, deleteAllQuery)
 	if err != nil {
-		return 0, fmt.Errorf("delete all query exec error: %v", err)
+		return 0, fmt.Errorf("delete all query exec error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -1,31 +1,45 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
 
 type Employee struct {
-	Id            int64         `json:"id,string"`
-	FirstName     string        `json:"first_name"`
-	LastName      string        `json:"last_name"`
-	DisplayName   string        `json:"display_name"`
-	Myid          string        `json:"myid"`
-	Empid         string        `json:"empid"`
-	LaborCapacity float64       `json:"labor_cap,string"`
-	Desk          string        `json:"desk"`
-	Active        bool          `json:"active"`
-	CoverageStart string        `json:"cov_start"`
-	CoverageEnd   string        `json:"cov_end"`
-	Comp          sql.NullInt64 `json:"comp"`
-	Manager       sql.NullInt64 `json:"manager"`
-	Ipt           sql.NullInt64 `json:"ipt"`
+	Id            int64     `json:"id,string"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	DisplayName   string    `json:"display_name"`
+	Myid          string    `json:"myid"`
+	Empid         string    `json:"empid"`
+	LaborCapacity float64   `json:"labor_cap,string"`
+	Desk          string    `json:"desk"`
+	Active        bool      `json:"active"`
+	CoverageStart string    `json:"cov_start"`
+	CoverageEnd   string    `json:"cov_end"`
+	Comp          NullInt64 `json:"comp"`
+	Manager       NullInt64 `json:"manager"`
+	Ipt           NullInt64 `json:"ipt"`
 }
 
 func NewEmployee() Employee {
 	return Employee{}
 }
 
+// names, myid and a labor capacity in (0,1], coverage can't end before it starts
+func (e Employee) Validate() error {
+	return validate(
+		required("first_name", e.FirstName),
+		required("last_name", e.LastName),
+		required("myid", e.Myid),
+		fraction("labor_cap", e.LaborCapacity),
+		date("cov_start", e.CoverageStart),
+		date("cov_end", e.CoverageEnd),
+		dateOrder("cov_start", e.CoverageStart, "cov_end", e.CoverageEnd),
+	)
+}
+
 func (e Employee) Init(db *sql.DB) error {
 	tableQuery := `
 	CREATE TABLE IF NOT EXISTS Employee (
@@ -105,71 +119,131 @@ func EmployeeImportColumns() []string {
 		"ipt", "manager", "grade"}
 }
 
-func GetEmployee(db *sql.DB, id int64) (Employee, error) {
+func GetEmployee(ctx context.Context, db *sql.DB, id int64) (Employee, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var emp Employee
 
 	getQuery := `
 	SELECT
-	  id,first_name,last_name,myid,empid,labor_capacity,
+	  id,first_name,last_name,display_name,myid,empid,labor_capacity,
 	  desk,active,coverage_start,coverage_end,comp,reports_to,ipt
 	FROM Employee
 	WHERE id=?;
 	`
 
-	row := db.QueryRow(getQuery, id)
-	if err := row.Scan(&emp.Id, &emp.FirstName, &emp.LastName, &emp.Myid, &emp.Empid, &emp.LaborCapacity, &emp.Desk, &emp.Active, &emp.CoverageStart, &emp.CoverageEnd, &emp.Comp, &emp.Manager, &emp.Ipt); err != nil {
+	row := db.QueryRowContext(ctx, getQuery, id)
+	if err := row.Scan(&emp.Id, &emp.FirstName, &emp.LastName, &emp.DisplayName, &emp.Myid, &emp.Empid, &emp.LaborCapacity, &emp.Desk, &emp.Active, &emp.CoverageStart, &emp.CoverageEnd, &emp.Comp, &emp.Manager, &emp.Ipt); err != nil {
 		if err == sql.ErrNoRows {
-			return emp, fmt.Errorf("employee id=%d: no such row", id)
+			return emp, fmt.Errorf("employee id=%d: no such row: %w", id, dbError(err))
 		}
 		return emp, fmt.Errorf("employee: id=%d: %v", id, err)
 	}
 	return emp, nil
 }
 
-func AllEmployees(db *sql.DB) ([]Employee, error) {
-	var emps []Employee
+// employee for a single sign-on identity, myid is matched case insensitively
+func GetEmployeeByMyid(ctx context.Context, db *sql.DB, myid string) (Employee, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var emp Employee
 
 	getQuery := `
 	SELECT
-	  id,first_name,last_name,myid,empid,labor_capacity,
+	  id,first_name,last_name,display_name,myid,empid,labor_capacity,
 	  desk,active,coverage_start,coverage_end,comp,reports_to,ipt
 	FROM Employee
-	ORDER BY last_name,first_name;
+	WHERE myid=? COLLATE NOCASE;
 	`
 
-	rows, err := db.Query(getQuery)
+	row := db.QueryRowContext(ctx, getQuery, myid)
+	if err := row.Scan(&emp.Id, &emp.FirstName, &emp.LastName, &emp.DisplayName, &emp.Myid, &emp.Empid, &emp.LaborCapacity, &emp.Desk, &emp.Active, &emp.CoverageStart, &emp.CoverageEnd, &emp.Comp, &emp.Manager, &emp.Ipt); err != nil {
+		if err == sql.ErrNoRows {
+			return emp, fmt.Errorf("employee myid=%s: no such row: %w", myid, dbError(err))
+		}
+		return emp, fmt.Errorf("employee: myid=%s: %v", myid, err)
+	}
+	return emp, nil
+}
+
+func EmployeeListFields() ListFields {
+	return ListFields{
+		Filters: map[string]FilterField{
+			"active":  {Column: "active", Type: FilterBool},
+			"ipt":     {Column: "ipt", Type: FilterId},
+			"manager": {Column: "reports_to", Type: FilterId},
+			"comp":    {Column: "comp", Type: FilterId},
+		},
+		Sorts: map[string][]string{
+			"id":        {"id"},
+			"name":      {"last_name", "first_name"},
+			"myid":      {"myid"},
+			"labor_cap": {"labor_capacity"},
+			"cov_start": {"coverage_start"},
+			"cov_end":   {"coverage_end"},
+		},
+		DefaultSort: "name",
+	}
+}
+
+func AllEmployees(ctx context.Context, db *sql.DB, q ListQuery) ([]Employee, int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var emps []Employee
+
+	total, err := countRows(ctx, db, "Employee", q)
 	if err != nil {
-		return nil, fmt.Errorf("query error: %v", err)
+		return nil, 0, err
+	}
+
+	getQuery := `
+	SELECT
+	  id,first_name,last_name,display_name,myid,empid,labor_capacity,
+	  desk,active,coverage_start,coverage_end,comp,reports_to,ipt
+	FROM Employee`
+
+	where, args := q.Where()
+	getQuery += where + q.OrderLimit() + ";"
+
+	rows, err := db.QueryContext(ctx, getQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query error: %v", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var emp Employee
-		if err := rows.Scan(&emp.Id, &emp.FirstName, &emp.LastName, &emp.Myid, &emp.Empid, &emp.LaborCapacity, &emp.Desk, &emp.Active, &emp.CoverageStart, &emp.CoverageEnd, &emp.Comp, &emp.Manager, &emp.Ipt); err != nil {
+		if err := rows.Scan(&emp.Id, &emp.FirstName, &emp.LastName, &emp.DisplayName, &emp.Myid, &emp.Empid, &emp.LaborCapacity, &emp.Desk, &emp.Active, &emp.CoverageStart, &emp.CoverageEnd, &emp.Comp, &emp.Manager, &emp.Ipt); err != nil {
 			if err == sql.ErrNoRows {
-				return nil, fmt.Errorf("error: no rows")
+				return nil, 0, fmt.Errorf("error: no rows")
 			}
-			return nil, fmt.Errorf("row scan error: %v", err)
+			return nil, 0, fmt.Errorf("row scan error: %v", err)
 		}
 		emps = append(emps, emp)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %v", err)
+		return nil, 0, fmt.Errorf("rows error: %v", err)
 	}
-	return emps, nil
+	return emps, total, nil
 }
 
-func UpdateEmployee(db *sql.DB, emp Employee) (int64, error) {
+func UpdateEmployee(ctx context.Context, db *sql.DB, emp Employee) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	updateQuery := `
 	UPDATE Employee SET
-	  first_name=?,last_name=?,myid=?,empid=?,labor_capacity=?,desk=?,
+	  first_name=?,last_name=?,display_name=?,myid=?,empid=?,labor_capacity=?,desk=?,
 	  active=?,coverage_start=?,coverage_end=?,comp=?,reports_to=?,ipt=?
 	WHERE id=?;
 	`
 
-	result, err := db.Exec(updateQuery, emp.FirstName, emp.LastName, emp.Myid, emp.Empid, emp.LaborCapacity, emp.Desk, emp.Active, emp.CoverageStart, emp.CoverageEnd, emp.Comp, emp.Manager, emp.Ipt, emp.Id)
+	result, err := execWrite(ctx, db, updateQuery, emp.FirstName, emp.LastName, emp.DisplayName, emp.Myid, emp.Empid, emp.LaborCapacity, emp.Desk, emp.Active, emp.CoverageStart, emp.CoverageEnd, emp.Comp, emp.Manager, emp.Ipt, emp.Id)
 	if err != nil {
-		return 0, fmt.Errorf("update query error: %v", err)
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -179,23 +253,26 @@ func UpdateEmployee(db *sql.DB, emp Employee) (int64, error) {
 	return rows, nil
 }
 
-func InsertEmployee(db *sql.DB, emp Employee) (int64, error) {
+func InsertEmployee(ctx context.Context, db *sql.DB, emp Employee) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	insertQuery := `
 	INSERT INTO Employee
-	  (first_name,last_name,myid,empid,labor_capacity,
+	  (first_name,last_name,display_name,myid,empid,labor_capacity,
 	  desk,active,coverage_start,coverage_end,comp,reports_to,ipt)
 	VALUES
-	  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 	`
 
-	result, err := db.Exec(insertQuery, emp.FirstName, emp.LastName, emp.Myid, emp.Empid, emp.LaborCapacity, emp.Desk, emp.Active, emp.CoverageStart, emp.CoverageEnd, emp.Comp, emp.Manager, emp.Ipt)
+	result, err := execWrite(ctx, db, insertQuery, emp.FirstName, emp.LastName, emp.DisplayName, emp.Myid, emp.Empid, emp.LaborCapacity, emp.Desk, emp.Active, emp.CoverageStart, emp.CoverageEnd, emp.Comp, emp.Manager, emp.Ipt)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
 	}
 
-	rows, err := result.RowsAffected()
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("insert result error: %v", err)
 	}
-	return rows, nil
+	return id, nil
 }
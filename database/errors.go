@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// the kinds of errors callers can act on, ex: the http status of a response.
+// Match them with errors.Is, anything else is an internal error.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")             // unique values, stale or missing state
+	ErrConstraint = errors.New("constraint violation") // foreign key, check and not null
+	ErrValidation = errors.New("validation failed")
+
+	ErrUnauthorized = errors.New("authentication required") // no session
+	ErrForbidden    = errors.New("forbidden")               // the user's role or a failed csrf check
+)
+
+// an error of one of the kinds above. The message is the wrapped error's,
+// errors.Is and errors.As see both.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// an ErrValidation error, ex: a form value that doesn't parse
+func Invalid(err error) error {
+	return &Error{Kind: ErrValidation, Err: err}
+}
+
+func Invalidf(format string, a ...any) error {
+	return Invalid(fmt.Errorf(format, a...))
+}
+
+// an error of the kind with the message
+func kindError(kind error, msg string) error {
+	return &Error{Kind: kind, Err: errors.New(msg)}
+}
+
+// gives sql.ErrNoRows and sqlite constraint errors their kind, others are
+// returned as they are
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Err: err}
+	}
+
+	var se sqlite3.Error
+	if !errors.As(err, &se) || se.Code != sqlite3.ErrConstraint {
+		return err
+	}
+	switch se.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return &Error{Kind: ErrConflict, Err: err}
+	}
+	return &Error{Kind: ErrConstraint, Err: err}
+}
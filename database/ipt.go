@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -15,6 +16,13 @@ func NewIpt() Ipt {
 	return Ipt{}
 }
 
+// an ipt needs a name
+func (i Ipt) Validate() error {
+	return validate(
+		required("name", i.Name),
+	)
+}
+
 func (i Ipt) Init(db *sql.DB) error {
 	tableQuery := `
 	CREATE TABLE IF NOT EXISTS Ipt (
@@ -61,27 +69,33 @@ func IptDropdownQuery() string {
 	return "SELECT name,id FROM Ipt ORDER BY name;"
 }
 
-func GetIpt(db *sql.DB, id int64) (Ipt, error) {
+func GetIpt(ctx context.Context, db *sql.DB, id int64) (Ipt, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var ipt Ipt
 
 	getQuery := `SELECT id,name,description FROM Ipt WHERE id=?;`
 
-	row := db.QueryRow(getQuery, id)
+	row := db.QueryRowContext(ctx, getQuery, id)
 	if err := row.Scan(&ipt.Id, &ipt.Name, &ipt.Description); err != nil {
 		if err == sql.ErrNoRows {
-			return ipt, fmt.Errorf("ipt id=%d: no such row", id)
+			return ipt, fmt.Errorf("ipt id=%d: no such row: %w", id, dbError(err))
 		}
 		return ipt, fmt.Errorf("ipt: id=%d: %v", id, err)
 	}
 	return ipt, nil
 }
 
-func AllIpts(db *sql.DB) ([]Ipt, error) {
+func AllIpts(ctx context.Context, db *sql.DB) ([]Ipt, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var ipts []Ipt
 
 	getQuery := `SELECT id,name,description FROM Ipt ORDER BY name;`
 
-	rows, err := db.Query(getQuery)
+	rows, err := db.QueryContext(ctx, getQuery)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -103,14 +117,17 @@ func AllIpts(db *sql.DB) ([]Ipt, error) {
 	return ipts, nil
 }
 
-func UpdateIpt(db *sql.DB, ipt Ipt) (int64, error) {
+func UpdateIpt(ctx context.Context, db *sql.DB, ipt Ipt) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	updateQuery := `
 	UPDATE Ipt SET name=?, description=? WHERE id=?;
 	`
 
-	result, err := db.Exec(updateQuery, ipt.Name, ipt.Description, ipt.Id)
+	result, err := execWrite(ctx, db, updateQuery, ipt.Name, ipt.Description, ipt.Id)
 	if err != nil {
-		return 0, fmt.Errorf("update query error: %v", err)
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -120,19 +137,22 @@ func UpdateIpt(db *sql.DB, ipt Ipt) (int64, error) {
 	return rows, nil
 }
 
-func InsertIpt(db *sql.DB, ipt Ipt) (int64, error) {
+func InsertIpt(ctx context.Context, db *sql.DB, ipt Ipt) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	insertQuery := `
 	INSERT INTO Ipt (name,description) VALUES (?, ?);
 	`
 
-	result, err := db.Exec(insertQuery, ipt.Name, ipt.Description)
+	result, err := execWrite(ctx, db, insertQuery, ipt.Name, ipt.Description)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
 	}
 
-	rows, err := result.RowsAffected()
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("insert result error: %v", err)
 	}
-	return rows, nil
+	return id, nil
 }
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// staffing for an IPT in a fiscal period compared to the labor capacity of
+// the employees assigned to the IPT
+type IptPeriod struct {
+	PeriodRollup
+	Headcount     int     `json:"headcount"` // employees with planned hours
+	CapacityHours float64 `json:"capacity_hours"`
+	CapacityFte   float64 `json:"capacity_fte"`
+}
+
+type IptRollup struct {
+	IptId   int64       `json:"ipt_id,string"`
+	IptName string      `json:"ipt_name"`
+	Periods []IptPeriod `json:"periods"`
+}
+
+type EmployeeRollup struct {
+	EmpId         int64          `json:"emp_id,string"`
+	EmpName       string         `json:"emp_name"`
+	LaborCapacity float64        `json:"labor_cap"`
+	Periods       []PeriodRollup `json:"periods"`
+}
+
+// returns an empty rollup for each month so the periods line up between rows
+func newPeriods(months []PlanMonth) []PeriodRollup {
+	periods := make([]PeriodRollup, len(months))
+	for i, m := range months {
+		periods[i] = PeriodRollup{
+			FiscalPeriod: m.FiscalPeriod,
+			DisplayName:  m.DisplayName,
+			MonthHours:   m.MonthHours,
+			Cost:         NewNullFloat64(0),
+		}
+	}
+	return periods
+}
+
+func periodIndex(months []PlanMonth) map[string]int {
+	idx := make(map[string]int, len(months))
+	for i, m := range months {
+		idx[m.FiscalPeriod] = i
+	}
+	return idx
+}
+
+// planned hours, FTE, headcount and cost for every IPT across all plans
+func GetIptRollups(ctx context.Context, db *sql.DB, startDate, endDate string) ([]IptRollup, error) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+
+	ipts, err := AllIpts(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("ipt list error: %v", err)
+	}
+	months, err := GetPlanMonths(ctx, db, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("error getting months: %v", err)
+	}
+	idx := periodIndex(months)
+
+	rollups := make([]IptRollup, len(ipts))
+	lookup := make(map[int64]*IptRollup, len(ipts))
+	for i, ipt := range ipts {
+		rollups[i] = IptRollup{
+			IptId:   ipt.Id,
+			IptName: ipt.Name,
+			Periods: make([]IptPeriod, len(months)),
+		}
+		for j, p := range newPeriods(months) {
+			rollups[i].Periods[j].PeriodRollup = p
+		}
+		lookup[ipt.Id] = &rollups[i]
+	}
+
+	planQuery := `
+	SELECT e.ipt,ch.fiscal_period,
+	       sum(pd.planned_hours),
+	       sum(pd.planned_hours * IFNULL(c.hourly_rate,0)),
+	       COUNT(DISTINCT CASE WHEN pd.planned_hours > 0 THEN pd.emp END)
+	FROM PlanDay pd
+	JOIN Employee e ON pd.emp=e.id
+	LEFT JOIN Compensation c ON e.comp=c.id
+	JOIN CalendarHours ch ON pd.cal_date=ch.cal_date
+	WHERE e.ipt IS NOT NULL
+	  AND pd.cal_date BETWEEN ? AND ?
+	GROUP BY e.ipt,ch.fiscal_period;
+	`
+
+	rows, err := db.QueryContext(ctx, planQuery, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var iptId int64
+		var fp string
+		var hours, cost float64
+		var headcount int
+		if err := rows.Scan(&iptId, &fp, &hours, &cost, &headcount); err != nil {
+			return nil, fmt.Errorf("row scan error: %v", err)
+		}
+		r, ok := lookup[iptId]
+		if !ok {
+			continue
+		}
+		j, ok := idx[fp]
+		if !ok {
+			continue
+		}
+		p := &r.Periods[j]
+		p.PlanHours = hours
+		p.Cost = NewNullFloat64(cost)
+		p.Headcount = headcount
+		if p.MonthHours > 0 {
+			p.Fte = hours / p.MonthHours
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	// available hours are the productive calendar hours inside each active
+	// employee's coverage dates scaled by their labor capacity
+	capacityQuery := `
+	SELECT e.ipt,ch.fiscal_period,sum(ch.productive_hours * e.labor_capacity)
+	FROM Employee e
+	JOIN CalendarHours ch ON ch.cal_date BETWEEN e.coverage_start AND e.coverage_end
+	WHERE e.ipt IS NOT NULL
+	  AND e.active
+	  AND ch.cal_date BETWEEN ? AND ?
+	GROUP BY e.ipt,ch.fiscal_period;
+	`
+
+	capRows, err := db.QueryContext(ctx, capacityQuery, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("capacity query error: %v", err)
+	}
+	defer capRows.Close()
+
+	for capRows.Next() {
+		var iptId int64
+		var fp string
+		var hours float64
+		if err := capRows.Scan(&iptId, &fp, &hours); err != nil {
+			return nil, fmt.Errorf("row scan error: %v", err)
+		}
+		r, ok := lookup[iptId]
+		if !ok {
+			continue
+		}
+		j, ok := idx[fp]
+		if !ok {
+			continue
+		}
+		p := &r.Periods[j]
+		p.CapacityHours = hours
+		if p.MonthHours > 0 {
+			p.CapacityFte = hours / p.MonthHours
+		}
+	}
+	if err := capRows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+	return rollups, nil
+}
+
+// per employee breakdown of an IPT rollup
+func GetIptEmployeeRollups(ctx context.Context, db *sql.DB, iptId int64, startDate, endDate string) ([]EmployeeRollup, error) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+
+	var emps []EmployeeRollup
+
+	months, err := GetPlanMonths(ctx, db, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("error getting months: %v", err)
+	}
+	idx := periodIndex(months)
+
+	empQuery := `
+	SELECT id,display_name,labor_capacity
+	FROM Employee
+	WHERE ipt=?
+	ORDER BY display_name;
+	`
+
+	rows, err := db.QueryContext(ctx, empQuery, iptId)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %v", err)
+	}
+	defer rows.Close()
+
+	lookup := make(map[int64]int)
+	for rows.Next() {
+		var e EmployeeRollup
+		if err := rows.Scan(&e.EmpId, &e.EmpName, &e.LaborCapacity); err != nil {
+			return nil, fmt.Errorf("row scan error: %v", err)
+		}
+		e.Periods = newPeriods(months)
+		lookup[e.EmpId] = len(emps)
+		emps = append(emps, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	planQuery := `
+	SELECT pd.emp,ch.fiscal_period,
+	       sum(pd.planned_hours),
+	       sum(pd.planned_hours * IFNULL(c.hourly_rate,0))
+	FROM PlanDay pd
+	JOIN Employee e ON pd.emp=e.id
+	LEFT JOIN Compensation c ON e.comp=c.id
+	JOIN CalendarHours ch ON pd.cal_date=ch.cal_date
+	WHERE e.ipt=?
+	  AND pd.cal_date BETWEEN ? AND ?
+	GROUP BY pd.emp,ch.fiscal_period;
+	`
+
+	planRows, err := db.QueryContext(ctx, planQuery, iptId, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %v", err)
+	}
+	defer planRows.Close()
+
+	for planRows.Next() {
+		var empId int64
+		var fp string
+		var hours, cost float64
+		if err := planRows.Scan(&empId, &fp, &hours, &cost); err != nil {
+			return nil, fmt.Errorf("row scan error: %v", err)
+		}
+		i, ok := lookup[empId]
+		if !ok {
+			continue
+		}
+		j, ok := idx[fp]
+		if !ok {
+			continue
+		}
+		p := &emps[i].Periods[j]
+		p.PlanHours = hours
+		p.Cost = NewNullFloat64(cost)
+		if p.MonthHours > 0 {
+			p.Fte = hours / p.MonthHours
+		}
+	}
+	if err := planRows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+	return emps, nil
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FilterType int
+
+const (
+	FilterText FilterType = iota
+	FilterBool
+	FilterId       // nullable foreign key, the value "none" matches NULL
+	FilterDateFrom // YYYY-MM-DD, on or after the date
+	FilterDateTo   // YYYY-MM-DD, on or before the date
+	FilterContains // every word of the value appears in the column, any case
+)
+
+// LIKE wildcards in a FilterContains value match themselves
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+type FilterField struct {
+	Column string
+	Type   FilterType
+}
+
+// the query params an entity list accepts. Params are only ever mapped to
+// these column names so user input never ends up in the SQL text.
+type ListFields struct {
+	Filters     map[string]FilterField // param name -> column
+	Sorts       map[string][]string    // sort key -> ORDER BY columns
+	DefaultSort string
+}
+
+// WHERE, ORDER BY and LIMIT clauses for an entity list
+type ListQuery struct {
+	where   []string
+	args    []any
+	orderBy string
+	Sort    string
+	Desc    bool
+	Limit   int // 0 returns every row
+	Offset  int
+}
+
+// list query with the default sort and no filters
+func NewListQuery(fields ListFields) ListQuery {
+	q, _ := ParseListQuery(url.Values{}, fields)
+	return q
+}
+
+// builds a list query from the url params:
+//
+//	?active=true&ipt=2&sort=name&order=desc&limit=50&offset=100
+func ParseListQuery(params url.Values, fields ListFields) (ListQuery, error) {
+	q := ListQuery{}
+
+	for param, f := range fields.Filters {
+		if !params.Has(param) {
+			continue
+		}
+		v := params.Get(param)
+
+		switch f.Type {
+		case FilterBool:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return q, fmt.Errorf("invalid filter %s=%s: %v", param, v, err)
+			}
+			q.where = append(q.where, f.Column+"=?")
+			q.args = append(q.args, b)
+		case FilterId:
+			if v == "none" {
+				q.where = append(q.where, f.Column+" IS NULL")
+				continue
+			}
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return q, fmt.Errorf("invalid filter %s=%s: %v", param, v, err)
+			}
+			q.where = append(q.where, f.Column+"=?")
+			q.args = append(q.args, id)
+		case FilterDateFrom, FilterDateTo:
+			if _, err := time.Parse("2006-01-02", v); err != nil {
+				return q, fmt.Errorf("invalid filter %s=%s: expecting YYYY-MM-DD", param, v)
+			}
+			if f.Type == FilterDateFrom {
+				q.where = append(q.where, f.Column+">=?")
+			} else {
+				// through the end of the day, a timestamp sorts after its date
+				q.where = append(q.where, f.Column+"<date(?,'+1 day')")
+			}
+			q.args = append(q.args, v)
+		case FilterContains:
+			for _, word := range strings.Fields(v) {
+				q.where = append(q.where, f.Column+` LIKE '%'||?||'%' ESCAPE '\'`)
+				q.args = append(q.args, likeEscaper.Replace(word))
+			}
+		default:
+			q.where = append(q.where, f.Column+"=?")
+			q.args = append(q.args, v)
+		}
+	}
+
+	q.Sort = fields.DefaultSort
+	if params.Has("sort") {
+		q.Sort = params.Get("sort")
+	}
+	cols, ok := fields.Sorts[q.Sort]
+	if !ok {
+		return q, fmt.Errorf("invalid sort key: %s", q.Sort)
+	}
+
+	switch strings.ToLower(params.Get("order")) {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("invalid sort order: %s", params.Get("order"))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(" ORDER BY ")
+	for _, c := range append(cols, "id") {
+		sb.WriteString(c)
+		if q.Desc {
+			sb.WriteString(" DESC")
+		}
+		sb.WriteByte(',')
+	}
+	q.orderBy = strings.TrimSuffix(sb.String(), ",")
+
+	if params.Has("limit") {
+		l, err := strconv.Atoi(params.Get("limit"))
+		if err != nil || l < 0 {
+			return q, fmt.Errorf("invalid limit: %s", params.Get("limit"))
+		}
+		q.Limit = l
+	}
+	if params.Has("offset") {
+		o, err := strconv.Atoi(params.Get("offset"))
+		if err != nil || o < 0 {
+			return q, fmt.Errorf("invalid offset: %s", params.Get("offset"))
+		}
+		q.Offset = o
+	}
+	return q, nil
+}
+
+func (q ListQuery) Where() (string, []any) {
+	if len(q.where) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(q.where, " AND "), q.args
+}
+
+// ORDER BY and LIMIT/OFFSET clauses, appended after Where()
+func (q ListQuery) OrderLimit() string {
+	if q.Limit == 0 {
+		if q.Offset > 0 {
+			return q.orderBy + " LIMIT -1 OFFSET " + strconv.Itoa(q.Offset)
+		}
+		return q.orderBy
+	}
+	return q.orderBy + " LIMIT " + strconv.Itoa(q.Limit) + " OFFSET " + strconv.Itoa(q.Offset)
+}
+
+// number of rows in table that match the list filters (ignores limit/offset)
+func countRows(ctx context.Context, db *sql.DB, table string, q ListQuery) (int64, error) {
+	where, args := q.Where()
+
+	var n int64
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table+where+";", args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count query error: %v", err)
+	}
+	return n, nil
+}
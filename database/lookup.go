@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// the lookup of an entity: its dropdown label and what a lookup can filter
+// on. ?q= searches the label (and a few more columns), ?active=true leaves
+// out inactive, ended or completed rows and the id filters scope it, ex:
+// employees in an IPT or networks of a project.
+type lookup struct {
+	table  string
+	name   string // label expression
+	fields ListFields
+}
+
+// entities without an active filter have no inactive rows
+var lookups = map[string]lookup{
+	"employees": newLookup("Employee", "display_name", map[string]FilterField{
+		"q":       {Column: "IFNULL(display_name,'')||' '||IFNULL(first_name,'')||' '||IFNULL(last_name,'')||' '||myid", Type: FilterContains},
+		"active":  {Column: "active", Type: FilterBool},
+		"ipt":     {Column: "ipt", Type: FilterId},
+		"manager": {Column: "reports_to", Type: FilterId},
+	}),
+	"ipts": newLookup("Ipt", "name", map[string]FilterField{
+		"q": {Column: "name", Type: FilterContains},
+	}),
+	"projects": newLookup("Project", "wbs_id || ' - ' || title", map[string]FilterField{
+		"q":      {Column: "wbs_id||' '||IFNULL(title,'')", Type: FilterContains},
+		"active": {Column: "(IFNULL(end_date,'')='' OR end_date>=date('now'))", Type: FilterBool},
+		"parent": {Column: "parent_project", Type: FilterId},
+	}),
+	"networks": newLookup("Network", "charge_number || ' - ' || title", map[string]FilterField{
+		"q":       {Column: "charge_number||' '||IFNULL(title,'')", Type: FilterContains},
+		"active":  {Column: "(IFNULL(end_date,'')='' OR end_date>=date('now'))", Type: FilterBool},
+		"project": {Column: "proj", Type: FilterId},
+	}),
+	"material": newLookup("Material", "name", map[string]FilterField{
+		"q":       {Column: "name", Type: FilterContains},
+		"active":  {Column: "(NOT complete)", Type: FilterBool},
+		"project": {Column: "proj", Type: FilterId},
+	}),
+	"compensation": newLookup("Compensation", "grade || ' - ' || labor_category", map[string]FilterField{
+		"q": {Column: "IFNULL(grade,'')||' '||IFNULL(labor_category,'')||' '||IFNULL(resource_code,'')", Type: FilterContains},
+	}),
+	"planpages": newLookup("PlanPage", "title", map[string]FilterField{
+		"q": {Column: "title", Type: FilterContains},
+	}),
+	"calendars": newLookup("Calendar", "name", map[string]FilterField{
+		"q": {Column: "name", Type: FilterContains},
+	}),
+}
+
+// lookups are sorted by their label
+func newLookup(table, name string, filters map[string]FilterField) lookup {
+	return lookup{table: table, name: name, fields: ListFields{
+		Filters:     filters,
+		Sorts:       map[string][]string{"name": {name}},
+		DefaultSort: "name",
+	}}
+}
+
+// the lookup params of the entity, ex: employees
+func LookupFields(entity string) ListFields {
+	return lookups[entity].fields
+}
+
+// the entity's dropdown options matching the query
+func Lookup(ctx context.Context, db *sql.DB, entity string, q ListQuery) ([]Dropdown, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	l, ok := lookups[entity]
+	if !ok {
+		return nil, fmt.Errorf("lookup error: no such entity %q", entity)
+	}
+
+	where, args := q.Where()
+	query := "SELECT IFNULL(" + l.name + ",''),id FROM " + l.table + where + q.OrderLimit() + ";"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("lookup query error: %v", err)
+	}
+	defer rows.Close()
+
+	options := []Dropdown{}
+	for rows.Next() {
+		var o Dropdown
+		if err := rows.Scan(&o.Name, &o.Id); err != nil {
+			return nil, fmt.Errorf("lookup scan error: %v", err)
+		}
+		options = append(options, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+	return options, nil
+}
@@ -1,35 +1,56 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
 
 type Material struct {
-	Id                  int64         `json:"id,string"`
-	Name                string        `json:"name"`
-	EstimatedCost       float64       `json:"estimated_cost"`
-	ActualCost          float64       `json:"actual_cost"`
-	PRDate              string        `json:"pr_date"`
-	PODate              string        `json:"po_date"`
-	PRNumber            string        `json:"pr"`
-	PONumber            string        `json:"po"`
-	Complete            bool          `json:"complete"`
-	BaselineStartDate   string        `json:"baseline_start_date"`
-	BaselineFinishDate  string        `json:"baseline_finish_date"`
-	TentativeStartDate  string        `json:"tentative_start_date"`
-	TentativeFinishDate string        `json:"tentative_finish_date"`
-	ActualStartDate     string        `json:"actual_start_date"`
-	ActualFinishDate    string        `json:"actual_finish_date"`
-	Notes               string        `json:"notes"`
-	WorkPackage         sql.NullInt64 `json:"wp"`
-	WorkPackageName     string        `json:"wp_name"`
+	Id                  int64     `json:"id,string"`
+	Name                string    `json:"name"`
+	EstimatedCost       float64   `json:"estimated_cost"`
+	ActualCost          float64   `json:"actual_cost"`
+	PRDate              string    `json:"pr_date"`
+	PODate              string    `json:"po_date"`
+	PRNumber            string    `json:"pr"`
+	PONumber            string    `json:"po"`
+	Complete            bool      `json:"complete"`
+	BaselineStartDate   string    `json:"baseline_start_date"`
+	BaselineFinishDate  string    `json:"baseline_finish_date"`
+	TentativeStartDate  string    `json:"tentative_start_date"`
+	TentativeFinishDate string    `json:"tentative_finish_date"`
+	ActualStartDate     string    `json:"actual_start_date"`
+	ActualFinishDate    string    `json:"actual_finish_date"`
+	Notes               string    `json:"notes"`
+	WorkPackage         NullInt64 `json:"wp"`
+	WorkPackageName     string    `json:"wp_name"`
 }
 
 func NewMaterial() Material {
 	return Material{}
 }
 
+// costs aren't negative and each start/finish pair is in order
+func (m Material) Validate() error {
+	return validate(
+		required("name", m.Name),
+		notNegative("estimated_cost", m.EstimatedCost),
+		notNegative("actual_cost", m.ActualCost),
+		date("pr_date", m.PRDate),
+		date("po_date", m.PODate),
+		date("baseline_start_date", m.BaselineStartDate),
+		date("baseline_finish_date", m.BaselineFinishDate),
+		date("tentative_start_date", m.TentativeStartDate),
+		date("tentative_finish_date", m.TentativeFinishDate),
+		date("actual_start_date", m.ActualStartDate),
+		date("actual_finish_date", m.ActualFinishDate),
+		dateOrder("baseline_start_date", m.BaselineStartDate, "baseline_finish_date", m.BaselineFinishDate),
+		dateOrder("tentative_start_date", m.TentativeStartDate, "tentative_finish_date", m.TentativeFinishDate),
+		dateOrder("actual_start_date", m.ActualStartDate, "actual_finish_date", m.ActualFinishDate),
+	)
+}
+
 func (e Material) Init(db *sql.DB) error {
 	tableQuery := `
 	CREATE TABLE IF NOT EXISTS Material (
@@ -78,7 +99,10 @@ func MaterialDropdownQuery() string {
 	return "SELECT name,id FROM Material ORDER BY id;"
 }
 
-func GetMaterial(db *sql.DB, id int64) (Material, error) {
+func GetMaterial(ctx context.Context, db *sql.DB, id int64) (Material, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var mat Material
 
 	getQuery := `
@@ -91,32 +115,58 @@ func GetMaterial(db *sql.DB, id int64) (Material, error) {
 	WHERE id=?;
 	`
 
-	row := db.QueryRow(getQuery, id)
+	row := db.QueryRowContext(ctx, getQuery, id)
 	if err := row.Scan(&mat.Id, &mat.Name, &mat.EstimatedCost, &mat.ActualCost, &mat.PRDate, &mat.PODate, &mat.PRNumber, &mat.PONumber, &mat.Complete, &mat.BaselineStartDate, &mat.BaselineFinishDate, &mat.TentativeStartDate, &mat.TentativeFinishDate, &mat.ActualStartDate, &mat.ActualFinishDate, &mat.Notes, &mat.WorkPackage); err != nil {
 		if err == sql.ErrNoRows {
-			return mat, fmt.Errorf("material id=%d: no such row", id)
+			return mat, fmt.Errorf("material id=%d: no such row: %w", id, dbError(err))
 		}
 		return mat, fmt.Errorf("material: id=%d: %v", id, err)
 	}
 	return mat, nil
 }
 
-func AllMaterials(db *sql.DB) ([]Material, error) {
+func MaterialListFields() ListFields {
+	return ListFields{
+		Filters: map[string]FilterField{
+			"project":  {Column: "proj", Type: FilterId},
+			"complete": {Column: "complete", Type: FilterBool},
+		},
+		Sorts: map[string][]string{
+			"id":                    {"id"},
+			"name":                  {"name"},
+			"estimated_cost":        {"estimated_cost"},
+			"tentative_finish_date": {"tentative_finish_date", "name"},
+			"actual_finish_date":    {"actual_finish_date"},
+		},
+		DefaultSort: "tentative_finish_date",
+	}
+}
+
+func AllMaterials(ctx context.Context, db *sql.DB, q ListQuery) ([]Material, int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var mats []Material
 
+	total, err := countRows(ctx, db, "Material", q)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	getQuery := `
 	SELECT
 	    id,name,estimated_cost,actual_cost,pr_date,po_date,pr_number,
 		po_number,complete,baseline_start_date,baseline_finish_date,
 		tentative_start_date,tentative_finish_date,actual_start_date,
 		actual_finish_date,notes,proj
-	FROM Material
-	ORDER BY tentative_finish_date,name;
-	`
+	FROM Material`
+
+	where, args := q.Where()
+	getQuery += where + q.OrderLimit() + ";"
 
-	rows, err := db.Query(getQuery)
+	rows, err := db.QueryContext(ctx, getQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("query error: %v", err)
+		return nil, 0, fmt.Errorf("query error: %v", err)
 	}
 	defer rows.Close()
 
@@ -124,19 +174,22 @@ func AllMaterials(db *sql.DB) ([]Material, error) {
 		var mat Material
 		if err := rows.Scan(&mat.Id, &mat.Name, &mat.EstimatedCost, &mat.ActualCost, &mat.PRDate, &mat.PODate, &mat.PRNumber, &mat.PONumber, &mat.Complete, &mat.BaselineStartDate, &mat.BaselineFinishDate, &mat.TentativeStartDate, &mat.TentativeFinishDate, &mat.ActualStartDate, &mat.ActualFinishDate, &mat.Notes, &mat.WorkPackage); err != nil {
 			if err == sql.ErrNoRows {
-				return nil, fmt.Errorf("error: no rows")
+				return nil, 0, fmt.Errorf("error: no rows")
 			}
-			return nil, fmt.Errorf("row scan error: %v", err)
+			return nil, 0, fmt.Errorf("row scan error: %v", err)
 		}
 		mats = append(mats, mat)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %v", err)
+		return nil, 0, fmt.Errorf("rows error: %v", err)
 	}
-	return mats, nil
+	return mats, total, nil
 }
 
-func UpdateMaterial(db *sql.DB, mat Material) (int64, error) {
+func UpdateMaterial(ctx context.Context, db *sql.DB, mat Material) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	updateQuery := `
 	UPDATE Material SET
 	    name=?,estimated_cost=?,actual_cost=?,pr_date=?,po_date=?,pr_number=?,
@@ -146,9 +199,9 @@ func UpdateMaterial(db *sql.DB, mat Material) (int64, error) {
 	WHERE id=?;
 	`
 
-	result, err := db.Exec(updateQuery, mat.Name, mat.EstimatedCost, mat.ActualCost, mat.PRDate, mat.PODate, mat.PRNumber, mat.PONumber, mat.Complete, mat.BaselineStartDate, mat.BaselineFinishDate, mat.TentativeStartDate, mat.TentativeFinishDate, mat.ActualStartDate, mat.ActualFinishDate, mat.Notes, mat.WorkPackage, mat.Id)
+	result, err := execWrite(ctx, db, updateQuery, mat.Name, mat.EstimatedCost, mat.ActualCost, mat.PRDate, mat.PODate, mat.PRNumber, mat.PONumber, mat.Complete, mat.BaselineStartDate, mat.BaselineFinishDate, mat.TentativeStartDate, mat.TentativeFinishDate, mat.ActualStartDate, mat.ActualFinishDate, mat.Notes, mat.WorkPackage, mat.Id)
 	if err != nil {
-		return 0, fmt.Errorf("update query error: %v", err)
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -158,7 +211,10 @@ func UpdateMaterial(db *sql.DB, mat Material) (int64, error) {
 	return rows, nil
 }
 
-func InsertMaterial(db *sql.DB, mat Material) (int64, error) {
+func InsertMaterial(ctx context.Context, db *sql.DB, mat Material) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	insertQuery := `
 	INSERT INTO Material
 	  (name,estimated_cost,actual_cost,pr_date,po_date,pr_number,
@@ -169,14 +225,14 @@ func InsertMaterial(db *sql.DB, mat Material) (int64, error) {
 	  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 	`
 
-	result, err := db.Exec(insertQuery, mat.Name, mat.EstimatedCost, mat.ActualCost, mat.PRDate, mat.PODate, mat.PRNumber, mat.PONumber, mat.Complete, mat.BaselineStartDate, mat.BaselineFinishDate, mat.TentativeStartDate, mat.TentativeFinishDate, mat.ActualStartDate, mat.ActualFinishDate, mat.Notes, mat.WorkPackage)
+	result, err := execWrite(ctx, db, insertQuery, mat.Name, mat.EstimatedCost, mat.ActualCost, mat.PRDate, mat.PODate, mat.PRNumber, mat.PONumber, mat.Complete, mat.BaselineStartDate, mat.BaselineFinishDate, mat.TentativeStartDate, mat.TentativeFinishDate, mat.ActualStartDate, mat.ActualFinishDate, mat.Notes, mat.WorkPackage)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
 	}
 
-	rows, err := result.RowsAffected()
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("insert result error: %v", err)
 	}
-	return rows, nil
+	return id, nil
 }
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// a schema change from version-1 to version. Tables added by a migration
+// also go in InitDB's table list so new databases are created at the latest
+// version directly.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// kept in version order, the last one is SchemaVersion
+var migrations = []migration{
+	{2, "audit trail", func(tx *sql.Tx) error {
+		_, err := tx.Exec(auditTablesQuery)
+		return err
+	}},
+	{3, "recycle bin", func(tx *sql.Tx) error {
+		_, err := tx.Exec(recycleBinQuery)
+		return err
+	}},
+	{4, "plan journal", func(tx *sql.Tx) error {
+		_, err := tx.Exec(planJournalQuery)
+		return err
+	}},
+	{5, "plan row versions", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "PlanJournal", "version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(planRowVersionQuery)
+		return err
+	}},
+	{6, "sso users", func(tx *sql.Tx) error {
+		// older databases have no users yet, createTables adds the table
+		if ok, err := hasTable(tx, "User"); err != nil || !ok {
+			return err
+		}
+		if err := addColumn(tx, "User", "sso", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		// single sign-on created its users without a password
+		_, err := tx.Exec("UPDATE User SET sso=1 WHERE password_hash='' AND emp_id IS NOT NULL;")
+		return err
+	}},
+	{7, "plan row version ids", func(tx *sql.Tx) error {
+		// keyed by (emp,plan) before, the recycle bin keeps rows by id
+		var n int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info('PlanRowVersion') WHERE name='id';").Scan(&n); err != nil {
+			return fmt.Errorf("table info error: %v", err)
+		}
+		if n > 0 {
+			return nil
+		}
+		_, err := tx.Exec(`
+		ALTER TABLE PlanRowVersion RENAME TO PlanRowVersionOld;
+		` + planRowVersionQuery + `
+		INSERT INTO PlanRowVersion (emp,plan,version,updated_at,actor)
+		SELECT emp,plan,version,updated_at,actor FROM PlanRowVersionOld;
+		DROP TABLE PlanRowVersionOld;`)
+		return err
+	}},
+}
+
+// creates a new database or brings an existing one up to SchemaVersion.
+// Returns the versions before and after.
+func Migrate(db *sql.DB) (int, int, error) {
+	from, err := GetSchemaVersion(db)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if from == 0 {
+		var tables int
+		if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='Employee';").Scan(&tables); err != nil {
+			return 0, 0, fmt.Errorf("migrate error: %v", err)
+		}
+		if tables > 0 {
+			// created before the version was recorded
+			from = 1
+		}
+	}
+	if from > SchemaVersion {
+		return from, from, fmt.Errorf("migrate error: database schema version %d is newer than this build (%d)", from, SchemaVersion)
+	}
+	if err := dropSearchTriggers(db); err != nil {
+		return from, from, err
+	}
+
+	if from > 0 {
+		for _, m := range migrations {
+			if m.version <= from {
+				continue
+			}
+			if err := runMigration(db, m); err != nil {
+				return from, m.version - 1, err
+			}
+		}
+	}
+
+	if err := createTables(db); err != nil {
+		return from, from, err
+	}
+	if err := createAuditTriggers(db); err != nil {
+		return from, from, err
+	}
+	if err := createSearchIndex(db); err != nil {
+		return from, from, err
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version=%d;", SchemaVersion)); err != nil {
+		return from, from, fmt.Errorf("migrate error: %v", err)
+	}
+	return from, SchemaVersion, nil
+}
+
+func runMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("migration %d (%s) error: %v", m.version, m.name, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) error: %v", m.version, m.name, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version=%d;", m.version)); err != nil {
+		return fmt.Errorf("migration %d (%s) error: %v", m.version, m.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d (%s) commit error: %v", m.version, m.name, err)
+	}
+	return nil
+}
+
+func hasTable(tx *sql.Tx, table string) (bool, error) {
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?;", table).Scan(&n); err != nil {
+		return false, fmt.Errorf("table info error: %v", err)
+	}
+	return n > 0, nil
+}
+
+// ALTER TABLE ADD COLUMN unless the table has it, ex: created by an earlier
+// migration from the current CREATE TABLE
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?;", table, column).Scan(&n); err != nil {
+		return fmt.Errorf("table info error: %v", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition)); err != nil {
+		return fmt.Errorf("add column %s.%s error: %v", table, column, err)
+	}
+	return nil
+}
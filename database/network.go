@@ -1,26 +1,38 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
 
 type Network struct {
-	Id           int64         `json:"id,string"`
-	ChargeNumber string        `json:"charge_num"`
-	Title        string        `json:"title"`
-	Description  string        `json:"description"`
-	Status       string        `json:"status"` // ex: 20 - Labor Only
-	StartDate    string        `json:"start_date"`
-	EndDate      string        `json:"end_date"`
-	Proj         sql.NullInt64 `json:"proj"`
-	ProjName     string        `json:"proj_name"`
+	Id           int64     `json:"id,string"`
+	ChargeNumber string    `json:"charge_num"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	Status       string    `json:"status"` // ex: 20 - Labor Only
+	StartDate    string    `json:"start_date"`
+	EndDate      string    `json:"end_date"`
+	Proj         NullInt64 `json:"proj"`
+	ProjName     string    `json:"proj_name"`
 }
 
 func NewNetwork() Network {
 	return Network{}
 }
 
+// a well formed charge number and dates in order
+func (n Network) Validate() error {
+	return validate(
+		required("charge_num", n.ChargeNumber),
+		chargeNumber("charge_num", n.ChargeNumber),
+		date("start_date", n.StartDate),
+		date("end_date", n.EndDate),
+		dateOrder("start_date", n.StartDate, "end_date", n.EndDate),
+	)
+}
+
 func (n Network) Init(db *sql.DB) error {
 	tableQuery := `
 	CREATE TABLE IF NOT EXISTS Network (
@@ -62,7 +74,10 @@ func NetworkImportColumns() []string {
 		"start_date", "end_date"}
 }
 
-func GetNetwork(db *sql.DB, id int64) (Network, error) {
+func GetNetwork(ctx context.Context, db *sql.DB, id int64) (Network, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var net Network
 
 	getQuery := `
@@ -72,10 +87,10 @@ func GetNetwork(db *sql.DB, id int64) (Network, error) {
 	WHERE id=?;
 	`
 
-	row := db.QueryRow(getQuery, id)
+	row := db.QueryRowContext(ctx, getQuery, id)
 	if err := row.Scan(&net.Id, &net.ChargeNumber, &net.Title, &net.Description, &net.Status, &net.StartDate, &net.EndDate, &net.Proj); err != nil {
 		if err == sql.ErrNoRows {
-			return net, fmt.Errorf("network id=%d: no such row", id)
+			return net, fmt.Errorf("network id=%d: no such row: %w", id, dbError(err))
 		}
 		return net, fmt.Errorf("network: id=%d: %v", id, err)
 	}
@@ -83,19 +98,46 @@ func GetNetwork(db *sql.DB, id int64) (Network, error) {
 	return net, nil
 }
 
-func AllNetworks(db *sql.DB) ([]Network, error) {
+func NetworkListFields() ListFields {
+	return ListFields{
+		Filters: map[string]FilterField{
+			"project": {Column: "proj", Type: FilterId},
+			"status":  {Column: "status", Type: FilterText},
+		},
+		Sorts: map[string][]string{
+			"id":         {"id"},
+			"charge_num": {"charge_number"},
+			"title":      {"title"},
+			"status":     {"status"},
+			"start_date": {"start_date"},
+			"end_date":   {"end_date"},
+		},
+		DefaultSort: "charge_num",
+	}
+}
+
+func AllNetworks(ctx context.Context, db *sql.DB, q ListQuery) ([]Network, int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var nets []Network
 
+	total, err := countRows(ctx, db, "Network", q)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	getQuery := `
 	SELECT
 	  id,charge_number,title,description,status,start_date,end_date,proj
-	FROM Network
-	ORDER BY charge_number;
-	`
+	FROM Network`
+
+	where, args := q.Where()
+	getQuery += where + q.OrderLimit() + ";"
 
-	rows, err := db.Query(getQuery)
+	rows, err := db.QueryContext(ctx, getQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("query error: %v", err)
+		return nil, 0, fmt.Errorf("query error: %v", err)
 	}
 	defer rows.Close()
 
@@ -103,20 +145,23 @@ func AllNetworks(db *sql.DB) ([]Network, error) {
 		var net Network
 		if err := rows.Scan(&net.Id, &net.ChargeNumber, &net.Title, &net.Description, &net.Status, &net.StartDate, &net.EndDate, &net.Proj); err != nil {
 			if err == sql.ErrNoRows {
-				return nil, fmt.Errorf("error: no rows")
+				return nil, 0, fmt.Errorf("error: no rows")
 			}
-			return nil, fmt.Errorf("row scan error: %v", err)
+			return nil, 0, fmt.Errorf("row scan error: %v", err)
 		}
 
 		nets = append(nets, net)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %v", err)
+		return nil, 0, fmt.Errorf("rows error: %v", err)
 	}
-	return nets, nil
+	return nets, total, nil
 }
 
-func UpdateNetwork(db *sql.DB, net Network) (int64, error) {
+func UpdateNetwork(ctx context.Context, db *sql.DB, net Network) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	updateQuery := `
 	UPDATE Network SET
 	  charge_number=?,title=?,description=?,status=?,start_date=?,
@@ -124,9 +169,9 @@ func UpdateNetwork(db *sql.DB, net Network) (int64, error) {
 	WHERE id=?;
 	`
 
-	result, err := db.Exec(updateQuery, net.ChargeNumber, net.Title, net.Description, net.Status, net.StartDate, net.EndDate, net.Proj, net.Id)
+	result, err := execWrite(ctx, db, updateQuery, net.ChargeNumber, net.Title, net.Description, net.Status, net.StartDate, net.EndDate, net.Proj, net.Id)
 	if err != nil {
-		return 0, fmt.Errorf("update query error: %v", err)
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -136,7 +181,10 @@ func UpdateNetwork(db *sql.DB, net Network) (int64, error) {
 	return rows, nil
 }
 
-func InsertNetwork(db *sql.DB, net Network) (int64, error) {
+func InsertNetwork(ctx context.Context, db *sql.DB, net Network) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	insertQuery := `
 	INSERT INTO Network
 	  (charge_number,title,description,status,start_date,end_date,proj)
@@ -144,14 +192,14 @@ func InsertNetwork(db *sql.DB, net Network) (int64, error) {
 	  (?, ?, ?, ?, ?, ?, ?);
 	`
 
-	result, err := db.Exec(insertQuery, net.ChargeNumber, net.Title, net.Description, net.Status, net.StartDate, net.EndDate, net.Proj)
+	result, err := execWrite(ctx, db, insertQuery, net.ChargeNumber, net.Title, net.Description, net.Status, net.StartDate, net.EndDate, net.Proj)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
 	}
 
-	rows, err := result.RowsAffected()
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("insert result error: %v", err)
 	}
-	return rows, nil
+	return id, nil
 }
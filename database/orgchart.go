@@ -0,0 +1,321 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// A node in the reporting tree built from Employee.reports_to
+type OrgNode struct {
+	Id            int64      `json:"id,string"`
+	DisplayName   string     `json:"display_name"`
+	Active        bool       `json:"active"`
+	SpanOfControl int        `json:"span_of_control"` // number of direct reports
+	OrgSize       int        `json:"org_size"`        // everyone below this node
+	Reports       []*OrgNode `json:"reports"`
+}
+
+type OrgChart struct {
+	Roots  []*OrgNode `json:"roots"`
+	Cycles [][]int64  `json:"cycles"` // employee ids that report to each other in a loop
+}
+
+func NewOrgChart() OrgChart {
+	return OrgChart{Roots: []*OrgNode{}, Cycles: [][]int64{}}
+}
+
+// builds the reporting tree for every employee. Employees that are part of a
+// reporting cycle are listed in Cycles and the lowest id in each cycle is
+// promoted to a root so the rest of the tree can still be displayed.
+func GetOrgChart(ctx context.Context, db *sql.DB) (OrgChart, error) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+
+	chart := NewOrgChart()
+
+	getQuery := `
+	SELECT id,display_name,active,reports_to
+	FROM Employee
+	ORDER BY display_name;
+	`
+
+	rows, err := db.QueryContext(ctx, getQuery)
+	if err != nil {
+		return chart, fmt.Errorf("query error: %v", err)
+	}
+	defer rows.Close()
+
+	var order []int64
+	nodes := make(map[int64]*OrgNode)
+	managers := make(map[int64]int64)
+	for rows.Next() {
+		n := OrgNode{Reports: []*OrgNode{}}
+		var manager sql.NullInt64
+		if err := rows.Scan(&n.Id, &n.DisplayName, &n.Active, &manager); err != nil {
+			return chart, fmt.Errorf("row scan error: %v", err)
+		}
+		nodes[n.Id] = &n
+		order = append(order, n.Id)
+		if manager.Valid {
+			managers[n.Id] = manager.Int64
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return chart, fmt.Errorf("rows error: %v", err)
+	}
+
+	// span of control counts every direct report, including cut cycle edges
+	for _, m := range managers {
+		if p, ok := nodes[m]; ok {
+			p.SpanOfControl++
+		}
+	}
+
+	chart.Cycles = findCycles(order, managers)
+	for _, c := range chart.Cycles {
+		// c is sorted, cut the edge from the lowest id to its manager
+		delete(managers, c[0])
+	}
+
+	for _, id := range order {
+		n := nodes[id]
+		m, ok := managers[id]
+		if ok {
+			if p, found := nodes[m]; found {
+				p.Reports = append(p.Reports, n)
+				continue
+			}
+		}
+		chart.Roots = append(chart.Roots, n)
+	}
+
+	for _, r := range chart.Roots {
+		countOrg(r)
+	}
+	return chart, nil
+}
+
+// returns the number of employees below n and stores it in OrgSize
+func countOrg(n *OrgNode) int {
+	size := 0
+	for _, r := range n.Reports {
+		size += 1 + countOrg(r)
+	}
+	n.OrgSize = size
+	return size
+}
+
+// follows each reporting chain upwards and records every loop it finds.
+// each cycle is returned sorted by employee id.
+func findCycles(order []int64, managers map[int64]int64) [][]int64 {
+	cycles := [][]int64{}
+
+	const (
+		unvisited = iota
+		inPath
+		done
+	)
+	state := make(map[int64]int)
+
+	for _, start := range order {
+		if state[start] != unvisited {
+			continue
+		}
+
+		var path []int64
+		id := start
+		for {
+			if state[id] == done {
+				break
+			}
+			if state[id] == inPath {
+				// everything in the path from the first visit of id is a loop
+				var c []int64
+				for i := len(path) - 1; i >= 0; i-- {
+					c = append(c, path[i])
+					if path[i] == id {
+						break
+					}
+				}
+				sort.Slice(c, func(i, j int) bool { return c[i] < c[j] })
+				cycles = append(cycles, c)
+				break
+			}
+			state[id] = inPath
+			path = append(path, id)
+
+			m, ok := managers[id]
+			if !ok {
+				break
+			}
+			id = m
+		}
+
+		for _, p := range path {
+			state[p] = done
+		}
+	}
+	return cycles
+}
+
+// returns an error if making managerId the manager of empId would create a
+// reporting cycle
+func CheckReportsTo(ctx context.Context, db *sql.DB, empId, managerId int64) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	if empId == managerId {
+		return Invalidf("employee id=%d cannot report to themselves", empId)
+	}
+
+	checkQuery := `
+	WITH RECURSIVE chain(id) AS (
+	  SELECT reports_to FROM Employee WHERE id=?
+	  UNION
+	  SELECT e.reports_to FROM Employee e JOIN chain c ON e.id=c.id
+	)
+	SELECT COUNT(*) FROM chain WHERE id=?;
+	`
+
+	var n int
+	if err := db.QueryRowContext(ctx, checkQuery, managerId, empId).Scan(&n); err != nil {
+		return fmt.Errorf("reporting chain query error: %v", err)
+	}
+	if n > 0 {
+		return Invalidf("employee id=%d reports up to id=%d: manager change would create a cycle", managerId, empId)
+	}
+	return nil
+}
+
+// planned hours, FTE and cost for a fiscal period
+type PeriodRollup struct {
+	FiscalPeriod string      `json:"fiscal_period"`
+	DisplayName  string      `json:"display_name"`
+	PlanHours    float64     `json:"plan_hours"`
+	MonthHours   float64     `json:"month_hours"`
+	Fte          float64     `json:"fte"`
+	Cost         NullFloat64 `json:"cost"`
+}
+
+type ManagerRollup struct {
+	ManagerId   int64          `json:"manager_id,string"`
+	ManagerName string         `json:"manager_name"`
+	OrgSize     int            `json:"org_size"`
+	Periods     []PeriodRollup `json:"periods"`
+}
+
+// sums the plan hours for the manager and everyone that reports up to them
+// for each fiscal period between startDate and endDate. Periods without
+// plan hours are zero.
+func GetManagerRollup(ctx context.Context, db *sql.DB, managerId int64, startDate, endDate string) (ManagerRollup, error) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+
+	m := ManagerRollup{ManagerId: managerId, Periods: []PeriodRollup{}}
+
+	nameQuery := `
+	WITH RECURSIVE org(id) AS (
+	  SELECT id FROM Employee WHERE id=?
+	  UNION
+	  SELECT e.id FROM Employee e JOIN org o ON e.reports_to=o.id
+	)
+	SELECT e.display_name,(SELECT COUNT(*) - 1 FROM org)
+	FROM Employee e
+	WHERE e.id=?;
+	`
+
+	row := db.QueryRowContext(ctx, nameQuery, managerId, managerId)
+	if err := row.Scan(&m.ManagerName, &m.OrgSize); err != nil {
+		if err == sql.ErrNoRows {
+			return m, fmt.Errorf("manager id=%d: no such row: %w", managerId, dbError(err))
+		}
+		return m, fmt.Errorf("manager: id=%d: %v", managerId, err)
+	}
+
+	months, err := GetPlanMonths(ctx, db, startDate, endDate)
+	if err != nil {
+		return m, fmt.Errorf("error getting months: %v", err)
+	}
+	m.Periods = newPeriods(months)
+	idx := periodIndex(months)
+
+	rollupQuery := `
+	WITH RECURSIVE org(id) AS (
+	  SELECT id FROM Employee WHERE id=?
+	  UNION
+	  SELECT e.id FROM Employee e JOIN org o ON e.reports_to=o.id
+	)
+	SELECT ch.fiscal_period,
+	       sum(pd.planned_hours),sum(pd.planned_hours * IFNULL(c.hourly_rate,0))
+	FROM PlanDay pd
+	JOIN org o ON pd.emp=o.id
+	JOIN Employee e ON pd.emp=e.id
+	LEFT JOIN Compensation c ON e.comp=c.id
+	JOIN CalendarHours ch ON pd.cal_date=ch.cal_date
+	WHERE pd.cal_date BETWEEN ? AND ?
+	GROUP BY ch.fiscal_period;
+	`
+
+	rows, err := db.QueryContext(ctx, rollupQuery, managerId, startDate, endDate)
+	if err != nil {
+		return m, fmt.Errorf("query error: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fp string
+		var hours, cost float64
+		if err := rows.Scan(&fp, &hours, &cost); err != nil {
+			return m, fmt.Errorf("row scan error: %v", err)
+		}
+		j, ok := idx[fp]
+		if !ok {
+			continue
+		}
+		p := &m.Periods[j]
+		p.PlanHours = hours
+		p.Cost = NewNullFloat64(cost)
+		if p.MonthHours > 0 {
+			p.Fte = hours / p.MonthHours
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return m, fmt.Errorf("rows error: %v", err)
+	}
+	return m, nil
+}
+
+// ids of every employee that has at least one direct report
+func AllManagers(ctx context.Context, db *sql.DB) ([]int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var ids []int64
+
+	getQuery := `
+	SELECT DISTINCT m.id
+	FROM Employee e
+	JOIN Employee m ON e.reports_to=m.id
+	ORDER BY m.display_name;
+	`
+
+	rows, err := db.QueryContext(ctx, getQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("row scan error: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+	return ids, nil
+}
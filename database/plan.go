@@ -1,15 +1,17 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
 
 type Plan struct {
-	Id        int64  `json:"id,string"`
-	Name      string `json:"name"`
-	StartDate string `json:"start_date"`
-	EndDate   string `json:"end_date"`
+	Id        int64     `json:"id,string"`
+	Name      string    `json:"name"`
+	StartDate string    `json:"start_date"`
+	EndDate   string    `json:"end_date"`
+	Page      NullInt64 `json:"page"` // the plan page showing it
 }
 
 func NewPlan() Plan {
@@ -51,34 +53,55 @@ func PlanDropdownQuery() string {
 	return "SELECT name,id FROM Plan ORDER BY name;"
 }
 
-func GetPlan(db *sql.DB, planId int64) (Plan, error) {
+func GetPlan(ctx context.Context, db *sql.DB, planId int64) (Plan, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var t Plan
 
 	getQuery := `
 	SELECT id,name,start_date,end_date FROM Plan WHERE plan=?;
 	`
 
-	row := db.QueryRow(getQuery, planId)
+	row := db.QueryRowContext(ctx, getQuery, planId)
 	if err := row.Scan(&t.Id, &t.Name, &t.StartDate, &t.EndDate); err != nil {
 		if err == sql.ErrNoRows {
-			return t, fmt.Errorf("plan table id=%d: no such row", planId)
+			return t, fmt.Errorf("plan table id=%d: no such row: %w", planId, dbError(err))
 		}
 		return t, fmt.Errorf("plan table: id=%d: %v", planId, err)
 	}
 	return t, nil
 }
 
-func InsertPlan(db *sql.DB, t Plan) (int64, error) {
+// the plan page a plan table is on, false for tables without a page
+func PlanPageOf(ctx context.Context, db *sql.DB, planId int64) (int64, bool, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var page NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT plan FROM Plan WHERE id=?;", planId).Scan(&page); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("plan page query error: %v", err)
+	}
+	return page.Int64, page.Valid, nil
+}
+
+func InsertPlan(ctx context.Context, db *sql.DB, t Plan) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	insertQuery := `
 	INSERT INTO Plan
-	  (name,start_date,end_date)
+	  (name,start_date,end_date,plan)
 	VALUES
-	  (?, ?, ?);
+	  (?, ?, ?, ?);
 	`
 
-	result, err := db.Exec(insertQuery, t.Name, t.StartDate, t.EndDate)
+	result, err := execWrite(ctx, db, insertQuery, t.Name, t.StartDate, t.EndDate, t.Page)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
 	}
 
 	id, err := result.LastInsertId()
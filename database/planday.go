@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -52,8 +54,12 @@ func (p PlanDay) Init(db *sql.DB) error {
 	return nil
 }
 
-func InitPlanRow(db *sql.DB, empId, planId int64, startDate, endDate string) (int64, error) {
-	dates, err := GetDateList(db, startDate, endDate)
+// adds the row's days between the dates. Returns the row's new version.
+func InitPlanRow(ctx context.Context, db *sql.DB, empId, planId int64, startDate, endDate string) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	dates, err := GetDateList(ctx, db, startDate, endDate)
 	if err != nil {
 		return 0, fmt.Errorf("date list error: %v", err)
 	}
@@ -94,20 +100,39 @@ func InitPlanRow(db *sql.DB, empId, planId int64, startDate, endDate string) (in
 
 	insertQuery := sb.String()
 
-	result, err := db.Exec(insertQuery)
+	tx, err := beginWrite(ctx, db)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("database transaction error: %v", err)
 	}
+	defer tx.Rollback()
 
-	rows, err := result.RowsAffected()
+	if _, err := tx.ExecContext(ctx, insertQuery); err != nil {
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
+	}
+
+	version, err := bumpPlanRowVersion(ctx, tx, empId, planId)
 	if err != nil {
-		return 0, fmt.Errorf("insert result error: %v", err)
+		return 0, err
+	}
+	after := make([]journalDay, len(dates))
+	for i, d := range dates {
+		after[i] = journalDay{CalDate: d}
 	}
-	return rows, nil
+	if err := journalPlanRow(ctx, tx, JournalNew, empId, planId, version, nil, after); err != nil {
+		return 0, err
+	}
+
+	if err := commitWrite(ctx, tx); err != nil {
+		return 0, fmt.Errorf("commit transaction error: %v", err)
+	}
+	return version, nil
 }
 
 // generates a list of ISO formatted dates from start to end inclusive
-func GetDateList(db *sql.DB, startDate, endDate string) ([]string, error) {
+func GetDateList(ctx context.Context, db *sql.DB, startDate, endDate string) ([]string, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	dates := make([]string, 0)
 
 	q := `
@@ -116,7 +141,7 @@ func GetDateList(db *sql.DB, startDate, endDate string) ([]string, error) {
 	WHERE cal_date BETWEEN ? AND ?;
 	`
 
-	rows, err := db.Query(q, startDate, endDate)
+	rows, err := db.QueryContext(ctx, q, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -147,7 +172,10 @@ type TableRow struct {
 	Months    []PlanMonth
 }
 
-func GetPlanRows(db *sql.DB, empId, planId []int64, startDate, endDate string) ([]TableRow, error) {
+func GetPlanRows(ctx context.Context, db *sql.DB, empId, planId []int64, startDate, endDate string) ([]TableRow, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var data []TableRow
 	var sb strings.Builder
 
@@ -191,7 +219,7 @@ func GetPlanRows(db *sql.DB, empId, planId []int64, startDate, endDate string) (
 
 	getQuery := sb.String()
 
-	rows, err := db.Query(getQuery)
+	rows, err := db.QueryContext(ctx, getQuery)
 	if err != nil {
 		return data, fmt.Errorf("query error: %v", err)
 	}
@@ -206,7 +234,7 @@ func GetPlanRows(db *sql.DB, empId, planId []int64, startDate, endDate string) (
 			return data, fmt.Errorf("row scan error: %v", err)
 		}
 
-		months, err := GetPlanMonths(db, startDate, endDate)
+		months, err := GetPlanMonths(ctx, db, startDate, endDate)
 		if err != nil {
 			return data, fmt.Errorf("error getting months: %v", err)
 		}
@@ -220,7 +248,10 @@ func GetPlanRows(db *sql.DB, empId, planId []int64, startDate, endDate string) (
 	return data, nil
 }
 
-func GetPlanHours(db *sql.DB, empId, planId int64, startDate, endDate string) ([]float64, error) {
+func GetPlanHours(ctx context.Context, db *sql.DB, empId, planId int64, startDate, endDate string) ([]float64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var h []float64
 
 	getQuery := `
@@ -232,7 +263,7 @@ func GetPlanHours(db *sql.DB, empId, planId int64, startDate, endDate string) ([
 	WHERE c.cal_date BETWEEN ? AND ?;
 	`
 
-	rows, err := db.Query(getQuery, empId, planId, startDate, endDate)
+	rows, err := db.QueryContext(ctx, getQuery, empId, planId, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -254,29 +285,79 @@ func GetPlanHours(db *sql.DB, empId, planId int64, startDate, endDate string) ([
 	return h, nil
 }
 
-func UpdatePlanRow(db *sql.DB, empId, planId int64, rows []PlanDay) error {
-	tx, err := db.Begin()
+// writes the hours if the row is still at version, the version it was read
+// at. Returns the new version, a *PlanRowConflict when the row changed since
+// and ErrNotFound for a row that doesn't exist.
+func UpdatePlanRow(ctx context.Context, db *sql.DB, empId, planId, version int64, rows []PlanDay) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := beginWrite(ctx, db)
 	if err != nil {
-		return fmt.Errorf("database transaction error: %v", err)
+		return 0, fmt.Errorf("database transaction error: %v", err)
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare("UPDATE PlanDay SET updated_at=CURRENT_DATE, planned_hours=?, description=? WHERE cal_date=? AND emp=? AND plan=?;")
+	days, err := planRowDays(ctx, tx, empId, planId)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if len(days) == 0 {
+		return 0, &Error{Kind: ErrNotFound, Err: fmt.Errorf("plan row emp=%d plan=%d: no such row", empId, planId)}
+	}
+	var outside []string
+	for _, v := range rows {
+		if _, ok := days[v.CalDate]; !ok {
+			outside = append(outside, v.CalDate)
+		}
+	}
+	if len(outside) > 0 {
+		sort.Strings(outside)
+		return 0, Invalidf("dates not on the plan row: %s", strings.Join(outside, ", "))
 	}
-	defer stmt.Close()
 
+	var current int64
+	err = tx.QueryRowContext(ctx, "SELECT version FROM PlanRowVersion WHERE emp=? AND plan=?;", empId, planId).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("plan row version error: %v", err)
+	}
+	if current != version {
+		conflict, err := planRowConflict(ctx, tx, empId, planId, version, days, rows)
+		if err != nil {
+			return 0, err
+		}
+		return 0, conflict
+	}
+
+	// only the days that change are written and journaled
+	var before, after []journalDay
 	for _, v := range rows {
-		if _, err := stmt.Exec(v.PlanHours, v.Description, v.CalDate, empId, planId); err != nil {
-			return fmt.Errorf("stmt exec error: %v", err)
+		old := days[v.CalDate]
+		if old.Hours == v.PlanHours && old.Description == v.Description {
+			continue
 		}
+		before = append(before, old)
+		after = append(after, journalDay{CalDate: v.CalDate, Hours: v.PlanHours, Description: v.Description})
+	}
+	if len(after) == 0 {
+		return version, nil
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit transaction error: %v", err)
+	if err := updatePlanDays(ctx, tx, empId, planId, after); err != nil {
+		return 0, err
 	}
-	return nil
+	version, err = bumpPlanRowVersion(ctx, tx, empId, planId)
+	if err != nil {
+		return 0, err
+	}
+	if err := journalPlanRow(ctx, tx, JournalUpdate, empId, planId, version, before, after); err != nil {
+		return 0, err
+	}
+
+	if err := commitWrite(ctx, tx); err != nil {
+		return 0, fmt.Errorf("commit transaction error: %v", err)
+	}
+	return version, nil
 }
 
 type PlanMonth struct {
@@ -288,7 +369,10 @@ type PlanMonth struct {
 }
 
 // the column name format is MMM-YYYY (ex: Oct-2024)
-func GetPlanMonths(db *sql.DB, startDate, endDate string) ([]PlanMonth, error) {
+func GetPlanMonths(ctx context.Context, db *sql.DB, startDate, endDate string) ([]PlanMonth, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var months []PlanMonth
 
 	q := `
@@ -302,7 +386,7 @@ func GetPlanMonths(db *sql.DB, startDate, endDate string) ([]PlanMonth, error) {
 	GROUP BY fiscal_period;
 	`
 
-	rows, err := db.Query(q, startDate, endDate)
+	rows, err := db.QueryContext(ctx, q, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %v", err)
 	}
@@ -348,21 +432,123 @@ func FormatDate(fy, fm int) string {
 	return monthLookup[fm] + "-" + strconv.Itoa(fy)
 }
 
-func DeletePlanRow(db *sql.DB, empId, planId int64) (int64, error) {
-	deleteQuery := `
-	DELETE FROM PlanDay
-	WHERE emp=?
-	  AND plan=?;
+// removes the row's days. Returns the row's new version, 0 when it had none.
+func DeletePlanRow(ctx context.Context, db *sql.DB, empId, planId int64) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := beginWrite(ctx, db)
+	if err != nil {
+		return 0, fmt.Errorf("database transaction error: %v", err)
+	}
+	defer tx.Rollback()
+
+	current, err := planRowDays(ctx, tx, empId, planId)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := deletePlanDays(ctx, tx, empId, planId)
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 0, nil
+	}
+
+	before := make([]journalDay, 0, len(current))
+	for _, d := range current {
+		before = append(before, d)
+	}
+	sort.Slice(before, func(i, j int) bool { return before[i].CalDate < before[j].CalDate })
+	version, err := bumpPlanRowVersion(ctx, tx, empId, planId)
+	if err != nil {
+		return 0, err
+	}
+	if err := journalPlanRow(ctx, tx, JournalDelete, empId, planId, version, before, nil); err != nil {
+		return 0, err
+	}
+
+	if err := commitWrite(ctx, tx); err != nil {
+		return 0, fmt.Errorf("commit transaction error: %v", err)
+	}
+	return version, nil
+}
+
+type PlanRowCount struct {
+	PageId int64
+	Rows   int64
+}
+
+// number of plan rows (employee and plan pairs) on each plan page
+func PlanRowCounts(ctx context.Context, db *sql.DB) ([]PlanRowCount, error) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+
+	countQuery := `
+	SELECT pp.id,COUNT(DISTINCT pd.emp || ':' || pd.plan)
+	FROM PlanPage pp
+	LEFT JOIN Plan p ON p.plan=pp.id
+	LEFT JOIN PlanDay pd ON pd.plan=p.id
+	GROUP BY pp.id
+	ORDER BY pp.id;
 	`
 
-	result, err := db.Exec(deleteQuery, empId, planId)
+	rows, err := db.QueryContext(ctx, countQuery)
 	if err != nil {
-		return 0, fmt.Errorf("delete query exec error: %v", err)
+		return nil, fmt.Errorf("plan row count query error: %v", err)
 	}
+	defer rows.Close()
+
+	var counts []PlanRowCount
+	for rows.Next() {
+		var c PlanRowCount
+		if err := rows.Scan(&c.PageId, &c.Rows); err != nil {
+			return nil, fmt.Errorf("plan row count scan error: %v", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+type PlanExportRow struct {
+	Plan        string
+	Myid        string
+	DisplayName string
+	CalDate     string
+	PlanHours   float64
+	Description string
+}
+
+// the planned hours of every plan row on a plan page, days without hours
+// are left out
+func ExportPlanPage(ctx context.Context, db *sql.DB, pageId int64) ([]PlanExportRow, error) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
 
-	rows, err := result.RowsAffected()
+	exportQuery := `
+	SELECT p.name,e.myid,e.display_name,pd.cal_date,pd.planned_hours,pd.description
+	FROM PlanDay pd
+	INNER JOIN Plan p ON p.id=pd.plan
+	INNER JOIN Employee e ON e.id=pd.emp
+	WHERE p.plan=?
+	  AND pd.planned_hours != 0
+	ORDER BY p.name,e.display_name,pd.cal_date;
+	`
+
+	rows, err := db.QueryContext(ctx, exportQuery, pageId)
 	if err != nil {
-		return 0, fmt.Errorf("delete query result error: %v", err)
+		return nil, fmt.Errorf("plan export query error: %v", err)
+	}
+	defer rows.Close()
+
+	var export []PlanExportRow
+	for rows.Next() {
+		var r PlanExportRow
+		if err := rows.Scan(&r.Plan, &r.Myid, &r.DisplayName, &r.CalDate, &r.PlanHours, &r.Description); err != nil {
+			return nil, fmt.Errorf("plan export scan error: %v", err)
+		}
+		export = append(export, r)
 	}
-	return rows, nil
+	return export, rows.Err()
 }
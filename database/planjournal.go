@@ -0,0 +1,332 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// every plan row change (new row, hours update, row delete) on a plan page is
+// journaled with what it takes to reverse and replay it, so undo and redo
+// work across page reloads and for everyone editing the page
+const planJournalQuery = `
+CREATE TABLE IF NOT EXISTS PlanJournal (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	page INTEGER NOT NULL,
+	created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+	actor TEXT NOT NULL DEFAULT '',
+	op TEXT NOT NULL, -- new, update or delete
+	emp INTEGER NOT NULL,
+	plan INTEGER NOT NULL,
+	before TEXT NOT NULL DEFAULT '[]', -- json list of journalDay
+	after TEXT NOT NULL DEFAULT '[]',
+	undone BOOLEAN NOT NULL DEFAULT FALSE,
+	version INTEGER NOT NULL DEFAULT 0, -- plan row version after it was (un)done
+	FOREIGN KEY (page) REFERENCES PlanPage(id)
+		ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_journal_page ON PlanJournal(page,id);
+`
+
+// entries kept per plan page, the oldest are dropped first
+const PlanJournalLimit = 200
+
+const (
+	JournalNew    = "new"
+	JournalUpdate = "update"
+	JournalDelete = "delete"
+)
+
+var (
+	ErrNothingToUndo = kindError(ErrConflict, "nothing to undo")
+	ErrNothingToRedo = kindError(ErrConflict, "nothing to redo")
+	ErrJournalReplay = kindError(ErrConflict, "plan row changed since")
+)
+
+type PlanJournal struct{}
+
+func (j PlanJournal) Init(db *sql.DB) error {
+	if _, err := db.Exec(planJournalQuery); err != nil {
+		return fmt.Errorf("error executing CREATE TABLE: %v", err)
+	}
+	return nil
+}
+
+type journalDay struct {
+	CalDate     string  `json:"cal_date"`
+	Hours       float64 `json:"hours"`
+	Description string  `json:"description"`
+}
+
+type JournalEntry struct {
+	Id        int64  `json:"id,string"`
+	PageId    int64  `json:"page_id,string"`
+	CreatedAt string `json:"created_at"`
+	Actor     string `json:"actor"`
+	Op        string `json:"op"`
+	EmpId     int64  `json:"emp_id,string"`
+	PlanId    int64  `json:"plan_id,string"`
+	Undone    bool   `json:"undone"`
+	Days      int    `json:"days"` // number of days changed
+	Version   int64  `json:"version,string"`
+	// whether the plan row exists once the entry is applied or undone, ex:
+	// false after undoing a new row
+	RowExists bool `json:"row_exists"`
+}
+
+func (e *JournalEntry) setRowExists() {
+	switch e.Op {
+	case JournalNew:
+		e.RowExists = !e.Undone
+	case JournalDelete:
+		e.RowExists = e.Undone
+	default:
+		e.RowExists = true
+	}
+}
+
+// adds the change to the journal of the plan's page, in the change's
+// transaction. A new change drops whatever could be redone. Plans without a
+// page are not journaled.
+func journalPlanRow(ctx context.Context, tx *sql.Tx, op string, empId, planId, version int64, before, after []journalDay) error {
+	var page sql.NullInt64
+	if err := tx.QueryRowContext(ctx, "SELECT plan FROM Plan WHERE id=?;", planId).Scan(&page); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("journal page query error: %v", err)
+	}
+	if !page.Valid {
+		return nil
+	}
+
+	if before == nil {
+		before = []journalDay{}
+	}
+	if after == nil {
+		after = []journalDay{}
+	}
+	b, err := json.Marshal(before)
+	if err != nil {
+		return fmt.Errorf("journal encode error: %v", err)
+	}
+	a, err := json.Marshal(after)
+	if err != nil {
+		return fmt.Errorf("journal encode error: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM PlanJournal WHERE page=? AND undone;", page.Int64); err != nil {
+		return fmt.Errorf("journal delete error: %v", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO PlanJournal (page,actor,op,emp,plan,before,after,version)
+	VALUES (?,(SELECT actor FROM AuditActor WHERE id=1),?,?,?,?,?,?);`,
+		page.Int64, op, empId, planId, string(b), string(a), version)
+	if err != nil {
+		return fmt.Errorf("journal insert error: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	DELETE FROM PlanJournal
+	WHERE page=?1 AND id <= (
+		SELECT id FROM PlanJournal WHERE page=?1 ORDER BY id DESC LIMIT 1 OFFSET ?2
+	);`, page.Int64, PlanJournalLimit)
+	if err != nil {
+		return fmt.Errorf("journal prune error: %v", err)
+	}
+	return nil
+}
+
+// the plan row's days by date
+func planRowDays(ctx context.Context, tx *sql.Tx, empId, planId int64) (map[string]journalDay, error) {
+	rows, err := tx.QueryContext(ctx, `
+	SELECT cal_date,IFNULL(planned_hours,0),IFNULL(description,'')
+	FROM PlanDay
+	WHERE emp=? AND plan=?
+	ORDER BY cal_date;`, empId, planId)
+	if err != nil {
+		return nil, fmt.Errorf("plan day query error: %v", err)
+	}
+	defer rows.Close()
+
+	days := make(map[string]journalDay)
+	for rows.Next() {
+		var d journalDay
+		if err := rows.Scan(&d.CalDate, &d.Hours, &d.Description); err != nil {
+			return nil, fmt.Errorf("plan day scan error: %v", err)
+		}
+		days[d.CalDate] = d
+	}
+	return days, rows.Err()
+}
+
+func insertPlanDays(ctx context.Context, tx *sql.Tx, empId, planId int64, days []journalDay) error {
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO PlanDay (planned_hours,description,cal_date,emp,plan) VALUES (?,?,?,?,?);")
+	if err != nil {
+		return fmt.Errorf("insert prepare error: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, d := range days {
+		if _, err := stmt.ExecContext(ctx, d.Hours, d.Description, d.CalDate, empId, planId); err != nil {
+			return fmt.Errorf("%w: %v", ErrJournalReplay, err)
+		}
+	}
+	return nil
+}
+
+func updatePlanDays(ctx context.Context, tx *sql.Tx, empId, planId int64, days []journalDay) error {
+	stmt, err := tx.PrepareContext(ctx, "UPDATE PlanDay SET updated_at=CURRENT_DATE, planned_hours=?, description=? WHERE cal_date=? AND emp=? AND plan=?;")
+	if err != nil {
+		return fmt.Errorf("update prepare error: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, d := range days {
+		if _, err := stmt.ExecContext(ctx, d.Hours, d.Description, d.CalDate, empId, planId); err != nil {
+			return fmt.Errorf("stmt exec error: %v", err)
+		}
+	}
+	return nil
+}
+
+func deletePlanDays(ctx context.Context, tx *sql.Tx, empId, planId int64) (int64, error) {
+	result, err := tx.ExecContext(ctx, "DELETE FROM PlanDay WHERE emp=? AND plan=?;", empId, planId)
+	if err != nil {
+		return 0, fmt.Errorf("delete query exec error: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("delete query result error: %v", err)
+	}
+	return rows, nil
+}
+
+// reverses the newest change on the page that is not undone yet
+func UndoPlanChange(ctx context.Context, db *sql.DB, pageId int64) (JournalEntry, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	return replayJournal(ctx, db, pageId, true)
+}
+
+// applies the oldest undone change on the page again
+func RedoPlanChange(ctx context.Context, db *sql.DB, pageId int64) (JournalEntry, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	return replayJournal(ctx, db, pageId, false)
+}
+
+func replayJournal(ctx context.Context, db *sql.DB, pageId int64, undo bool) (JournalEntry, error) {
+	tx, err := beginWrite(ctx, db)
+	if err != nil {
+		return JournalEntry{}, fmt.Errorf("database transaction error: %v", err)
+	}
+	defer tx.Rollback()
+
+	q := "SELECT id,page,created_at,actor,op,emp,plan,before,after FROM PlanJournal WHERE page=? AND undone ORDER BY id ASC LIMIT 1;"
+	if undo {
+		q = "SELECT id,page,created_at,actor,op,emp,plan,before,after FROM PlanJournal WHERE page=? AND NOT undone ORDER BY id DESC LIMIT 1;"
+	}
+	var e JournalEntry
+	var before, after string
+	err = tx.QueryRowContext(ctx, q, pageId).Scan(&e.Id, &e.PageId, &e.CreatedAt, &e.Actor, &e.Op, &e.EmpId, &e.PlanId, &before, &after)
+	if err == sql.ErrNoRows {
+		if undo {
+			return e, ErrNothingToUndo
+		}
+		return e, ErrNothingToRedo
+	}
+	if err != nil {
+		return e, fmt.Errorf("journal query error: %v", err)
+	}
+
+	var b, a []journalDay
+	if err := json.Unmarshal([]byte(before), &b); err != nil {
+		return e, fmt.Errorf("journal decode error: %v", err)
+	}
+	if err := json.Unmarshal([]byte(after), &a); err != nil {
+		return e, fmt.Errorf("journal decode error: %v", err)
+	}
+
+	if e.Op == JournalUpdate {
+		current, err := planRowDays(ctx, tx, e.EmpId, e.PlanId)
+		if err != nil {
+			return e, err
+		}
+		if len(current) == 0 {
+			return e, fmt.Errorf("%w: employee %d is no longer on plan %d", ErrJournalReplay, e.EmpId, e.PlanId)
+		}
+	}
+
+	// undo a new row by deleting it, redo a delete the same way
+	switch {
+	case (e.Op == JournalNew && undo) || (e.Op == JournalDelete && !undo):
+		_, err = deletePlanDays(ctx, tx, e.EmpId, e.PlanId)
+		e.Days = len(a) + len(b)
+	case e.Op == JournalNew:
+		err = insertPlanDays(ctx, tx, e.EmpId, e.PlanId, a)
+		e.Days = len(a)
+	case e.Op == JournalDelete:
+		err = insertPlanDays(ctx, tx, e.EmpId, e.PlanId, b)
+		e.Days = len(b)
+	case undo:
+		err = updatePlanDays(ctx, tx, e.EmpId, e.PlanId, b)
+		e.Days = len(b)
+	default:
+		err = updatePlanDays(ctx, tx, e.EmpId, e.PlanId, a)
+		e.Days = len(a)
+	}
+	if err != nil {
+		return e, err
+	}
+
+	version, err := bumpPlanRowVersion(ctx, tx, e.EmpId, e.PlanId)
+	if err != nil {
+		return e, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE PlanJournal SET undone=?, version=? WHERE id=?;", undo, version, e.Id); err != nil {
+		return e, fmt.Errorf("journal update error: %v", err)
+	}
+	if err := commitWrite(ctx, tx); err != nil {
+		return e, fmt.Errorf("commit transaction error: %v", err)
+	}
+	e.Undone = undo
+	e.Version = version
+	e.setRowExists()
+	return e, nil
+}
+
+// the page's journal, newest first. Undone entries are the ones redo
+// applies.
+func PlanJournalEntries(ctx context.Context, db *sql.DB, pageId int64) ([]JournalEntry, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `
+	SELECT id,page,created_at,actor,op,emp,plan,undone,version,
+	  json_array_length(CASE op WHEN 'delete' THEN before ELSE after END)
+	FROM PlanJournal
+	WHERE page=?
+	ORDER BY id DESC;`, pageId)
+	if err != nil {
+		return nil, fmt.Errorf("journal query error: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []JournalEntry
+	for rows.Next() {
+		var e JournalEntry
+		if err := rows.Scan(&e.Id, &e.PageId, &e.CreatedAt, &e.Actor, &e.Op, &e.EmpId, &e.PlanId, &e.Undone, &e.Version, &e.Days); err != nil {
+			return nil, fmt.Errorf("journal scan error: %v", err)
+		}
+		e.setRowExists()
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+	return entries, nil
+}
@@ -1,16 +1,26 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
 
 type PlanPage struct {
-	Id          int64   `json:"id,string"`
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	TargetCost  float64 `json:"target_cost"`
-	TargetHours float64 `json:"target_hours"`
+	Id          int64       `json:"id,string"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	TargetCost  NullFloat64 `json:"target_cost"`
+	TargetHours float64     `json:"target_hours"`
+}
+
+// a title and no negative targets
+func (p PlanPage) Validate() error {
+	return validate(
+		required("title", p.Title),
+		notNegative("target_cost", p.TargetCost.Float64),
+		notNegative("target_hours", p.TargetHours),
+	)
 }
 
 func NewLaborPlan() PlanPage {
@@ -49,63 +59,126 @@ func PlanPageDropdownQuery() string {
 	return "SELECT title AS name,id FROM PlanPage ORDER BY name;"
 }
 
-func GetPlanPage(db *sql.DB, id int64) (PlanPage, error) {
+func GetPlanPage(ctx context.Context, db *sql.DB, id int64) (PlanPage, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var plan PlanPage
 
 	getQuery := `SELECT id,title,description,target_cost,target_hours FROM PlanPage WHERE id=?;`
 
-	row := db.QueryRow(getQuery, id)
+	row := db.QueryRowContext(ctx, getQuery, id)
 	if err := row.Scan(&plan.Id, &plan.Title, &plan.Description, &plan.TargetCost, &plan.TargetHours); err != nil {
 		if err == sql.ErrNoRows {
-			return plan, fmt.Errorf("plan page id=%d: no such row", id)
+			return plan, fmt.Errorf("plan page id=%d: no such row: %w", id, dbError(err))
 		}
 		return plan, fmt.Errorf("plan page: id=%d: %v", id, err)
 	}
 	return plan, nil
 }
 
-func InsertPlanPage(db *sql.DB, plan PlanPage) (int64, error) {
+func AllPlanPages(ctx context.Context, db *sql.DB) ([]PlanPage, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var plans []PlanPage
+
+	getQuery := `SELECT id,title,description,target_cost,target_hours FROM PlanPage ORDER BY title;`
+
+	rows, err := db.QueryContext(ctx, getQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var plan PlanPage
+		if err := rows.Scan(&plan.Id, &plan.Title, &plan.Description, &plan.TargetCost, &plan.TargetHours); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("error: no rows")
+			}
+			return nil, fmt.Errorf("row scan error: %v", err)
+		}
+		plans = append(plans, plan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+	return plans, nil
+}
+
+// a null target cost (redacted for the user) keeps the stored value
+func UpdatePlanPage(ctx context.Context, db *sql.DB, plan PlanPage) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	updateQuery := `
+	UPDATE PlanPage SET title=?,description=?,target_cost=IFNULL(?,target_cost),target_hours=? WHERE id=?;
+	`
+
+	result, err := execWrite(ctx, db, updateQuery, plan.Title, plan.Description, plan.TargetCost, plan.TargetHours, plan.Id)
+	if err != nil {
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("update result error: %v", err)
+	}
+	return rows, nil
+}
+
+func InsertPlanPage(ctx context.Context, db *sql.DB, plan PlanPage) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	insertQuery := `
 	INSERT INTO PlanPage
-	  (title,description)
+	  (title,description,target_cost,target_hours)
 	VALUES
-	  (?, ?);
+	  (?, ?, IFNULL(?,0), ?);
 	`
 
-	result, err := db.Exec(insertQuery, plan.Title, plan.Description)
+	result, err := execWrite(ctx, db, insertQuery, plan.Title, plan.Description, plan.TargetCost, plan.TargetHours)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
 	}
 
-	rows, err := result.RowsAffected()
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("insert result error: %v", err)
 	}
-	return rows, nil
+	return id, nil
 }
 
-func GetTargetValues(db *sql.DB, id int64) (float64, float64, error) {
+func GetTargetValues(ctx context.Context, db *sql.DB, id int64) (float64, float64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	getQuery := `SELECT target_cost,target_hours FROM PlanPage WHERE id=?;`
 
-	row := db.QueryRow(getQuery, id)
+	row := db.QueryRowContext(ctx, getQuery, id)
 	var targetCost, targetHours float64
 	if err := row.Scan(&targetCost, &targetHours); err != nil {
 		if err == sql.ErrNoRows {
-			return 0.0, 0.0, fmt.Errorf("target values id=%d: no such row", id)
+			return 0.0, 0.0, fmt.Errorf("target values id=%d: no such row: %w", id, dbError(err))
 		}
 		return 0.0, 0.0, fmt.Errorf("target values: id=%d: %v", id, err)
 	}
 	return targetCost, targetHours, nil
 }
 
-func UpdateTargetValues(db *sql.DB, id int64, targetCost, targetHours float64) (int64, error) {
+func UpdateTargetValues(ctx context.Context, db *sql.DB, id int64, targetCost, targetHours float64) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	updateQuery := `
 	UPDATE PlanPage SET target_cost=?,target_hours=? WHERE id=?;
 	`
 
-	result, err := db.Exec(updateQuery, targetCost, targetHours, id)
+	result, err := execWrite(ctx, db, updateQuery, targetCost, targetHours, id)
 	if err != nil {
-		return 0, fmt.Errorf("update query error: %v", err)
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// every write to a plan row bumps its version. Hours are only updated
+// against the version they were read at, so two planners on the same plan
+// don't silently overwrite each other.
+const planRowVersionQuery = `
+CREATE TABLE IF NOT EXISTS PlanRowVersion (
+	id INTEGER PRIMARY KEY,
+	emp INTEGER NOT NULL,
+	plan INTEGER NOT NULL,
+	version INTEGER NOT NULL DEFAULT 0,
+	updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+	actor TEXT NOT NULL DEFAULT '',
+	UNIQUE (emp, plan),
+	FOREIGN KEY (emp) REFERENCES Employee(id)
+		ON DELETE CASCADE,
+	FOREIGN KEY (plan) REFERENCES Plan(id)
+		ON DELETE CASCADE
+);
+`
+
+type PlanRowVersion struct{}
+
+func (v PlanRowVersion) Init(db *sql.DB) error {
+	if _, err := db.Exec(planRowVersionQuery); err != nil {
+		return fmt.Errorf("error executing CREATE TABLE: %v", err)
+	}
+	return nil
+}
+
+// the version of a plan row, 0 before its first write
+func GetPlanRowVersion(ctx context.Context, db *sql.DB, empId, planId int64) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var v int64
+	err := db.QueryRowContext(ctx, "SELECT version FROM PlanRowVersion WHERE emp=? AND plan=?;", empId, planId).Scan(&v)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("plan row version error: %v", err)
+	}
+	return v, nil
+}
+
+func bumpPlanRowVersion(ctx context.Context, tx *sql.Tx, empId, planId int64) (int64, error) {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO PlanRowVersion (emp,plan,version,actor)
+	VALUES (?,?,1,(SELECT actor FROM AuditActor WHERE id=1))
+	ON CONFLICT (emp,plan) DO UPDATE SET
+	  version=version+1,
+	  updated_at=strftime('%Y-%m-%dT%H:%M:%fZ', 'now'),
+	  actor=excluded.actor;`, empId, planId)
+	if err != nil {
+		return 0, fmt.Errorf("plan row version error: %v", err)
+	}
+
+	var v int64
+	if err := tx.QueryRowContext(ctx, "SELECT version FROM PlanRowVersion WHERE emp=? AND plan=?;", empId, planId).Scan(&v); err != nil {
+		return 0, fmt.Errorf("plan row version error: %v", err)
+	}
+	return v, nil
+}
+
+// a day changed by someone else since the version the client had
+type PlanDayChange struct {
+	CalDate   string  `json:"cal_date"`
+	Hours     float64 `json:"hours"`
+	Actor     string  `json:"actor"`
+	ChangedAt string  `json:"changed_at"`
+}
+
+// returned by UpdatePlanRow when the row changed since the version the
+// update was based on
+type PlanRowConflict struct {
+	EmpId     int64           `json:"emp_id,string"`
+	PlanId    int64           `json:"plan_id,string"`
+	Version   int64           `json:"version,string"` // current version, retry against it
+	Expected  int64           `json:"expected,string"`
+	UpdatedBy string          `json:"updated_by"`
+	UpdatedAt string          `json:"updated_at"`
+	Changes   []PlanDayChange `json:"changes"` // current value of each day changed since
+}
+
+func (c *PlanRowConflict) Error() string {
+	by := c.UpdatedBy
+	if len(by) == 0 {
+		by = "someone else"
+	}
+	return fmt.Sprintf("plan row changed by %s at %s: version %d, update is for version %d", by, c.UpdatedAt, c.Version, c.Expected)
+}
+
+// a conflict for errors.Is(err, ErrConflict)
+func (c *PlanRowConflict) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// the days come from the journal, with their current values from days. Rows
+// of plans without a page aren't journaled, for them it is the posted days
+// whose current value is different.
+func planRowConflict(ctx context.Context, tx *sql.Tx, empId, planId, expected int64, days map[string]journalDay, posted []PlanDay) (*PlanRowConflict, error) {
+	c := &PlanRowConflict{EmpId: empId, PlanId: planId, Expected: expected, Changes: []PlanDayChange{}}
+	err := tx.QueryRowContext(ctx, "SELECT version,actor,updated_at FROM PlanRowVersion WHERE emp=? AND plan=?;", empId, planId).
+		Scan(&c.Version, &c.UpdatedBy, &c.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("plan row version error: %v", err)
+	}
+
+	// the journaled changes since, an undone change left the before values
+	rows, err := tx.QueryContext(ctx, `
+	SELECT actor,created_at,undone,before,after
+	FROM PlanJournal
+	WHERE emp=? AND plan=? AND version>?
+	ORDER BY version;`, empId, planId, expected)
+	if err != nil {
+		return nil, fmt.Errorf("journal query error: %v", err)
+	}
+	defer rows.Close()
+
+	latest := make(map[string]PlanDayChange)
+	for rows.Next() {
+		var actor, at, before, after string
+		var undone bool
+		if err := rows.Scan(&actor, &at, &undone, &before, &after); err != nil {
+			return nil, fmt.Errorf("journal scan error: %v", err)
+		}
+		values := after
+		if undone {
+			values = before
+		}
+		var days []journalDay
+		if err := json.Unmarshal([]byte(values), &days); err != nil {
+			return nil, fmt.Errorf("journal decode error: %v", err)
+		}
+		for _, d := range days {
+			latest[d.CalDate] = PlanDayChange{CalDate: d.CalDate, Hours: d.Hours, Actor: actor, ChangedAt: at}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+
+	for date, d := range latest {
+		if cur, ok := days[date]; ok {
+			d.Hours = cur.Hours
+			latest[date] = d
+		}
+	}
+	if len(latest) == 0 {
+		for _, p := range posted {
+			if cur, ok := days[p.CalDate]; ok && cur.Hours != p.PlanHours {
+				latest[p.CalDate] = PlanDayChange{CalDate: p.CalDate, Hours: cur.Hours, Actor: c.UpdatedBy, ChangedAt: c.UpdatedAt}
+			}
+		}
+	}
+
+	for _, d := range latest {
+		c.Changes = append(c.Changes, d)
+	}
+	sort.Slice(c.Changes, func(i, j int) bool { return c.Changes[i].CalDate < c.Changes[j].CalDate })
+	return c, nil
+}
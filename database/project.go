@@ -1,29 +1,40 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
 
 type Project struct {
-	Id             int64         `json:"id,string"`
-	Title          string        `json:"title"`        // Software Maintenance (SM)
-	Description    string        `json:"description"`  // CLIN, Control Account, Work Package, etc.
-	WbsId          string        `json:"wbsid"`        // 1.1002.5.2
-	StmtOfWork     string        `json:"stmt_of_work"` // statement of work or WP description
-	StartDate      string        `json:"start_date"`
-	EndDate        string        `json:"end_date"`
-	ImsUid         int           `json:"ims_uid,string"`    // Assigned by the scheduler
-	WadLineId      int           `json:"wad_lineid,string"` // Is this for the PLATO tab?
-	Evt            string        `json:"evt"`
-	ParentProject  sql.NullInt64 `json:"parent_proj"`
-	ParentProjName string        `json:"parent_proj_name"`
+	Id             int64     `json:"id,string"`
+	Title          string    `json:"title"`        // Software Maintenance (SM)
+	Description    string    `json:"description"`  // CLIN, Control Account, Work Package, etc.
+	WbsId          string    `json:"wbsid"`        // 1.1002.5.2
+	StmtOfWork     string    `json:"stmt_of_work"` // statement of work or WP description
+	StartDate      string    `json:"start_date"`
+	EndDate        string    `json:"end_date"`
+	ImsUid         int       `json:"ims_uid,string"`    // Assigned by the scheduler
+	WadLineId      int       `json:"wad_lineid,string"` // Is this for the PLATO tab?
+	Evt            string    `json:"evt"`
+	ParentProject  NullInt64 `json:"parent_proj"`
+	ParentProjName string    `json:"parent_proj_name"`
 }
 
 func NewProject() Project {
 	return Project{}
 }
 
+// the wbs id is required and the dates are in order
+func (p Project) Validate() error {
+	return validate(
+		required("wbsid", p.WbsId),
+		date("start_date", p.StartDate),
+		date("end_date", p.EndDate),
+		dateOrder("start_date", p.StartDate, "end_date", p.EndDate),
+	)
+}
+
 func (p Project) Init(db *sql.DB) error {
 	tableQuery := `
 	CREATE TABLE IF NOT EXISTS Project (
@@ -68,7 +79,10 @@ func ProjectImportColumns() []string {
 		"start_date", "end_date", "ims_uid", "evt"}
 }
 
-func GetProject(db *sql.DB, id int64) (Project, error) {
+func GetProject(ctx context.Context, db *sql.DB, id int64) (Project, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var proj Project
 
 	getQuery := `
@@ -79,10 +93,10 @@ func GetProject(db *sql.DB, id int64) (Project, error) {
 	WHERE id=?;
 	`
 
-	row := db.QueryRow(getQuery, id)
+	row := db.QueryRowContext(ctx, getQuery, id)
 	if err := row.Scan(&proj.Id, &proj.Title, &proj.Description, &proj.WbsId, &proj.StmtOfWork, &proj.StartDate, &proj.EndDate, &proj.ImsUid, &proj.WadLineId, &proj.Evt, &proj.ParentProject); err != nil {
 		if err == sql.ErrNoRows {
-			return proj, fmt.Errorf("project id=%d: no such row", id)
+			return proj, fmt.Errorf("project id=%d: no such row: %w", id, dbError(err))
 		}
 		return proj, fmt.Errorf("project: id=%d: %v", id, err)
 	}
@@ -90,20 +104,46 @@ func GetProject(db *sql.DB, id int64) (Project, error) {
 	return proj, nil
 }
 
-func AllProjects(db *sql.DB) ([]Project, error) {
+func ProjectListFields() ListFields {
+	return ListFields{
+		Filters: map[string]FilterField{
+			"parent": {Column: "parent_project", Type: FilterId},
+			"evt":    {Column: "evt", Type: FilterText},
+		},
+		Sorts: map[string][]string{
+			"id":         {"id"},
+			"wbsid":      {"wbs_id"},
+			"title":      {"title"},
+			"start_date": {"start_date"},
+			"end_date":   {"end_date"},
+		},
+		DefaultSort: "wbsid",
+	}
+}
+
+func AllProjects(ctx context.Context, db *sql.DB, q ListQuery) ([]Project, int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	var projects []Project
 
+	total, err := countRows(ctx, db, "Project", q)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	getQuery := `
 	SELECT
 	  id,title,description,wbs_id,stmt_of_work,start_date,
 	  end_date,ims_uid,wad_line_id,evt,parent_project
-	FROM Project
-	ORDER BY wbs_id;
-	`
+	FROM Project`
+
+	where, args := q.Where()
+	getQuery += where + q.OrderLimit() + ";"
 
-	rows, err := db.Query(getQuery)
+	rows, err := db.QueryContext(ctx, getQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("query error: %v", err)
+		return nil, 0, fmt.Errorf("query error: %v", err)
 	}
 	defer rows.Close()
 
@@ -111,20 +151,23 @@ func AllProjects(db *sql.DB) ([]Project, error) {
 		var proj Project
 		if err := rows.Scan(&proj.Id, &proj.Title, &proj.Description, &proj.WbsId, &proj.StmtOfWork, &proj.StartDate, &proj.EndDate, &proj.ImsUid, &proj.WadLineId, &proj.Evt, &proj.ParentProject); err != nil {
 			if err == sql.ErrNoRows {
-				return nil, fmt.Errorf("error: no rows")
+				return nil, 0, fmt.Errorf("error: no rows")
 			}
-			return nil, fmt.Errorf("row scan error: %v", err)
+			return nil, 0, fmt.Errorf("row scan error: %v", err)
 		}
 
 		projects = append(projects, proj)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %v", err)
+		return nil, 0, fmt.Errorf("rows error: %v", err)
 	}
-	return projects, nil
+	return projects, total, nil
 }
 
-func UpdateProject(db *sql.DB, proj Project) (int64, error) {
+func UpdateProject(ctx context.Context, db *sql.DB, proj Project) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	updateQuery := `
 	UPDATE Project SET
 	  title=?,description=?,wbs_id=?,stmt_of_work=?,start_date=?,
@@ -132,9 +175,9 @@ func UpdateProject(db *sql.DB, proj Project) (int64, error) {
 	WHERE id=?;
 	`
 
-	result, err := db.Exec(updateQuery, proj.Title, proj.Description, proj.WbsId, proj.StmtOfWork, proj.StartDate, proj.EndDate, proj.ImsUid, proj.WadLineId, proj.Evt, proj.ParentProject, proj.Id)
+	result, err := execWrite(ctx, db, updateQuery, proj.Title, proj.Description, proj.WbsId, proj.StmtOfWork, proj.StartDate, proj.EndDate, proj.ImsUid, proj.WadLineId, proj.Evt, proj.ParentProject, proj.Id)
 	if err != nil {
-		return 0, fmt.Errorf("update query error: %v", err)
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
 	}
 
 	rows, err := result.RowsAffected()
@@ -144,7 +187,10 @@ func UpdateProject(db *sql.DB, proj Project) (int64, error) {
 	return rows, nil
 }
 
-func InsertProject(db *sql.DB, proj Project) (int64, error) {
+func InsertProject(ctx context.Context, db *sql.DB, proj Project) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	insertQuery := `
 	INSERT INTO Project
 	  (title,description,wbs_id,stmt_of_work,start_date,
@@ -153,14 +199,14 @@ func InsertProject(db *sql.DB, proj Project) (int64, error) {
 	  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 	`
 
-	result, err := db.Exec(insertQuery, proj.Title, proj.Description, proj.WbsId, proj.StmtOfWork, proj.StartDate, proj.EndDate, proj.ImsUid, proj.WadLineId, proj.Evt, proj.ParentProject)
+	result, err := execWrite(ctx, db, insertQuery, proj.Title, proj.Description, proj.WbsId, proj.StmtOfWork, proj.StartDate, proj.EndDate, proj.ImsUid, proj.WadLineId, proj.Evt, proj.ParentProject)
 	if err != nil {
-		return 0, fmt.Errorf("insert query error: %v", err)
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
 	}
 
-	rows, err := result.RowsAffected()
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, fmt.Errorf("insert result error: %v", err)
 	}
-	return rows, nil
+	return id, nil
 }
@@ -0,0 +1,467 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// deleting a record moves it to the recycle bin together with everything the
+// delete cascades to (ex: an employee's plan days) and the references it
+// clears (ex: reports_to of their direct reports). Restoring puts all of it
+// back; purging drops the entry for good.
+const recycleBinQuery = `
+CREATE TABLE IF NOT EXISTS RecycleBin (
+	id INTEGER PRIMARY KEY AUTOINCREMENT, -- never reused, ids go back to the ui
+	deleted_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+	actor TEXT NOT NULL DEFAULT '',
+	tbl TEXT NOT NULL,
+	row_id INTEGER NOT NULL,
+	label TEXT NOT NULL DEFAULT '',
+	dependents INTEGER NOT NULL DEFAULT 0,
+	data TEXT NOT NULL -- json, see binData
+);
+CREATE INDEX IF NOT EXISTS idx_recycle_time ON RecycleBin(deleted_at);
+`
+
+var (
+	ErrBinNotFound     = kindError(ErrNotFound, "no such recycle bin entry")
+	ErrRestoreConflict = kindError(ErrConflict, "record can't be restored")
+)
+
+type RecycleBin struct{}
+
+func (b RecycleBin) Init(db *sql.DB) error {
+	if _, err := db.Exec(recycleBinQuery); err != nil {
+		return fmt.Errorf("error executing CREATE TABLE: %v", err)
+	}
+	return nil
+}
+
+// tables deleted through the bin and the name shown for a record
+var recycledTables = map[string]string{
+	"Compensation": "resource_code",
+	"Ipt":          "name",
+	"Employee":     "display_name",
+	"Project":      "wbs_id || ' ' || title",
+	"Network":      "charge_number || ' ' || title",
+	"Material":     "name",
+	"PlanPage":     "title",
+}
+
+type binRow struct {
+	Table string          `json:"table"`
+	Row   json.RawMessage `json:"row"`
+}
+
+// a column set to NULL by the delete, put back on restore if still NULL
+type binRef struct {
+	Table  string `json:"table"`
+	Id     int64  `json:"id"` // rowid, the same as id for tables that have one
+	Column string `json:"column"`
+	Value  int64  `json:"value"`
+}
+
+// rows in insert order, the deleted record first
+type binData struct {
+	Rows []binRow `json:"rows"`
+	Refs []binRef `json:"refs"`
+}
+
+type foreignKey struct {
+	table    string
+	column   string
+	onDelete string
+}
+
+// foreign keys pointing at each table, keyed by the lowercase table name
+func referencingKeys(ctx context.Context, tx *sql.Tx) (map[string][]foreignKey, error) {
+	rows, err := tx.QueryContext(ctx, `
+	SELECT m.name,f."table",f."from",f.on_delete
+	FROM sqlite_master m, pragma_foreign_key_list(m.name) f
+	WHERE m.type='table';`)
+	if err != nil {
+		return nil, fmt.Errorf("foreign key query error: %v", err)
+	}
+	defer rows.Close()
+
+	keys := make(map[string][]foreignKey)
+	for rows.Next() {
+		var child, parent string
+		var fk foreignKey
+		if err := rows.Scan(&child, &parent, &fk.column, &fk.onDelete); err != nil {
+			return nil, fmt.Errorf("foreign key scan error: %v", err)
+		}
+		fk.table = child
+		keys[strings.ToLower(parent)] = append(keys[strings.ToLower(parent)], fk)
+	}
+	return keys, rows.Err()
+}
+
+func txColumnNames(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM pragma_table_info(?);", table)
+	if err != nil {
+		return nil, fmt.Errorf("table info error: %v", err)
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, fmt.Errorf("table info scan error: %v", err)
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+func queryIds(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("recycle query error: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("recycle scan error: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+type snapshot struct {
+	tx   *sql.Tx
+	keys map[string][]foreignKey
+	seen map[string]bool
+	data binData
+}
+
+// adds the row and, depth first, whatever deleting it would cascade to.
+// Rows are found by rowid, not every table has an id column (ex: Session).
+func (s *snapshot) add(ctx context.Context, table string, id int64) error {
+	seenKey := fmt.Sprintf("%s/%d", strings.ToLower(table), id)
+	if s.seen[seenKey] {
+		return nil
+	}
+	s.seen[seenKey] = true
+
+	cols, err := txColumnNames(ctx, s.tx, table)
+	if err != nil {
+		return err
+	}
+	var row string
+	err = s.tx.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s AS r WHERE rowid=?;", jsonRow("r", cols), table), id).Scan(&row)
+	if err != nil {
+		return fmt.Errorf("recycle snapshot error: %v", err)
+	}
+	s.data.Rows = append(s.data.Rows, binRow{Table: table, Row: json.RawMessage(row)})
+
+	for _, fk := range s.keys[strings.ToLower(table)] {
+		ids, err := queryIds(ctx, s.tx, fmt.Sprintf("SELECT rowid FROM %s WHERE %s=?;", fk.table, fk.column), id)
+		if err != nil {
+			return err
+		}
+		for _, childId := range ids {
+			switch fk.onDelete {
+			case "CASCADE":
+				if err := s.add(ctx, fk.table, childId); err != nil {
+					return err
+				}
+			case "SET NULL":
+				s.data.Refs = append(s.data.Refs, binRef{Table: fk.table, Id: childId, Column: fk.column, Value: id})
+			}
+		}
+	}
+	return nil
+}
+
+// deletes the row, keeping it and its dependents in the recycle bin.
+// Returns the rows affected like DeleteRow.
+func RecycleRow(ctx context.Context, db *sql.DB, table string, id int64) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	label, ok := recycledTables[table]
+	if !ok {
+		return 0, fmt.Errorf("recycle error: %s records are not recycled", table)
+	}
+
+	tx, err := beginWrite(ctx, db)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var name string
+	err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(%s,'') FROM %s WHERE id=?;", label, table), id).Scan(&name)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("recycle query error: %v", err)
+	}
+
+	keys, err := referencingKeys(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	s := snapshot{tx: tx, keys: keys, seen: make(map[string]bool)}
+	if err := s.add(ctx, table, id); err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(s.data)
+	if err != nil {
+		return 0, fmt.Errorf("recycle encode error: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO RecycleBin (actor,tbl,row_id,label,dependents,data)
+	VALUES ((SELECT actor FROM AuditActor WHERE id=1),?,?,?,?,?);`,
+		table, id, name, len(s.data.Rows)-1, string(data))
+	if err != nil {
+		return 0, fmt.Errorf("recycle insert error: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id=?;", table), id)
+	if err != nil {
+		return 0, fmt.Errorf("delete query exec error: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("delete query result error: %v", err)
+	}
+
+	if err := commitWrite(ctx, tx); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return rows, nil
+}
+
+// INSERT INTO t (a,b) SELECT json_extract(?1,'$."a"'),json_extract(?1,'$."b"')
+// for the columns of the stored row the table still has
+func restoreQuery(ctx context.Context, tx *sql.Tx, r binRow) (string, error) {
+	var stored map[string]json.RawMessage
+	if err := json.Unmarshal(r.Row, &stored); err != nil {
+		return "", fmt.Errorf("restore decode error: %v", err)
+	}
+	cols, err := txColumnNames(ctx, tx, r.Table)
+	if err != nil {
+		return "", err
+	}
+
+	var names, values []string
+	for _, c := range cols {
+		if _, ok := stored[c]; !ok {
+			continue
+		}
+		names = append(names, `"`+c+`"`)
+		values = append(values, fmt.Sprintf(`json_extract(?1,'$."%s"')`, c))
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("restore error: table %s has no matching columns", r.Table)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s;", r.Table, strings.Join(names, ","), strings.Join(values, ",")), nil
+}
+
+// puts the record and its dependents back and removes the bin entry.
+// Dependents that no longer fit (ex: plan days of a plan deleted since) are
+// skipped and counted.
+func RestoreRecycled(ctx context.Context, db *sql.DB, id int64) (BinEntry, int, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := beginWrite(ctx, db)
+	if err != nil {
+		return BinEntry{}, 0, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	e, raw, err := binEntry(ctx, tx, id)
+	if err != nil {
+		return BinEntry{}, 0, err
+	}
+	var data binData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return e, 0, fmt.Errorf("restore decode error: %v", err)
+	}
+
+	skipped := 0
+	for i, r := range data.Rows {
+		q, err := restoreQuery(ctx, tx, r)
+		if err != nil {
+			return e, 0, err
+		}
+		if i == 0 {
+			if _, err := tx.ExecContext(ctx, q, string(r.Row)); err != nil {
+				return e, 0, fmt.Errorf("%w: %s %d: %v", ErrRestoreConflict, e.Table, e.RowId, err)
+			}
+			continue
+		}
+
+		// a failed dependent must not undo the rest
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT restore_row;"); err != nil {
+			return e, 0, fmt.Errorf("restore savepoint error: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, q, string(r.Row)); err != nil {
+			skipped++
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO restore_row;"); err != nil {
+				return e, 0, fmt.Errorf("restore savepoint error: %v", err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE restore_row;"); err != nil {
+			return e, 0, fmt.Errorf("restore savepoint error: %v", err)
+		}
+	}
+
+	for _, ref := range data.Refs {
+		q := fmt.Sprintf(`UPDATE %s SET "%s"=? WHERE rowid=? AND "%s" IS NULL;`, ref.Table, ref.Column, ref.Column)
+		if _, err := tx.ExecContext(ctx, q, ref.Value, ref.Id); err != nil {
+			return e, 0, fmt.Errorf("restore reference error: %v", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM RecycleBin WHERE id=?;", id); err != nil {
+		return e, 0, fmt.Errorf("recycle delete error: %v", err)
+	}
+	if err := commitWrite(ctx, tx); err != nil {
+		return e, 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return e, skipped, nil
+}
+
+// removes the entry for good
+func PurgeRecycled(ctx context.Context, db *sql.DB, id int64) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, "DELETE FROM RecycleBin WHERE id=?;", id)
+	if err != nil {
+		return fmt.Errorf("purge query exec error: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("purge query result error: %v", err)
+	}
+	if rows == 0 {
+		return ErrBinNotFound
+	}
+	return nil
+}
+
+// empties the recycle bin, returns the number of entries purged
+func PurgeAllRecycled(ctx context.Context, db *sql.DB) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	return DeleteAll(ctx, db, "RecycleBin")
+}
+
+type BinEntry struct {
+	Id         int64  `json:"id,string"`
+	DeletedAt  string `json:"deleted_at"`
+	Actor      string `json:"actor"`
+	Table      string `json:"table"`
+	RowId      int64  `json:"row_id,string"`
+	Label      string `json:"label"`
+	Dependents int64  `json:"dependents"`
+}
+
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func binEntry(ctx context.Context, q rowQuerier, id int64) (BinEntry, string, error) {
+	var e BinEntry
+	var data string
+	err := q.QueryRowContext(ctx, `
+	SELECT id,deleted_at,actor,tbl,row_id,label,dependents,data
+	FROM RecycleBin WHERE id=?;`, id).Scan(&e.Id, &e.DeletedAt, &e.Actor, &e.Table, &e.RowId, &e.Label, &e.Dependents, &data)
+	if err == sql.ErrNoRows {
+		return e, "", ErrBinNotFound
+	}
+	if err != nil {
+		return e, "", fmt.Errorf("recycle query error: %v", err)
+	}
+	return e, data, nil
+}
+
+func GetRecycled(ctx context.Context, db *sql.DB, id int64) (BinEntry, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	e, _, err := binEntry(ctx, db, id)
+	return e, err
+}
+
+// ?table=Employee&actor=admin&from=2025-01-01&to=2025-01-31
+func RecycleListFields() ListFields {
+	return ListFields{
+		Filters: map[string]FilterField{
+			"table": {Column: "tbl", Type: FilterText},
+			"actor": {Column: "actor", Type: FilterText},
+			"label": {Column: "label", Type: FilterText},
+			"from":  {Column: "deleted_at", Type: FilterDateFrom},
+			"to":    {Column: "deleted_at", Type: FilterDateTo},
+		},
+		Sorts: map[string][]string{
+			"time":  {"deleted_at"},
+			"table": {"tbl", "deleted_at"},
+			"label": {"label"},
+		},
+		DefaultSort: "time",
+	}
+}
+
+func RecycledEntries(ctx context.Context, db *sql.DB, q ListQuery) ([]BinEntry, int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	total, err := countRows(ctx, db, "RecycleBin", q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	getQuery := `
+	SELECT id,deleted_at,actor,tbl,row_id,label,dependents
+	FROM RecycleBin`
+
+	where, args := q.Where()
+	getQuery += where + q.OrderLimit() + ";"
+
+	rows, err := db.QueryContext(ctx, getQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("recycle query error: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []BinEntry
+	for rows.Next() {
+		var e BinEntry
+		if err := rows.Scan(&e.Id, &e.DeletedAt, &e.Actor, &e.Table, &e.RowId, &e.Label, &e.Dependents); err != nil {
+			return nil, 0, fmt.Errorf("recycle scan error: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %v", err)
+	}
+	return entries, total, nil
+}
+
+// names of the tables deleted through the recycle bin
+func RecycledTables() []string {
+	names := make([]string, 0, len(recycledTables))
+	for name := range recycledTables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
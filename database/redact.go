@@ -0,0 +1,45 @@
+package database
+
+// Redact clears the compensation derived fields (rates, costs and target
+// cost) for users without the finance role. Handlers call it before a value
+// is written as json or rendered so the data never leaves the server.
+
+func (p *PlanPage) Redact() {
+	p.TargetCost = NullFloat64{}
+}
+
+func (t *TableRow) Redact() {
+	t.LaborRate = ""
+}
+
+func (p *PeriodRollup) Redact() {
+	p.Cost = NullFloat64{}
+}
+
+func (m *ManagerRollup) Redact() {
+	for i := range m.Periods {
+		m.Periods[i].Redact()
+	}
+}
+
+func (r *IptRollup) Redact() {
+	for i := range r.Periods {
+		r.Periods[i].Redact()
+	}
+}
+
+func (e *EmployeeRollup) Redact() {
+	for i := range e.Periods {
+		e.Periods[i].Redact()
+	}
+}
+
+// redacts every element of a slice in place
+func RedactAll[T any, P interface {
+	*T
+	Redact()
+}](items []T) {
+	for i := range items {
+		P(&items[i]).Redact()
+	}
+}
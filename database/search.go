@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// one fts5 index over the searchable text of the entities, kept in sync by
+// triggers on their tables. It only holds copies, so it is rebuilt on every
+// start. Builds without the fts5 tag skip it.
+const searchIndexQuery = `
+CREATE VIRTUAL TABLE IF NOT EXISTS SearchIndex USING fts5(
+	kind UNINDEXED,
+	row_id UNINDEXED,
+	title,
+	body,
+	prefix='2 3',
+	tokenize='unicode61 remove_diacritics 2'
+);
+`
+
+var ErrSearchUnavailable = errors.New("full-text search unavailable: built without the fts5 tag")
+
+// what gets indexed for a table, the expressions are over the row alias %[1]s
+// and an empty body indexes the title only
+type searchSource struct {
+	kind  string
+	table string
+	title string
+	body  string
+}
+
+var searchSources = []searchSource{
+	{"employee", "Employee",
+		"COALESCE(NULLIF(%[1]s.display_name,''),trim(IFNULL(%[1]s.first_name,'')||' '||IFNULL(%[1]s.last_name,'')))",
+		"trim(IFNULL(%[1]s.first_name,'')||' '||IFNULL(%[1]s.last_name,''))||' '||%[1]s.myid"},
+	{"project", "Project",
+		"%[1]s.wbs_id||' '||IFNULL(%[1]s.title,'')",
+		"IFNULL(%[1]s.stmt_of_work,'')"},
+	{"network", "Network",
+		"%[1]s.charge_number||' '||IFNULL(%[1]s.title,'')",
+		""},
+	{"material", "Material",
+		"%[1]s.name",
+		"IFNULL(%[1]s.notes,'')"},
+	{"planpage", "PlanPage",
+		"%[1]s.title",
+		"IFNULL(%[1]s.description,'')"},
+}
+
+// the result types, ex: for the type filter
+func SearchTypes() []string {
+	types := make([]string, len(searchSources))
+	for i, s := range searchSources {
+		types[i] = s.kind
+	}
+	return types
+}
+
+func SearchAvailable(ctx context.Context, db *sql.DB) (bool, error) {
+	var ok bool
+	if err := db.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5');").Scan(&ok); err != nil {
+		return false, fmt.Errorf("compile option error: %v", err)
+	}
+	return ok, nil
+}
+
+// the title and body expressions over the row alias
+func (s searchSource) columns(row string) (string, string) {
+	body := "''"
+	if len(s.body) > 0 {
+		body = fmt.Sprintf(s.body, row)
+	}
+	return fmt.Sprintf(s.title, row), body
+}
+
+// before anything is written on start, a build without fts5 can't run them
+// and createSearchIndex adds them back
+func dropSearchTriggers(db *sql.DB) error {
+	for _, s := range searchSources {
+		for _, action := range []string{"insert", "update", "delete"} {
+			if _, err := db.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS search_%s_%s;", s.table, action)); err != nil {
+				return fmt.Errorf("search trigger error: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+func createSearchIndex(db *sql.DB) error {
+	available, err := SearchAvailable(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	if !available {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(searchIndexQuery); err != nil {
+		return fmt.Errorf("error executing CREATE VIRTUAL TABLE: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM SearchIndex;"); err != nil {
+		return fmt.Errorf("search index error: %v", err)
+	}
+
+	for _, s := range searchSources {
+		title, body := s.columns("NEW")
+		insert := fmt.Sprintf("INSERT INTO SearchIndex (kind,row_id,title,body) VALUES ('%s',NEW.id,%s,%s);", s.kind, title, body)
+		remove := fmt.Sprintf("DELETE FROM SearchIndex WHERE kind='%s' AND row_id=OLD.id;", s.kind)
+
+		title, body = s.columns("t")
+		queries := []string{
+			fmt.Sprintf("INSERT INTO SearchIndex (kind,row_id,title,body) SELECT '%s',t.id,%s,%s FROM %s t;",
+				s.kind, title, body, s.table),
+			fmt.Sprintf("CREATE TRIGGER search_%[1]s_insert AFTER INSERT ON %[1]s BEGIN %[2]s END;", s.table, insert),
+			fmt.Sprintf("CREATE TRIGGER search_%[1]s_update AFTER UPDATE ON %[1]s BEGIN %[2]s %[3]s END;", s.table, remove, insert),
+			fmt.Sprintf("CREATE TRIGGER search_%[1]s_delete AFTER DELETE ON %[1]s BEGIN %[2]s END;", s.table, remove),
+		}
+		for _, q := range queries {
+			if _, err := tx.Exec(q); err != nil {
+				return fmt.Errorf("search index %s error: %v", s.table, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+type SearchResult struct {
+	Type    string  `json:"type"` // employee, project, network, material or planpage
+	Id      int64   `json:"id,string"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"` // the matching part of the body
+	Rank    float64 `json:"rank"`    // bm25, lower is a better match
+}
+
+// every word of the text as a prefix, so "jo sm" finds John Smith while it
+// is typed. Quotes keep fts5 operators and punctuation (ex: the dots of a
+// charge number) from being read as query syntax.
+func searchMatch(text string) string {
+	var terms []string
+	for _, f := range strings.Fields(text) {
+		f = strings.ReplaceAll(f, `"`, "")
+		if strings.IndexFunc(f, isWordRune) < 0 {
+			continue
+		}
+		terms = append(terms, `"`+f+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r > 127
+}
+
+// the best matches first, titles weigh more than the body. No types
+// searches all of them.
+func Search(ctx context.Context, db *sql.DB, text string, types []string, limit int) ([]SearchResult, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	results := []SearchResult{}
+	match := searchMatch(text)
+	if len(match) == 0 {
+		return results, nil
+	}
+
+	available, err := SearchAvailable(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if !available {
+		return nil, ErrSearchUnavailable
+	}
+
+	query := `
+	SELECT kind,row_id,title,snippet(SearchIndex,3,'','','…',8),bm25(SearchIndex,0,0,10,1) AS rank
+	FROM SearchIndex
+	WHERE SearchIndex MATCH ?`
+	args := []any{match}
+	if len(types) > 0 {
+		query += " AND kind IN (?" + strings.Repeat(",?", len(types)-1) + ")"
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	query += " ORDER BY rank LIMIT ?;"
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search query error: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.Type, &r.Id, &r.Title, &r.Snippet, &r.Rank); err != nil {
+			return nil, fmt.Errorf("search scan error: %v", err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// application login. Role is one of viewer, planner, finance or admin and
+// EmpId optionally links the login to an Employee record. SSO users are
+// created by single sign-on for their employee and have no password.
+type User struct {
+	Id           int64     `json:"id,string"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	EmpId        NullInt64 `json:"emp_id"`
+	Active       bool      `json:"active"`
+	SSO          bool      `json:"sso"`
+}
+
+func NewUser() User {
+	return User{Role: "viewer", Active: true}
+}
+
+func (u User) Init(db *sql.DB) error {
+	tableQuery := `
+	CREATE TABLE IF NOT EXISTS User (
+		id INTEGER PRIMARY KEY,
+		username TEXT UNIQUE NOT NULL COLLATE NOCASE,
+		password_hash TEXT NOT NULL DEFAULT '',
+		role TEXT NOT NULL DEFAULT 'viewer' CHECK (role IN ('viewer','planner','finance','admin')),
+		emp_id INTEGER DEFAULT NULL,
+		active INTEGER DEFAULT 1,
+		sso INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (emp_id) REFERENCES Employee(id) ON DELETE SET NULL
+	);
+	`
+	sessionQuery := `
+	CREATE TABLE IF NOT EXISTS Session (
+		token TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		expires INTEGER NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES User(id) ON DELETE CASCADE
+	);
+	`
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(tableQuery)
+	if err != nil {
+		return fmt.Errorf("error executing CREATE TABLE transaction: %v", err)
+	}
+
+	_, err = tx.Exec(sessionQuery)
+	if err != nil {
+		return fmt.Errorf("error executing CREATE TABLE transaction: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}
+
+const userColumns = "id,username,password_hash,role,emp_id,active,sso"
+
+func scanUser(row interface{ Scan(...any) error }, u *User) error {
+	return row.Scan(&u.Id, &u.Username, &u.PasswordHash, &u.Role, &u.EmpId, &u.Active, &u.SSO)
+}
+
+func GetUser(ctx context.Context, db *sql.DB, id int64) (User, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var u User
+
+	row := db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM User WHERE id=?;", id)
+	if err := scanUser(row, &u); err != nil {
+		if err == sql.ErrNoRows {
+			return u, fmt.Errorf("user id=%d: no such row: %w", id, dbError(err))
+		}
+		return u, fmt.Errorf("row scan error: %v", err)
+	}
+	return u, nil
+}
+
+func GetUserByName(ctx context.Context, db *sql.DB, username string) (User, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var u User
+
+	row := db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM User WHERE username=?;", username)
+	if err := scanUser(row, &u); err != nil {
+		if err == sql.ErrNoRows {
+			return u, fmt.Errorf("user %s: no such row: %w", username, dbError(err))
+		}
+		return u, fmt.Errorf("row scan error: %v", err)
+	}
+	return u, nil
+}
+
+// the single sign-on user of the employee
+func GetSSOUser(ctx context.Context, db *sql.DB, empId int64) (User, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var u User
+
+	row := db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM User WHERE sso=1 AND emp_id=?;", empId)
+	if err := scanUser(row, &u); err != nil {
+		if err == sql.ErrNoRows {
+			return u, fmt.Errorf("sso user emp_id=%d: no such row: %w", empId, dbError(err))
+		}
+		return u, fmt.Errorf("row scan error: %v", err)
+	}
+	return u, nil
+}
+
+func AllUsers(ctx context.Context, db *sql.DB) ([]User, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var users []User
+
+	rows, err := db.QueryContext(ctx, "SELECT "+userColumns+" FROM User ORDER BY username;")
+	if err != nil {
+		return nil, fmt.Errorf("query error: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u User
+		if err := scanUser(rows, &u); err != nil {
+			return nil, fmt.Errorf("row scan error: %v", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %v", err)
+	}
+	return users, nil
+}
+
+func CountUsers(ctx context.Context, db *sql.DB) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var n int64
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM User;").Scan(&n); err != nil {
+		return 0, fmt.Errorf("count query error: %v", err)
+	}
+	return n, nil
+}
+
+func InsertUser(ctx context.Context, db *sql.DB, u User) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	insertQuery := `
+	INSERT INTO User (username,password_hash,role,emp_id,active,sso) VALUES (?, ?, ?, ?, ?, ?);
+	`
+
+	result, err := execWrite(ctx, db, insertQuery, u.Username, u.PasswordHash, u.Role, u.EmpId, u.Active, u.SSO)
+	if err != nil {
+		return 0, fmt.Errorf("insert query error: %w", dbError(err))
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("insert result error: %v", err)
+	}
+	return id, nil
+}
+
+// updates everything except the password hash (see SetPassword) and sso
+func UpdateUser(ctx context.Context, db *sql.DB, u User) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	updateQuery := `
+	UPDATE User SET username=?, role=?, emp_id=?, active=? WHERE id=?;
+	`
+
+	result, err := execWrite(ctx, db, updateQuery, u.Username, u.Role, u.EmpId, u.Active, u.Id)
+	if err != nil {
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("update result error: %v", err)
+	}
+	return rows, nil
+}
+
+// replaces the password hash and signs the user out everywhere
+func SetPassword(ctx context.Context, db *sql.DB, id int64, hash string) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	tx, err := beginWrite(ctx, db)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE User SET password_hash=? WHERE id=?;", hash, id)
+	if err != nil {
+		return 0, fmt.Errorf("update query error: %w", dbError(err))
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("update result error: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Session WHERE user_id=?;", id); err != nil {
+		return 0, fmt.Errorf("delete query error: %w", dbError(err))
+	}
+
+	if err := commitWrite(ctx, tx); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return rows, nil
+}
+
+func InsertSession(ctx context.Context, db *sql.DB, token string, userId int64, expires time.Time) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	insertQuery := `
+	INSERT INTO Session (token,user_id,expires) VALUES (?, ?, ?);
+	`
+
+	if _, err := db.ExecContext(ctx, insertQuery, token, userId, expires.Unix()); err != nil {
+		return fmt.Errorf("insert query error: %w", dbError(err))
+	}
+	return nil
+}
+
+// active user for an unexpired session token
+func GetSessionUser(ctx context.Context, db *sql.DB, token string) (User, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var u User
+
+	getQuery := `
+	SELECT u.id,u.username,u.password_hash,u.role,u.emp_id,u.active,u.sso
+	FROM Session s
+	  INNER JOIN User u ON u.id = s.user_id
+	WHERE s.token=? AND s.expires > ? AND u.active=1;
+	`
+
+	row := db.QueryRowContext(ctx, getQuery, token, time.Now().Unix())
+	if err := scanUser(row, &u); err != nil {
+		if err == sql.ErrNoRows {
+			return u, fmt.Errorf("session: no such row: %w", dbError(err))
+		}
+		return u, fmt.Errorf("row scan error: %v", err)
+	}
+	return u, nil
+}
+
+func DeleteSession(ctx context.Context, db *sql.DB, token string) error {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM Session WHERE token=?;", token); err != nil {
+		return fmt.Errorf("delete query error: %w", dbError(err))
+	}
+	return nil
+}
+
+func DeleteExpiredSessions(ctx context.Context, db *sql.DB) (int64, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, "DELETE FROM Session WHERE expires <= ?;", time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("delete query error: %w", dbError(err))
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("delete result error: %v", err)
+	}
+	return rows, nil
+}
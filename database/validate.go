@@ -0,0 +1,125 @@
+package database
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// what is wrong with each invalid field, keyed by its json (and form) name.
+// An ErrValidation error.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := slices.Sorted(maps.Keys(e))
+	msgs := make([]string, len(fields))
+	for i, f := range fields {
+		msgs[i] = f + ": " + e[f]
+	}
+	return "invalid " + strings.Join(msgs, ", ")
+}
+
+func (e FieldErrors) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// the first message added for a field is kept
+func (e FieldErrors) Add(field, msg string) {
+	if _, ok := e[field]; !ok {
+		e[field] = msg
+	}
+}
+
+// nil without any field errors
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// a check of one field, the entities' Validate methods are lists of them.
+// An empty msg passes.
+type rule struct {
+	field string
+	msg   string
+}
+
+func validate(rules ...rule) error {
+	errs := FieldErrors{}
+	for _, r := range rules {
+		if len(r.msg) > 0 {
+			errs.Add(r.field, r.msg)
+		}
+	}
+	return errs.Err()
+}
+
+func required(field, value string) rule {
+	if len(strings.TrimSpace(value)) == 0 {
+		return rule{field, "required"}
+	}
+	return rule{}
+}
+
+// optional, yyyy-mm-dd when set
+func date(field, value string) rule {
+	if len(value) == 0 {
+		return rule{}
+	}
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		return rule{field, "not a date (yyyy-mm-dd)"}
+	}
+	return rule{}
+}
+
+// the end date isn't before the start, checked when both are valid dates
+func dateOrder(startField, start, endField, end string) rule {
+	s, err := time.Parse(time.DateOnly, start)
+	if err != nil {
+		return rule{}
+	}
+	e, err := time.Parse(time.DateOnly, end)
+	if err != nil {
+		return rule{}
+	}
+	if e.Before(s) {
+		return rule{endField, "can't be before " + startField}
+	}
+	return rule{}
+}
+
+func positive(field string, value float64) rule {
+	if value <= 0 {
+		return rule{field, "must be greater than 0"}
+	}
+	return rule{}
+}
+
+func notNegative(field string, value float64) rule {
+	if value < 0 {
+		return rule{field, "can't be negative"}
+	}
+	return rule{}
+}
+
+// in (0,1], ex: a labor capacity
+func fraction(field string, value float64) rule {
+	if value <= 0 || value > 1 {
+		return rule{field, "must be greater than 0 and at most 1"}
+	}
+	return rule{}
+}
+
+// letters and digits in groups split by single dots or dashes, ex: 1234.5678
+// or AB-1001.20
+var chargeNumberFormat = regexp.MustCompile(`^[A-Za-z0-9]+([.-][A-Za-z0-9]+)*$`)
+
+func chargeNumber(field, value string) rule {
+	if len(value) > 0 && !chargeNumberFormat.MatchString(value) {
+		return rule{field, "letters and digits split by . or - (ex: 1234.5678)"}
+	}
+	return rule{}
+}
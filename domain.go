@@ -3,13 +3,23 @@ package main
 import (
 	"database/sql"
 	"html/template"
-	"log"
 	"net/http"
+	"strings"
 
+	"github.com/james-mcallister/may/api"
+	"github.com/james-mcallister/may/audit"
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/config"
 	"github.com/james-mcallister/may/database"
 	"github.com/james-mcallister/may/entity"
 	"github.com/james-mcallister/may/form"
+	"github.com/james-mcallister/may/health"
+	"github.com/james-mcallister/may/live"
+	"github.com/james-mcallister/may/logging"
+	"github.com/james-mcallister/may/metrics"
+	"github.com/james-mcallister/may/openapi"
 	"github.com/james-mcallister/may/plan"
+	"github.com/james-mcallister/may/report"
 )
 
 type MayPage interface {
@@ -19,10 +29,11 @@ type MayPage interface {
 type Domain struct {
 	db        *sql.DB
 	templates *template.Template
+	hub       *live.Hub // plan page changes for the open pages
 }
 
-func NewDomain() Domain {
-	d, err := database.NewDB()
+func NewDomain(dbPath string) Domain {
+	d, err := database.NewDB(dbPath)
 	if err != nil {
 		panic(err)
 	}
@@ -33,7 +44,8 @@ func NewDomain() Domain {
 	}
 
 	return Domain{
-		db: dbConn,
+		db:  dbConn,
+		hub: live.NewHub(),
 	}
 }
 
@@ -49,76 +61,232 @@ func home(arg string) http.HandlerFunc {
 	})
 }
 
-func Logger(logger *log.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			logger.Printf("%s %s", r.Method, r.URL)
-			next.ServeHTTP(w, r)
-		})
+// records the method patterns registered on a mux (with the prefix it is
+// mounted under) so the openapi document is generated from the real routes
+type routeMux struct {
+	*http.ServeMux
+	prefix   string
+	patterns *[]string
+	changes  func(http.Handler) http.Handler // wraps POST, PUT and DELETE routes
+}
+
+func newRouteMux(mux *http.ServeMux, prefix string, patterns *[]string) routeMux {
+	return routeMux{ServeMux: mux, prefix: prefix, patterns: patterns}
+}
+
+func (m routeMux) Handle(pattern string, handler http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		m.ServeMux.Handle(pattern, handler)
+		return
+	}
+
+	full := method + " " + m.prefix + path
+	*m.patterns = append(*m.patterns, full)
+	if m.changes != nil && method != http.MethodGet && method != http.MethodHead {
+		handler = m.changes(handler)
 	}
+	m.ServeMux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.SetRoute(r.Context(), full)
+		handler.ServeHTTP(w, r)
+	}))
 }
 
-func initRoutes(mux *http.ServeMux, logger *log.Logger, d Domain) {
-	middlewareLog := Logger(logger)
-
-	mux.Handle("GET /home/", middlewareLog(home("Test")))
-
-	mux.Handle("GET /employees/", middlewareLog(entity.Employees(d.templates, d.db)))
-	mux.Handle("POST /employees/", middlewareLog(form.NewEmployee(d.db)))
-	mux.Handle("GET /employees/{id}/", middlewareLog(form.Employee(d.templates, d.db)))
-	mux.Handle("PUT /employees/{id}/", middlewareLog(form.UpdateEmployee(d.db)))
-	mux.Handle("DELETE /employees/{id}/", middlewareLog(form.DeleteEmployee(d.db)))
-
-	mux.Handle("GET /compensation/", middlewareLog(entity.Compensation(d.templates, d.db)))
-	mux.Handle("POST /compensation/", middlewareLog(form.NewCompensation(d.db)))
-	mux.Handle("GET /compensation/{id}/", middlewareLog(form.Compensation(d.templates, d.db)))
-	mux.Handle("PUT /compensation/{id}/", middlewareLog(form.UpdateCompensation(d.db)))
-	mux.Handle("DELETE /compensation/{id}/", middlewareLog(form.DeleteCompensation(d.db)))
-
-	mux.Handle("GET /ipts/", middlewareLog(entity.Ipts(d.templates, d.db)))
-	mux.Handle("POST /ipts/", middlewareLog(form.NewIpt(d.db)))
-	mux.Handle("GET /ipts/{id}/", middlewareLog(form.Ipt(d.templates, d.db)))
-	mux.Handle("PUT /ipts/{id}/", middlewareLog(form.UpdateIpt(d.db)))
-	mux.Handle("DELETE /ipts/{id}/", middlewareLog(form.DeleteIpt(d.db)))
-
-	mux.Handle("GET /material/", middlewareLog(entity.Material(d.templates, d.db)))
-	mux.Handle("POST /material/", middlewareLog(form.NewMaterial(d.db)))
-	mux.Handle("GET /material/{id}/", middlewareLog(form.Material(d.templates, d.db)))
-	mux.Handle("PUT /material/{id}/", middlewareLog(form.UpdateMaterial(d.db)))
-	mux.Handle("DELETE /material/{id}/", middlewareLog(form.DeleteMaterial(d.db)))
-
-	mux.Handle("GET /networks/", middlewareLog(entity.Networks(d.templates, d.db)))
-	mux.Handle("POST /networks/", middlewareLog(form.NewNetwork(d.db)))
-	mux.Handle("GET /networks/{id}/", middlewareLog(form.Network(d.templates, d.db)))
-	mux.Handle("PUT /networks/{id}/", middlewareLog(form.UpdateNetwork(d.db)))
-	mux.Handle("DELETE /networks/{id}/", middlewareLog(form.DeleteNetwork(d.db)))
-
-	mux.Handle("GET /projects/", middlewareLog(entity.Projects(d.templates, d.db)))
-	mux.Handle("POST /projects/", middlewareLog(form.NewProject(d.db)))
-	mux.Handle("GET /projects/{id}/", middlewareLog(form.Project(d.templates, d.db)))
-	mux.Handle("PUT /projects/{id}/", middlewareLog(form.UpdateProject(d.db)))
-	mux.Handle("DELETE /projects/{id}/", middlewareLog(form.DeleteProject(d.db)))
-
-	mux.Handle("GET /plan/", middlewareLog(plan.Select(d.templates, d.db)))
-	mux.Handle("PUT /plan/", middlewareLog(plan.New(d.templates, d.db)))
-	mux.Handle("POST /plan/", middlewareLog(plan.Page(d.templates, d.db)))
-
-	evmsMux := http.NewServeMux()
-	evmsMux.Handle("POST /plan", middlewareLog(plan.NewPlanTable(d.templates, d.db)))
-	evmsMux.Handle("GET /plan", middlewareLog(plan.NewPlanForm(d.templates, d.db)))
-	evmsMux.Handle("GET /cal", middlewareLog(plan.Calendar(d.templates, d.db)))
+func initRoutes(rootMux *http.ServeMux, d Domain, cfg config.Config) {
+	var patterns []string
+	mux := newRouteMux(rootMux, "", &patterns)
+	mux.changes = audit.Middleware(audit.UI)
+
+	a := auth.New(d.db)
+	view := a.Require(auth.AllRoles...)
+	edit := a.Require(auth.Planner)
+	finance := a.Require(auth.Finance)
+	admin := a.Require(auth.Admin)
+	// anyone who can delete records, restores are checked per record
+	deleter := a.Require(auth.Planner, auth.Finance)
+
+	if cfg.OIDC.Enabled() {
+		a.SSO = true
+		o := auth.NewOIDC(a, cfg.OIDC)
+		mux.Handle("GET /login/oidc", o.Start())
+		mux.Handle("GET /login/oidc/callback", o.Callback(d.templates))
+	}
+
+	mux.Handle("GET /login", auth.LoginPage(d.templates, a))
+	mux.Handle("POST /login", auth.Login(d.templates, a))
+	mux.Handle("POST /logout", auth.Logout(a))
+
+	mux.Handle("GET /healthz", health.Healthz())
+	mux.Handle("GET /readyz", health.Readyz(d.db))
+	if cfg.Features.Metrics {
+		health.RegisterMetrics(d.db)
+		mux.Handle("GET /metrics", a.RequireToken(cfg.MetricsToken, auth.Admin)(metrics.Handler()))
+	}
+
+	mux.Handle("GET /home/", view(home("Test")))
+
+	mux.Handle("GET /employees/", view(entity.Employees(d.templates, d.db)))
+	mux.Handle("POST /employees/", edit(form.NewEmployee(d.templates, d.db)))
+	mux.Handle("GET /employees/{id}/", view(form.Employee(d.templates, d.db)))
+	mux.Handle("PUT /employees/{id}/", edit(form.UpdateEmployee(d.templates, d.db)))
+	mux.Handle("DELETE /employees/{id}/", edit(form.DeleteEmployee(d.db)))
+
+	mux.Handle("GET /compensation/", finance(entity.Compensation(d.templates, d.db)))
+	mux.Handle("POST /compensation/", finance(form.NewCompensation(d.templates, d.db)))
+	mux.Handle("GET /compensation/{id}/", finance(form.Compensation(d.templates, d.db)))
+	mux.Handle("PUT /compensation/{id}/", finance(form.UpdateCompensation(d.templates, d.db)))
+	mux.Handle("DELETE /compensation/{id}/", finance(form.DeleteCompensation(d.db)))
+
+	mux.Handle("GET /ipts/", view(entity.Ipts(d.templates, d.db)))
+	mux.Handle("POST /ipts/", edit(form.NewIpt(d.templates, d.db)))
+	mux.Handle("GET /ipts/{id}/", view(form.Ipt(d.templates, d.db)))
+	mux.Handle("PUT /ipts/{id}/", edit(form.UpdateIpt(d.templates, d.db)))
+	mux.Handle("DELETE /ipts/{id}/", edit(form.DeleteIpt(d.db)))
+
+	mux.Handle("GET /material/", view(entity.Material(d.templates, d.db)))
+	mux.Handle("POST /material/", edit(form.NewMaterial(d.templates, d.db)))
+	mux.Handle("GET /material/{id}/", view(form.Material(d.templates, d.db)))
+	mux.Handle("PUT /material/{id}/", edit(form.UpdateMaterial(d.templates, d.db)))
+	mux.Handle("DELETE /material/{id}/", edit(form.DeleteMaterial(d.db)))
+
+	mux.Handle("GET /networks/", view(entity.Networks(d.templates, d.db)))
+	mux.Handle("POST /networks/", edit(form.NewNetwork(d.templates, d.db)))
+	mux.Handle("GET /networks/{id}/", view(form.Network(d.templates, d.db)))
+	mux.Handle("PUT /networks/{id}/", edit(form.UpdateNetwork(d.templates, d.db)))
+	mux.Handle("DELETE /networks/{id}/", edit(form.DeleteNetwork(d.db)))
+
+	mux.Handle("GET /projects/", view(entity.Projects(d.templates, d.db)))
+	mux.Handle("POST /projects/", edit(form.NewProject(d.templates, d.db)))
+	mux.Handle("GET /projects/{id}/", view(form.Project(d.templates, d.db)))
+	mux.Handle("PUT /projects/{id}/", edit(form.UpdateProject(d.templates, d.db)))
+	mux.Handle("DELETE /projects/{id}/", edit(form.DeleteProject(d.db)))
+
+	mux.Handle("GET /audit/", admin(entity.Audit(d.templates, d.db)))
+
+	mux.Handle("GET /recycle/", deleter(entity.RecycleBin(d.templates, d.db)))
+	mux.Handle("POST /recycle/{id}/restore", deleter(api.RestoreRecycled(d.db)))
+	mux.Handle("DELETE /recycle/{id}/", admin(api.PurgeRecycled(d.db)))
+	mux.Handle("DELETE /recycle/", admin(api.PurgeAllRecycled(d.db)))
+
+	mux.Handle("GET /plan/", view(plan.Select(d.templates, d.db)))
+	mux.Handle("PUT /plan/", edit(plan.New(d.templates, d.db)))
+	mux.Handle("POST /plan/", view(plan.Page(d.templates, d.db)))
+
+	evmsMux := newRouteMux(http.NewServeMux(), "/evms", &patterns)
+	evmsMux.changes = audit.Middleware(audit.UI)
+	evmsMux.Handle("POST /plan", edit(plan.NewPlanTable(d.templates, d.db)))
+	evmsMux.Handle("GET /plan", view(plan.NewPlanForm(d.templates, d.db)))
+	evmsMux.Handle("GET /cal", view(plan.Calendar(d.templates, d.db)))
 
 	mux.Handle("/evms/", http.StripPrefix("/evms", evmsMux))
 
-	apiMux := http.NewServeMux()
-	apiMux.Handle("GET /prodhours", middlewareLog(plan.ProdHours(d.db)))
-	apiMux.Handle("GET /prodhoursidx", middlewareLog(plan.ProdHoursIdx(d.db)))
-	apiMux.Handle("GET /newrow", middlewareLog(plan.NewPlanRowForm(d.templates, d.db)))
-	apiMux.Handle("GET /planhours", middlewareLog(plan.PlanHours(d.db)))
-	apiMux.Handle("GET /planrow", middlewareLog(plan.PlanRow(d.templates, d.db)))
-	apiMux.Handle("POST /planrow", middlewareLog(plan.NewPlanRow(d.db)))
-	apiMux.Handle("DELETE /planrow", middlewareLog(plan.DeleteRow(d.db)))
-	apiMux.Handle("PUT /planrow", middlewareLog(plan.UpdateRow(d.db)))
+	apiMux := newRouteMux(http.NewServeMux(), "/api", &patterns)
+	apiMux.changes = audit.Middleware(audit.UI)
+	apiMux.Handle("GET /prodhours", view(plan.ProdHours(d.db)))
+	apiMux.Handle("GET /prodhoursidx", view(plan.ProdHoursIdx(d.db)))
+	apiMux.Handle("GET /newrow", view(plan.NewPlanRowForm(d.templates, d.db)))
+	apiMux.Handle("GET /planhours", view(plan.PlanHours(d.db)))
+	apiMux.Handle("GET /planrow", view(plan.PlanRow(d.templates, d.db)))
+	apiMux.Handle("POST /planrow", edit(plan.NewPlanRow(d.db, d.hub)))
+	apiMux.Handle("DELETE /planrow", edit(plan.DeleteRow(d.db, d.hub)))
+	apiMux.Handle("PUT /planrow", edit(plan.UpdateRow(d.db, d.hub)))
+	apiMux.Handle("GET /journal", view(plan.Journal(d.db)))
+	apiMux.Handle("POST /undo", edit(plan.Undo(d.db, d.hub)))
+	apiMux.Handle("POST /redo", edit(plan.Redo(d.db, d.hub)))
+	apiMux.Handle("GET /events", view(plan.Events(d.hub)))
+	apiMux.Handle("GET /org", view(report.OrgChart(d.db)))
+	apiMux.Handle("GET /org/rollup", view(report.OrgRollup(d.db)))
+	apiMux.Handle("GET /ipt/rollup", view(report.IptRollup(d.db)))
+	apiMux.Handle("GET /ipt/{id}/employees", view(report.IptEmployees(d.db)))
+
+	apiMux.Handle("GET /me", view(auth.Me()))
+	apiMux.Handle("PUT /me/password", view(api.ChangePassword(d.db)))
+	if cfg.Features.APIDocs {
+		apiMux.Handle("GET /openapi.json", view(openapi.Spec(&patterns)))
+		apiMux.Handle("GET /docs", view(openapi.Viewer(d.templates, "/api/openapi.json")))
+	}
+
+	// off, the v1 routes aren't served or documented
+	if cfg.Features.APIv1 {
+		v1Mux := newRouteMux(http.NewServeMux(), "/api/v1", &patterns)
+		v1Mux.changes = audit.Middleware(audit.API)
+		v1Mux.Handle("GET /employees", view(api.Employees(d.db)))
+		v1Mux.Handle("GET /employees/lookup", view(api.Lookup(d.db, "employees")))
+		v1Mux.Handle("POST /employees", edit(api.NewEmployee(d.db)))
+		v1Mux.Handle("GET /employees/{id}", view(api.Employee(d.db)))
+		v1Mux.Handle("PUT /employees/{id}", edit(api.UpdateEmployee(d.db)))
+		v1Mux.Handle("DELETE /employees/{id}", edit(api.DeleteEmployee(d.db)))
+
+		v1Mux.Handle("GET /compensation", finance(api.AllCompensation(d.db)))
+		v1Mux.Handle("GET /compensation/lookup", finance(api.Lookup(d.db, "compensation")))
+		v1Mux.Handle("POST /compensation", finance(api.NewCompensation(d.db)))
+		v1Mux.Handle("GET /compensation/{id}", finance(api.Compensation(d.db)))
+		v1Mux.Handle("PUT /compensation/{id}", finance(api.UpdateCompensation(d.db)))
+		v1Mux.Handle("DELETE /compensation/{id}", finance(api.DeleteCompensation(d.db)))
+
+		v1Mux.Handle("GET /ipts", view(api.Ipts(d.db)))
+		v1Mux.Handle("GET /ipts/lookup", view(api.Lookup(d.db, "ipts")))
+		v1Mux.Handle("POST /ipts", edit(api.NewIpt(d.db)))
+		v1Mux.Handle("GET /ipts/{id}", view(api.Ipt(d.db)))
+		v1Mux.Handle("PUT /ipts/{id}", edit(api.UpdateIpt(d.db)))
+		v1Mux.Handle("DELETE /ipts/{id}", edit(api.DeleteIpt(d.db)))
+
+		v1Mux.Handle("GET /material", view(api.Materials(d.db)))
+		v1Mux.Handle("GET /material/lookup", view(api.Lookup(d.db, "material")))
+		v1Mux.Handle("POST /material", edit(api.NewMaterial(d.db)))
+		v1Mux.Handle("GET /material/{id}", view(api.Material(d.db)))
+		v1Mux.Handle("PUT /material/{id}", edit(api.UpdateMaterial(d.db)))
+		v1Mux.Handle("DELETE /material/{id}", edit(api.DeleteMaterial(d.db)))
+
+		v1Mux.Handle("GET /networks", view(api.Networks(d.db)))
+		v1Mux.Handle("GET /networks/lookup", view(api.Lookup(d.db, "networks")))
+		v1Mux.Handle("POST /networks", edit(api.NewNetwork(d.db)))
+		v1Mux.Handle("GET /networks/{id}", view(api.Network(d.db)))
+		v1Mux.Handle("PUT /networks/{id}", edit(api.UpdateNetwork(d.db)))
+		v1Mux.Handle("DELETE /networks/{id}", edit(api.DeleteNetwork(d.db)))
+
+		v1Mux.Handle("GET /projects", view(api.Projects(d.db)))
+		v1Mux.Handle("GET /projects/lookup", view(api.Lookup(d.db, "projects")))
+		v1Mux.Handle("POST /projects", edit(api.NewProject(d.db)))
+		v1Mux.Handle("GET /projects/{id}", view(api.Project(d.db)))
+		v1Mux.Handle("PUT /projects/{id}", edit(api.UpdateProject(d.db)))
+		v1Mux.Handle("DELETE /projects/{id}", edit(api.DeleteProject(d.db)))
+
+		v1Mux.Handle("GET /calendars", view(api.Calendars(d.db)))
+		v1Mux.Handle("GET /calendars/lookup", view(api.Lookup(d.db, "calendars")))
+		v1Mux.Handle("POST /calendars", finance(api.NewCalendar(d.db)))
+		v1Mux.Handle("GET /calendars/{id}", view(api.Calendar(d.db)))
+		v1Mux.Handle("PUT /calendars/{id}", finance(api.UpdateCalendar(d.db)))
+		v1Mux.Handle("DELETE /calendars/{id}", finance(api.DeleteCalendar(d.db)))
+
+		v1Mux.Handle("GET /planpages", view(api.PlanPages(d.db)))
+		v1Mux.Handle("GET /planpages/lookup", view(api.Lookup(d.db, "planpages")))
+		v1Mux.Handle("POST /planpages", edit(api.NewPlanPage(d.db)))
+		v1Mux.Handle("GET /planpages/{id}", view(api.PlanPage(d.db)))
+		v1Mux.Handle("PUT /planpages/{id}", edit(api.UpdatePlanPage(d.db, d.hub)))
+		v1Mux.Handle("DELETE /planpages/{id}", edit(api.DeletePlanPage(d.db)))
+
+		v1Mux.Handle("GET /users", admin(api.Users(d.db)))
+		v1Mux.Handle("POST /users", admin(api.NewUser(d.db)))
+		v1Mux.Handle("GET /users/{id}", admin(api.User(d.db)))
+		v1Mux.Handle("PUT /users/{id}", admin(api.UpdateUser(d.db)))
+		v1Mux.Handle("DELETE /users/{id}", admin(api.DeleteUser(d.db)))
+
+		v1Mux.Handle("GET /audit", admin(api.AuditLog(d.db)))
+		v1Mux.Handle("GET /search", view(api.Search(d.db)))
+
+		v1Mux.Handle("GET /recycle", deleter(api.RecycleBin(d.db)))
+		v1Mux.Handle("POST /recycle/{id}/restore", deleter(api.RestoreRecycled(d.db)))
+		v1Mux.Handle("DELETE /recycle/{id}", admin(api.PurgeRecycled(d.db)))
+		v1Mux.Handle("DELETE /recycle", admin(api.PurgeAllRecycled(d.db)))
+
+		v1Mux.Handle("GET /backups", admin(api.Backups(cfg.BackupDir)))
+		v1Mux.Handle("POST /backups", admin(api.NewBackup(d.db, cfg.BackupDir, cfg.BackupKeep)))
+		v1Mux.Handle("GET /backups/{name}", admin(api.DownloadBackup(cfg.BackupDir)))
+		v1Mux.Handle("POST /backups/{name}/restore", admin(api.RestoreBackup(d.db, cfg.BackupDir)))
+
+		apiMux.Handle("/v1/", http.StripPrefix("/v1", v1Mux))
+	}
 
 	mux.Handle("/api/", http.StripPrefix("/api", apiMux))
 }
@@ -0,0 +1,61 @@
+package entity
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+type EntityAudit struct {
+	Table   string
+	Row     string
+	Actor   string
+	From    string
+	To      string
+	Entries []database.AuditEntry
+	Total   int64
+	Tables  []string
+}
+
+// the change history, filtered by record (?table=Employee&row=5), user and
+// dates. Shows the newest 200 changes.
+func Audit(t *template.Template, db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		for k, v := range params {
+			if len(v) == 0 || len(v[0]) == 0 {
+				params.Del(k)
+			}
+		}
+		params.Set("order", "desc")
+		params.Set("limit", "200")
+
+		q, err := database.ParseListQuery(params, database.AuditListFields())
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		data := EntityAudit{
+			Table:  params.Get("table"),
+			Row:    params.Get("row"),
+			Actor:  params.Get("actor"),
+			From:   params.Get("from"),
+			To:     params.Get("to"),
+			Tables: database.AuditedTables(),
+		}
+		data.Entries, data.Total, err = database.AuditLog(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		if err = t.ExecuteTemplate(w, "entity-audit.html", data); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+	})
+}
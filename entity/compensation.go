@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type EntityCompensation struct {
@@ -17,14 +18,14 @@ func Compensation(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 
 		data := EntityCompensation{}
-		data.Comps, err = database.AllCompensation(db)
+		data.Comps, err = database.AllCompensation(r.Context(), db)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "entity-compensation.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
@@ -6,26 +6,53 @@ import (
 	"net/http"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 // TODO: add logic to replace the manager, ipt, and comp IDs with the names
 type EntityEmployee struct {
-	Emps []database.Employee
+	Emps         []database.Employee
+	List         ListPage
+	IptDropdown  []database.Dropdown // filter options
+	EmpDropdown  []database.Dropdown
+	CompDropdown []database.Dropdown
 }
 
 func Employees(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
-		data := EntityEmployee{}
-		data.Emps, err = database.AllEmployees(db)
+		q, list, err := listQuery(r, database.EmployeeListFields())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		data := EntityEmployee{List: list}
+		data.Emps, data.List.Total, err = database.AllEmployees(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		data.List.Shown = len(data.Emps)
+		data.IptDropdown, err = database.NewDropdown(r.Context(), db, database.IptDropdownQuery())
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		data.EmpDropdown, err = database.NewDropdown(r.Context(), db, database.EmployeeDropdownQuery())
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		data.CompDropdown, err = database.NewDropdown(r.Context(), db, database.CompensationDropdownQuery())
+		if err != nil {
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "entity-employee.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 // TODO: add logic to replace the manager, ipt, and comp IDs with the names
@@ -18,14 +19,14 @@ func Ipts(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 
 		data := EntityIpt{}
-		data.Ipts, err = database.AllIpts(db)
+		data.Ipts, err = database.AllIpts(r.Context(), db)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "entity-ipt.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
@@ -0,0 +1,93 @@
+package entity
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/james-mcallister/may/database"
+)
+
+// rows on an entity list page unless the request asks for a limit
+const pageSize = 100
+
+// the filter, sort and paging state of an entity list page, rendered back
+// into its controls
+type ListPage struct {
+	Params url.Values // the filters as requested, ex: .Params.Get "active"
+	Sorts  []string
+	Sort   string
+	Desc   bool
+	Limit  int
+	Offset int
+	Shown  int
+	Total  int64
+}
+
+// true when the param was requested with the value, ex: the selected option
+// of a filter dropdown
+func (p ListPage) Is(param string, v any) bool {
+	return p.Params.Get(param) == fmt.Sprint(v)
+}
+
+func (p ListPage) HasPrev() bool {
+	return p.Offset > 0
+}
+
+func (p ListPage) HasNext() bool {
+	return int64(p.Offset+p.Shown) < p.Total
+}
+
+func (p ListPage) Prev() int {
+	return max(p.Offset-p.Limit, 0)
+}
+
+func (p ListPage) Next() int {
+	return p.Offset + p.Limit
+}
+
+// first and last row shown, counting from 1
+func (p ListPage) From() int {
+	if p.Shown == 0 {
+		return 0
+	}
+	return p.Offset + 1
+}
+
+func (p ListPage) To() int {
+	return p.Offset + p.Shown
+}
+
+// parses the list params like the json api. Empty form fields are no
+// filter and a page is pageSize rows.
+func listQuery(r *http.Request, fields database.ListFields) (database.ListQuery, ListPage, error) {
+	params := r.URL.Query()
+	for k, v := range params {
+		if len(v) == 0 || len(v[0]) == 0 {
+			params.Del(k)
+		}
+	}
+	if !params.Has("limit") {
+		params.Set("limit", strconv.Itoa(pageSize))
+	}
+
+	q, err := database.ParseListQuery(params, fields)
+	if err != nil {
+		return q, ListPage{}, err
+	}
+
+	p := ListPage{
+		Params: params,
+		Sort:   q.Sort,
+		Desc:   q.Desc,
+		Limit:  q.Limit,
+		Offset: q.Offset,
+	}
+	for s := range fields.Sorts {
+		p.Sorts = append(p.Sorts, s)
+	}
+	sort.Strings(p.Sorts)
+	return q, p, nil
+}
@@ -6,25 +6,40 @@ import (
 	"net/http"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type EntityMaterial struct {
-	Mats []database.Material
+	Mats         []database.Material
+	List         ListPage
+	ProjDropdown []database.Dropdown // filter options
 }
 
 func Material(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
-		data := EntityMaterial{}
-		data.Mats, err = database.AllMaterials(db)
+		q, list, err := listQuery(r, database.MaterialListFields())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		data := EntityMaterial{List: list}
+		data.Mats, data.List.Total, err = database.AllMaterials(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		data.List.Shown = len(data.Mats)
+		data.ProjDropdown, err = database.NewDropdown(r.Context(), db, database.ProjectDropdownQuery())
+		if err != nil {
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "entity-material.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
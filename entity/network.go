@@ -6,25 +6,40 @@ import (
 	"net/http"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type EntityNetwork struct {
-	Nets []database.Network
+	Nets         []database.Network
+	List         ListPage
+	ProjDropdown []database.Dropdown // filter options
 }
 
 func Networks(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
-		data := EntityNetwork{}
-		data.Nets, err = database.AllNetworks(db)
+		q, list, err := listQuery(r, database.NetworkListFields())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		data := EntityNetwork{List: list}
+		data.Nets, data.List.Total, err = database.AllNetworks(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		data.List.Shown = len(data.Nets)
+		data.ProjDropdown, err = database.NewDropdown(r.Context(), db, database.ProjectDropdownQuery())
+		if err != nil {
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "entity-network.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
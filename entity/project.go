@@ -6,25 +6,40 @@ import (
 	"net/http"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type EntityProject struct {
-	Projs []database.Project
+	Projs        []database.Project
+	List         ListPage
+	ProjDropdown []database.Dropdown // filter options
 }
 
 func Projects(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
-		data := EntityProject{}
-		data.Projs, err = database.AllProjects(db)
+		q, list, err := listQuery(r, database.ProjectListFields())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		data := EntityProject{List: list}
+		data.Projs, data.List.Total, err = database.AllProjects(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		data.List.Shown = len(data.Projs)
+		data.ProjDropdown, err = database.NewDropdown(r.Context(), db, database.ProjectDropdownQuery())
+		if err != nil {
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "entity-project.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
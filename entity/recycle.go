@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+type EntityRecycle struct {
+	Table    string
+	Entries  []database.BinEntry
+	Total    int64
+	Tables   []string
+	CanPurge bool
+}
+
+// deleted records, newest first, with restore and (for admins) purge
+func RecycleBin(t *template.Template, db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if len(params.Get("table")) == 0 {
+			params.Del("table")
+		}
+		params.Set("order", "desc")
+
+		q, err := database.ParseListQuery(params, database.RecycleListFields())
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		data := EntityRecycle{
+			Table:    params.Get("table"),
+			Tables:   database.RecycledTables(),
+			CanPurge: auth.HasRole(r.Context()),
+		}
+		data.Entries, data.Total, err = database.RecycledEntries(r.Context(), db, q)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		if err = t.ExecuteTemplate(w, "entity-recycle.html", data); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+	})
+}
@@ -8,10 +8,12 @@ import (
 	"strconv"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type CompensationForm struct {
-	Comp database.Compensation
+	Comp   database.Compensation
+	Errors database.FieldErrors // of a rejected form, shown next to its inputs
 }
 
 func Compensation(t *template.Template, db *sql.DB) http.Handler {
@@ -19,93 +21,95 @@ func Compensation(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		data := CompensationForm{}
-		if id == 0 {
-			data.Comp = database.Compensation{}
-		} else {
-			data.Comp, err = database.GetCompensation(db, id)
+		c := database.Compensation{}
+		if id != 0 {
+			c, err = database.GetCompensation(r.Context(), db, id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
 		}
 
+		data := CompensationForm{Comp: c}
+
 		if err = t.ExecuteTemplate(w, "form-compensation.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
 }
 
-func NewCompensation(db *sql.DB) http.Handler {
+// the compensation of a posted form, see values
+func readCompensation(v *values) database.Compensation {
+	return database.Compensation{
+		ResourceCode:  v.str("resource_code"),
+		Grade:         v.str("grade"),
+		LaborCategory: v.str("labor_category"),
+		HourlyRate:    v.float("hourly_rate"),
+	}
+}
+
+func NewCompensation(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var err error
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		hRate, err := strconv.ParseFloat(r.FormValue("hourly_rate"), 64)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		v := newValues(r)
+		c := readCompensation(v)
+		if errs := v.check(c.Validate()); errs != nil {
+			data := CompensationForm{Comp: c}
+			data.Errors = errs
+			rejected(w, r, t, "form-compensation.html", data, errs)
 			return
 		}
 
-		c := database.Compensation{
-			ResourceCode:  r.FormValue("resource_code"),
-			Grade:         r.FormValue("grade"),
-			LaborCategory: r.FormValue("labor_category"),
-			HourlyRate:    hRate,
-		}
-
-		rows, err := database.InsertCompensation(db, c)
+		id, err := database.InsertCompensation(r.Context(), db, c)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
-		response := fmt.Sprintf("Success: %d rows affected.", rows)
+		response := fmt.Sprintf("Success: created id=%d.", id)
 
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(response))
 	})
 }
 
-func UpdateCompensation(db *sql.DB) http.Handler {
+func UpdateCompensation(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		hRate, err := strconv.ParseFloat(r.FormValue("hourly_rate"), 64)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		v := newValues(r)
+		c := readCompensation(v)
+		c.Id = id
 
-		c := database.Compensation{
-			Id:            id,
-			ResourceCode:  r.FormValue("resource_code"),
-			Grade:         r.FormValue("grade"),
-			LaborCategory: r.FormValue("labor_category"),
-			HourlyRate:    hRate,
+		if errs := v.check(c.Validate()); errs != nil {
+			data := CompensationForm{Comp: c}
+			data.Errors = errs
+			rejected(w, r, t, "form-compensation.html", data, errs)
+			return
 		}
 
-		rows, err := database.UpdateCompensation(db, c)
+		rows, err := database.UpdateCompensation(r.Context(), db, c)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -121,13 +125,13 @@ func DeleteCompensation(db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		rows, err := database.DeleteRow(db, "Compensation", id)
+		rows, err := database.RecycleRow(r.Context(), db, "Compensation", id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
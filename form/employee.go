@@ -1,7 +1,9 @@
 package form
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -9,6 +11,7 @@ import (
 	"time"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type EmployeeForm struct {
@@ -16,6 +19,26 @@ type EmployeeForm struct {
 	EmpDropdown  []database.Dropdown
 	IptDropdown  []database.Dropdown
 	CompDropdown []database.Dropdown
+	Errors       database.FieldErrors // of a rejected form, shown next to its inputs
+}
+
+// the form showing the employee with its dropdowns
+func employeeForm(ctx context.Context, db *sql.DB, e database.Employee) (EmployeeForm, error) {
+	var err error
+	data := EmployeeForm{Emp: e}
+	data.EmpDropdown, err = database.NewDropdown(ctx, db, database.EmployeeDropdownQuery())
+	if err != nil {
+		return data, err
+	}
+	data.IptDropdown, err = database.NewDropdown(ctx, db, database.IptDropdownQuery())
+	if err != nil {
+		return data, err
+	}
+	data.CompDropdown, err = database.NewDropdown(ctx, db, database.CompensationDropdownQuery())
+	if err != nil {
+		return data, err
+	}
+	return data, nil
 }
 
 func Employee(t *template.Template, db *sql.DB) http.Handler {
@@ -23,191 +46,131 @@ func Employee(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		data := EmployeeForm{}
-		if id == 0 {
-			currentTime := time.Now()
-			data.Emp = database.Employee{
-				Id:            0,
-				LaborCapacity: 1.0,
-				Active:        true,
-				CoverageStart: currentTime.Format("2006-01-02"),
-				CoverageEnd:   "2040-12-28",
-			}
-		} else {
-			data.Emp, err = database.GetEmployee(db, id)
+		e := database.Employee{
+			LaborCapacity: 1.0,
+			Active:        true,
+			CoverageStart: time.Now().Format("2006-01-02"),
+			CoverageEnd:   "2040-12-28",
+		}
+		if id != 0 {
+			e, err = database.GetEmployee(r.Context(), db, id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
 		}
-		data.EmpDropdown, err = database.NewDropdown(db, database.EmployeeDropdownQuery())
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		data.IptDropdown, err = database.NewDropdown(db, database.IptDropdownQuery())
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		data.CompDropdown, err = database.NewDropdown(db, database.CompensationDropdownQuery())
+
+		data, err := employeeForm(r.Context(), db, e)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "form-employee.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
 }
 
-func NewEmployee(db *sql.DB) http.Handler {
+// the employee of a posted form, see values
+func readEmployee(v *values) database.Employee {
+	return database.Employee{
+		FirstName:     v.str("first_name"),
+		LastName:      v.str("last_name"),
+		Myid:          v.str("myid"),
+		DisplayName:   v.str("last_name") + ", " + v.str("first_name") + " (" + v.str("myid") + ")",
+		Empid:         v.str("empid"),
+		LaborCapacity: v.float("labor_cap"),
+		Desk:          v.str("desk"),
+		Active:        v.str("active") == "on",
+		CoverageStart: v.str("cov_start"),
+		CoverageEnd:   v.str("cov_end"),
+		Comp:          v.id("comp"),
+		Manager:       v.id("manager"),
+		Ipt:           v.id("ipt"),
+	}
+}
+
+func NewEmployee(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var err error
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		var active bool
-		if r.FormValue("active") == "on" {
-			active = true
-		}
-
-		laborCap, err := strconv.ParseFloat(r.FormValue("labor_cap"), 64)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		e := database.Employee{
-			FirstName:     r.FormValue("first_name"),
-			LastName:      r.FormValue("last_name"),
-			Myid:          r.FormValue("myid"),
-			DisplayName:   r.FormValue("last_name") + ", " + r.FormValue("first_name") + " (" + r.FormValue("myid") + ")",
-			Empid:         r.FormValue("empid"),
-			LaborCapacity: laborCap,
-			Desk:          r.FormValue("desk"),
-			Active:        active,
-			CoverageStart: r.FormValue("cov_start"),
-			CoverageEnd:   r.FormValue("cov_end"),
-		}
-
-		if r.PostForm.Has("comp") {
-			v, err := strconv.ParseInt(r.FormValue("comp"), 10, 64)
+		v := newValues(r)
+		e := readEmployee(v)
+		if errs := v.check(e.Validate()); errs != nil {
+			data, err := employeeForm(r.Context(), db, e)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
-			e.Comp = sql.NullInt64{Int64: v, Valid: true}
-		}
-
-		if r.PostForm.Has("manager") {
-			v, err := strconv.ParseInt(r.FormValue("manager"), 10, 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			e.Manager = sql.NullInt64{Int64: v, Valid: true}
-		}
-
-		if r.PostForm.Has("ipt") {
-			v, err := strconv.ParseInt(r.FormValue("ipt"), 10, 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			e.Ipt = sql.NullInt64{Int64: v, Valid: true}
+			data.Errors = errs
+			rejected(w, r, t, "form-employee.html", data, errs)
+			return
 		}
 
-		rows, err := database.InsertEmployee(db, e)
+		id, err := database.InsertEmployee(r.Context(), db, e)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
-		response := fmt.Sprintf("Success: %d rows affected.", rows)
+		response := fmt.Sprintf("Success: created id=%d.", id)
 
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(response))
 	})
 }
 
-func UpdateEmployee(db *sql.DB) http.Handler {
+func UpdateEmployee(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		var active bool
-		if r.FormValue("active") == "on" {
-			active = true
-		}
+		v := newValues(r)
+		e := readEmployee(v)
+		e.Id = id
 
-		laborCap, err := strconv.ParseFloat(r.FormValue("labor_cap"), 64)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		e := database.Employee{
-			Id:            id,
-			FirstName:     r.FormValue("first_name"),
-			LastName:      r.FormValue("last_name"),
-			Myid:          r.FormValue("myid"),
-			DisplayName:   r.FormValue("last_name") + ", " + r.FormValue("first_name") + " (" + r.FormValue("myid") + ")",
-			Empid:         r.FormValue("empid"),
-			LaborCapacity: laborCap,
-			Desk:          r.FormValue("desk"),
-			Active:        active,
-			CoverageStart: r.FormValue("cov_start"),
-			CoverageEnd:   r.FormValue("cov_end"),
-		}
-
-		if r.PostForm.Has("comp") {
-			v, err := strconv.ParseInt(r.FormValue("comp"), 10, 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+		if e.Manager.Valid {
+			err := database.CheckReportsTo(r.Context(), db, id, e.Manager.Int64)
+			if errors.Is(err, database.ErrValidation) {
+				v.errs.Add("manager", err.Error())
+			} else if err != nil {
+				respond.Error(w, r, err)
 				return
 			}
-			e.Comp = sql.NullInt64{Int64: v, Valid: true}
 		}
 
-		if r.PostForm.Has("manager") {
-			v, err := strconv.ParseInt(r.FormValue("manager"), 10, 64)
+		if errs := v.check(e.Validate()); errs != nil {
+			data, err := employeeForm(r.Context(), db, e)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
-			e.Manager = sql.NullInt64{Int64: v, Valid: true}
-		}
-
-		if r.PostForm.Has("ipt") {
-			v, err := strconv.ParseInt(r.FormValue("ipt"), 10, 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			e.Ipt = sql.NullInt64{Int64: v, Valid: true}
+			data.Errors = errs
+			rejected(w, r, t, "form-employee.html", data, errs)
+			return
 		}
 
-		rows, err := database.UpdateEmployee(db, e)
+		rows, err := database.UpdateEmployee(r.Context(), db, e)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -223,13 +186,13 @@ func DeleteEmployee(db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		rows, err := database.DeleteRow(db, "Employee", id)
+		rows, err := database.RecycleRow(r.Context(), db, "Employee", id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
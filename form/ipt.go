@@ -8,10 +8,12 @@ import (
 	"strconv"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type IptForm struct {
-	Ipt database.Ipt
+	Ipt    database.Ipt
+	Errors database.FieldErrors // of a rejected form, shown next to its inputs
 }
 
 func Ipt(t *template.Template, db *sql.DB) http.Handler {
@@ -19,77 +21,93 @@ func Ipt(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		data := IptForm{}
-		if id == 0 {
-			data.Ipt = database.Ipt{}
-		} else {
-			data.Ipt, err = database.GetIpt(db, id)
+		i := database.Ipt{}
+		if id != 0 {
+			i, err = database.GetIpt(r.Context(), db, id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
 		}
 
+		data := IptForm{Ipt: i}
+
 		if err = t.ExecuteTemplate(w, "form-ipt.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
 }
 
-func NewIpt(db *sql.DB) http.Handler {
+// the ipt of a posted form, see values
+func readIpt(v *values) database.Ipt {
+	return database.Ipt{
+		Name:        v.str("name"),
+		Description: v.str("description"),
+	}
+}
+
+func NewIpt(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var err error
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		i := database.Ipt{
-			Name:        r.FormValue("name"),
-			Description: r.FormValue("description"),
+		v := newValues(r)
+		i := readIpt(v)
+		if errs := v.check(i.Validate()); errs != nil {
+			data := IptForm{Ipt: i}
+			data.Errors = errs
+			rejected(w, r, t, "form-ipt.html", data, errs)
+			return
 		}
 
-		rows, err := database.InsertIpt(db, i)
+		id, err := database.InsertIpt(r.Context(), db, i)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
-		response := fmt.Sprintf("Success: %d rows affected.", rows)
+		response := fmt.Sprintf("Success: created id=%d.", id)
 
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(response))
 	})
 }
 
-func UpdateIpt(db *sql.DB) http.Handler {
+func UpdateIpt(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		i := database.Ipt{
-			Id:          id,
-			Name:        r.FormValue("name"),
-			Description: r.FormValue("description"),
+		v := newValues(r)
+		i := readIpt(v)
+		i.Id = id
+
+		if errs := v.check(i.Validate()); errs != nil {
+			data := IptForm{Ipt: i}
+			data.Errors = errs
+			rejected(w, r, t, "form-ipt.html", data, errs)
+			return
 		}
 
-		rows, err := database.UpdateIpt(db, i)
+		rows, err := database.UpdateIpt(r.Context(), db, i)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -105,13 +123,13 @@ func DeleteIpt(db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		rows, err := database.DeleteRow(db, "Ipt", id)
+		rows, err := database.RecycleRow(r.Context(), db, "Ipt", id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
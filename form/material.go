@@ -1,6 +1,7 @@
 package form
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"html/template"
@@ -8,11 +9,24 @@ import (
 	"strconv"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type MaterialForm struct {
 	Mat          database.Material
 	ProjDropdown []database.Dropdown
+	Errors       database.FieldErrors // of a rejected form, shown next to its inputs
+}
+
+// the form showing the material with its dropdowns
+func materialForm(ctx context.Context, db *sql.DB, m database.Material) (MaterialForm, error) {
+	var err error
+	data := MaterialForm{Mat: m}
+	data.ProjDropdown, err = database.NewDropdown(ctx, db, database.ProjectDropdownQuery())
+	if err != nil {
+		return data, err
+	}
+	return data, nil
 }
 
 func Material(t *template.Template, db *sql.DB) http.Handler {
@@ -20,169 +34,119 @@ func Material(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		data := MaterialForm{}
-		if id == 0 {
-			data.Mat = database.Material{}
-		} else {
-			data.Mat, err = database.GetMaterial(db, id)
+		m := database.Material{}
+		if id != 0 {
+			m, err = database.GetMaterial(r.Context(), db, id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
 		}
 
-		data.ProjDropdown, err = database.NewDropdown(db, database.ProjectDropdownQuery())
+		data, err := materialForm(r.Context(), db, m)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "form-material.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
 }
 
-func NewMaterial(db *sql.DB) http.Handler {
+// the material of a posted form, see values
+func readMaterial(v *values) database.Material {
+	return database.Material{
+		Name:                v.str("name"),
+		EstimatedCost:       v.float("estimated_cost"),
+		ActualCost:          v.float("actual_cost"),
+		PRDate:              v.str("pr_date"),
+		PODate:              v.str("po_date"),
+		PRNumber:            v.str("pr"),
+		PONumber:            v.str("po"),
+		Complete:            v.str("status") == "complete",
+		BaselineStartDate:   v.str("baseline_start_date"),
+		BaselineFinishDate:  v.str("baseline_finish_date"),
+		TentativeStartDate:  v.str("tentative_start_date"),
+		TentativeFinishDate: v.str("tentative_finish_date"),
+		ActualStartDate:     v.str("actual_start_date"),
+		ActualFinishDate:    v.str("actual_finish_date"),
+		Notes:               v.str("notes"),
+		WorkPackage:         v.id("wp"),
+	}
+}
+
+func NewMaterial(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var err error
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		var done bool
-		if r.FormValue("status") == "complete" {
-			done = true
-		}
-
-		m := database.Material{
-			Name:                r.FormValue("name"),
-			PRDate:              r.FormValue("pr_date"),
-			PODate:              r.FormValue("po_date"),
-			PRNumber:            r.FormValue("pr"),
-			PONumber:            r.FormValue("po"),
-			Complete:            done,
-			BaselineStartDate:   r.FormValue("baseline_start_date"),
-			BaselineFinishDate:  r.FormValue("baseline_finish_date"),
-			TentativeStartDate:  r.FormValue("tentative_start_date"),
-			TentativeFinishDate: r.FormValue("tentative_finish_date"),
-			ActualStartDate:     r.FormValue("actual_start_date"),
-			ActualFinishDate:    r.FormValue("actual_finish_date"),
-			Notes:               r.FormValue("notes"),
-		}
-
-		if r.PostForm.Has("estimated_cost") && len(r.FormValue("estimated_cost")) > 0 {
-			estCost, err := strconv.ParseFloat(r.FormValue("estimated_cost"), 64)
+		v := newValues(r)
+		m := readMaterial(v)
+		if errs := v.check(m.Validate()); errs != nil {
+			data, err := materialForm(r.Context(), db, m)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
-			m.EstimatedCost = estCost
-		}
-
-		if r.PostForm.Has("actual_cost") && len(r.FormValue("actual_cost")) > 0 {
-			actCost, err := strconv.ParseFloat(r.FormValue("actual_cost"), 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			m.ActualCost = actCost
-		}
-
-		if r.PostForm.Has("wp") {
-			v, err := strconv.ParseInt(r.FormValue("wp"), 10, 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			m.WorkPackage = sql.NullInt64{Int64: v, Valid: true}
+			data.Errors = errs
+			rejected(w, r, t, "form-material.html", data, errs)
+			return
 		}
 
-		rows, err := database.InsertMaterial(db, m)
+		id, err := database.InsertMaterial(r.Context(), db, m)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
-		response := fmt.Sprintf("Success: %d rows affected.", rows)
+		response := fmt.Sprintf("Success: created id=%d.", id)
 
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(response))
 	})
 }
 
-func UpdateMaterial(db *sql.DB) http.Handler {
+func UpdateMaterial(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		var done bool
-		if r.FormValue("status") == "complete" {
-			done = true
-		}
+		v := newValues(r)
+		m := readMaterial(v)
+		m.Id = id
 
-		m := database.Material{
-			Id:                  id,
-			Name:                r.FormValue("name"),
-			PRDate:              r.FormValue("pr_date"),
-			PODate:              r.FormValue("po_date"),
-			PRNumber:            r.FormValue("pr"),
-			PONumber:            r.FormValue("po"),
-			Complete:            done,
-			BaselineStartDate:   r.FormValue("baseline_start_date"),
-			BaselineFinishDate:  r.FormValue("baseline_finish_date"),
-			TentativeStartDate:  r.FormValue("tentative_start_date"),
-			TentativeFinishDate: r.FormValue("tentative_finish_date"),
-			ActualStartDate:     r.FormValue("actual_start_date"),
-			ActualFinishDate:    r.FormValue("actual_finish_date"),
-			Notes:               r.FormValue("notes"),
-		}
-
-		if r.PostForm.Has("estimated_cost") && len(r.FormValue("estimated_cost")) > 0 {
-			estCost, err := strconv.ParseFloat(r.FormValue("estimated_cost"), 64)
+		if errs := v.check(m.Validate()); errs != nil {
+			data, err := materialForm(r.Context(), db, m)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
-			m.EstimatedCost = estCost
-		}
-
-		if r.PostForm.Has("actual_cost") && len(r.FormValue("actual_cost")) > 0 {
-			actCost, err := strconv.ParseFloat(r.FormValue("actual_cost"), 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			m.ActualCost = actCost
-		}
-
-		if r.PostForm.Has("wp") {
-			v, err := strconv.ParseInt(r.FormValue("wp"), 10, 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			m.WorkPackage = sql.NullInt64{Int64: v, Valid: true}
+			data.Errors = errs
+			rejected(w, r, t, "form-material.html", data, errs)
+			return
 		}
 
-		rows, err := database.UpdateMaterial(db, m)
+		rows, err := database.UpdateMaterial(r.Context(), db, m)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -198,13 +162,13 @@ func DeleteMaterial(db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		rows, err := database.DeleteRow(db, "Material", id)
+		rows, err := database.RecycleRow(r.Context(), db, "Material", id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -1,6 +1,7 @@
 package form
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"html/template"
@@ -8,11 +9,24 @@ import (
 	"strconv"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type NetworkForm struct {
 	Net          database.Network
 	ProjDropdown []database.Dropdown
+	Errors       database.FieldErrors // of a rejected form, shown next to its inputs
+}
+
+// the form showing the network with its dropdowns
+func networkForm(ctx context.Context, db *sql.DB, n database.Network) (NetworkForm, error) {
+	var err error
+	data := NetworkForm{Net: n}
+	data.ProjDropdown, err = database.NewDropdown(ctx, db, database.ProjectDropdownQuery())
+	if err != nil {
+		return data, err
+	}
+	return data, nil
 }
 
 func Network(t *template.Template, db *sql.DB) http.Handler {
@@ -20,109 +34,110 @@ func Network(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		data := NetworkForm{}
-		if id == 0 {
-			data.Net = database.Network{}
-		} else {
-			data.Net, err = database.GetNetwork(db, id)
+		n := database.Network{}
+		if id != 0 {
+			n, err = database.GetNetwork(r.Context(), db, id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
 		}
 
-		data.ProjDropdown, err = database.NewDropdown(db, database.ProjectDropdownQuery())
+		data, err := networkForm(r.Context(), db, n)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "form-network.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
 }
 
-func NewNetwork(db *sql.DB) http.Handler {
+// the network of a posted form, see values
+func readNetwork(v *values) database.Network {
+	return database.Network{
+		ChargeNumber: v.str("charge_num"),
+		Title:        v.str("title"),
+		Description:  v.str("description"),
+		Status:       v.str("status"),
+		StartDate:    v.str("start_date"),
+		EndDate:      v.str("end_date"),
+		Proj:         v.id("proj"),
+	}
+}
+
+func NewNetwork(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var err error
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		n := database.Network{
-			ChargeNumber: r.FormValue("charge_num"),
-			Title:        r.FormValue("title"),
-			Description:  r.FormValue("description"),
-			Status:       r.FormValue("status"),
-			StartDate:    r.FormValue("start_date"),
-			EndDate:      r.FormValue("end_date"),
-		}
-
-		if r.PostForm.Has("proj") {
-			v, err := strconv.ParseInt(r.FormValue("proj"), 10, 64)
+		v := newValues(r)
+		n := readNetwork(v)
+		if errs := v.check(n.Validate()); errs != nil {
+			data, err := networkForm(r.Context(), db, n)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
-			n.Proj = sql.NullInt64{Int64: v, Valid: true}
+			data.Errors = errs
+			rejected(w, r, t, "form-network.html", data, errs)
+			return
 		}
 
-		rows, err := database.InsertNetwork(db, n)
+		id, err := database.InsertNetwork(r.Context(), db, n)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
-		response := fmt.Sprintf("Success: %d rows affected.", rows)
+		response := fmt.Sprintf("Success: created id=%d.", id)
 
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(response))
 	})
 }
 
-func UpdateNetwork(db *sql.DB) http.Handler {
+func UpdateNetwork(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		n := database.Network{
-			Id:           id,
-			ChargeNumber: r.FormValue("charge_num"),
-			Title:        r.FormValue("title"),
-			Description:  r.FormValue("description"),
-			Status:       r.FormValue("status"),
-			StartDate:    r.FormValue("start_date"),
-			EndDate:      r.FormValue("end_date"),
-		}
+		v := newValues(r)
+		n := readNetwork(v)
+		n.Id = id
 
-		if r.PostForm.Has("proj") {
-			v, err := strconv.ParseInt(r.FormValue("proj"), 10, 64)
+		if errs := v.check(n.Validate()); errs != nil {
+			data, err := networkForm(r.Context(), db, n)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
-			n.Proj = sql.NullInt64{Int64: v, Valid: true}
+			data.Errors = errs
+			rejected(w, r, t, "form-network.html", data, errs)
+			return
 		}
 
-		rows, err := database.UpdateNetwork(db, n)
+		rows, err := database.UpdateNetwork(r.Context(), db, n)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -138,13 +153,13 @@ func DeleteNetwork(db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		rows, err := database.DeleteRow(db, "Network", id)
+		rows, err := database.RecycleRow(r.Context(), db, "Network", id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -1,6 +1,7 @@
 package form
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"html/template"
@@ -8,11 +9,24 @@ import (
 	"strconv"
 
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
 )
 
 type ProjectForm struct {
 	Proj         database.Project
 	ProjDropdown []database.Dropdown
+	Errors       database.FieldErrors // of a rejected form, shown next to its inputs
+}
+
+// the form showing the project with its dropdowns
+func projectForm(ctx context.Context, db *sql.DB, p database.Project) (ProjectForm, error) {
+	var err error
+	data := ProjectForm{Proj: p}
+	data.ProjDropdown, err = database.NewDropdown(ctx, db, database.ProjectDropdownQuery())
+	if err != nil {
+		return data, err
+	}
+	return data, nil
 }
 
 func Project(t *template.Template, db *sql.DB) http.Handler {
@@ -20,147 +34,113 @@ func Project(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		data := ProjectForm{}
-		if id == 0 {
-			data.Proj = database.Project{}
-		} else {
-			data.Proj, err = database.GetProject(db, id)
+		p := database.Project{}
+		if id != 0 {
+			p, err = database.GetProject(r.Context(), db, id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
 		}
 
-		data.ProjDropdown, err = database.NewDropdown(db, database.ProjectDropdownQuery())
+		data, err := projectForm(r.Context(), db, p)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "form-project.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
 }
 
-func NewProject(db *sql.DB) http.Handler {
+// the project of a posted form, see values
+func readProject(v *values) database.Project {
+	return database.Project{
+		Title:         v.str("title"),
+		Description:   v.str("description"),
+		WbsId:         v.str("wbsid"),
+		StmtOfWork:    v.str("stmt_of_work"),
+		StartDate:     v.str("start_date"),
+		EndDate:       v.str("end_date"),
+		ImsUid:        v.int("ims_uid"),
+		WadLineId:     v.int("wad_lineid"),
+		Evt:           v.str("evt"),
+		ParentProject: v.id("parent_proj"),
+	}
+}
+
+func NewProject(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var err error
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		p := database.Project{
-			Title:       r.FormValue("title"),
-			Description: r.FormValue("description"),
-			WbsId:       r.FormValue("wbsid"),
-			StmtOfWork:  r.FormValue("stmt_of_work"),
-			StartDate:   r.FormValue("start_date"),
-			EndDate:     r.FormValue("end_date"),
-			Evt:         r.FormValue("evt"),
-		}
-
-		if r.PostForm.Has("ims_uid") && len(r.FormValue("ims_uid")) > 0 {
-			imsUid, err := strconv.Atoi(r.FormValue("ims_uid"))
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			p.ImsUid = imsUid
-		}
-
-		if r.PostForm.Has("wad_lineid") && len(r.FormValue("wad_lineid")) > 0 {
-			wadLineId, err := strconv.Atoi(r.FormValue("wad_lineid"))
+		v := newValues(r)
+		p := readProject(v)
+		if errs := v.check(p.Validate()); errs != nil {
+			data, err := projectForm(r.Context(), db, p)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
-			p.WadLineId = wadLineId
-		}
-
-		if r.PostForm.Has("parent_proj") {
-			v, err := strconv.ParseInt(r.FormValue("parent_proj"), 10, 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			p.ParentProject = sql.NullInt64{Int64: v, Valid: true}
+			data.Errors = errs
+			rejected(w, r, t, "form-project.html", data, errs)
+			return
 		}
 
-		rows, err := database.InsertProject(db, p)
+		id, err := database.InsertProject(r.Context(), db, p)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
-		response := fmt.Sprintf("Success: %d rows affected.", rows)
+		response := fmt.Sprintf("Success: created id=%d.", id)
 
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(response))
 	})
 }
 
-func UpdateProject(db *sql.DB) http.Handler {
+func UpdateProject(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		p := database.Project{
-			Id:          id,
-			Title:       r.FormValue("title"),
-			Description: r.FormValue("description"),
-			WbsId:       r.FormValue("wbsid"),
-			StmtOfWork:  r.FormValue("stmt_of_work"),
-			StartDate:   r.FormValue("start_date"),
-			EndDate:     r.FormValue("end_date"),
-			Evt:         r.FormValue("evt"),
-		}
+		v := newValues(r)
+		p := readProject(v)
+		p.Id = id
 
-		if r.PostForm.Has("ims_uid") && len(r.FormValue("ims_uid")) > 0 {
-			imsUid, err := strconv.Atoi(r.FormValue("ims_uid"))
+		if errs := v.check(p.Validate()); errs != nil {
+			data, err := projectForm(r.Context(), db, p)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
-			p.ImsUid = imsUid
-		}
-
-		if r.PostForm.Has("wad_lineid") && len(r.FormValue("wad_lineid")) > 0 {
-			wadLineId, err := strconv.Atoi(r.FormValue("wad_lineid"))
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			p.WadLineId = wadLineId
-		}
-
-		if r.PostForm.Has("parent_proj") {
-			v, err := strconv.ParseInt(r.FormValue("parent_proj"), 10, 64)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			p.ParentProject = sql.NullInt64{Int64: v, Valid: true}
+			data.Errors = errs
+			rejected(w, r, t, "form-project.html", data, errs)
+			return
 		}
 
-		rows, err := database.UpdateProject(db, p)
+		rows, err := database.UpdateProject(r.Context(), db, p)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -176,13 +156,13 @@ func DeleteProject(db *sql.DB) http.Handler {
 		var err error
 		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		rows, err := database.DeleteRow(db, "Project", id)
+		rows, err := database.RecycleRow(r.Context(), db, "Project", id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
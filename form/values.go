@@ -0,0 +1,105 @@
+package form
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/logging"
+	"github.com/james-mcallister/may/respond"
+)
+
+// set on a rejected form so the page swaps it in for the submitted one
+const rejectedHeader = "X-May-Rejected"
+
+// the posted values of an entity form. A value that doesn't parse is a field
+// error instead of failing the request, so every problem is shown at once.
+type values struct {
+	r    *http.Request
+	errs database.FieldErrors
+}
+
+func newValues(r *http.Request) *values {
+	return &values{r: r, errs: database.FieldErrors{}}
+}
+
+func (v *values) str(name string) string {
+	return v.r.FormValue(name)
+}
+
+// an empty input is 0
+func (v *values) float(name string) float64 {
+	s := strings.TrimSpace(v.r.FormValue(name))
+	if len(s) == 0 {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		v.errs.Add(name, "not a number")
+	}
+	return f
+}
+
+func (v *values) int(name string) int {
+	s := strings.TrimSpace(v.r.FormValue(name))
+	if len(s) == 0 {
+		return 0
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.errs.Add(name, "not a whole number")
+	}
+	return i
+}
+
+// a dropdown selection, a cleared dropdown isn't posted
+func (v *values) id(name string) database.NullInt64 {
+	if !v.r.PostForm.Has(name) {
+		return database.NullInt64{}
+	}
+	id, err := strconv.ParseInt(v.r.FormValue(name), 10, 64)
+	if err != nil {
+		v.errs.Add(name, "not one of the options")
+		return database.NullInt64{}
+	}
+	return database.NewNullInt64(id)
+}
+
+// adds the field errors of the entity's Validate to the parse errors, nil
+// when there are none
+func (v *values) check(validate error) database.FieldErrors {
+	var fields database.FieldErrors
+	if errors.As(validate, &fields) {
+		for f, msg := range fields {
+			v.errs.Add(f, msg)
+		}
+	}
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// the form again with the posted values and what is wrong with each field,
+// or only the field errors for a client asking for json
+func rejected(w http.ResponseWriter, r *http.Request, t *template.Template, name string, data any, errs database.FieldErrors) {
+	if respond.WantsJSON(r) {
+		respond.Error(w, r, errs)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	logging.SetError(r.Context(), errs.Error())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set(rejectedHeader, "true")
+	w.WriteHeader(respond.Status(errs))
+	buf.WriteTo(w)
+}
@@ -1,6 +1,6 @@
 import Big from 'big.js';
-import { PlanRow, PlanTable } from './plan';
-import { init as msgInit, notify, showProgress, endProgress } from './messages';
+import { PlanRow, PlanTable, clientId, fetchLookup, subscribePage } from './plan';
+import { init as msgInit, notify, errorText, showProgress, endProgress } from './messages';
 import 'bulma/css/bulma.css';
 import '@fortawesome/fontawesome-free/js/solid.js';
 import '@fortawesome/fontawesome-free/js/fontawesome.js';
@@ -24,17 +24,38 @@ const formatterUSD = new Intl.NumberFormat('en-US', {
 const formatNumber = new Intl.NumberFormat("en-US");
 
 $(function() {
+    // the session expired or was never started
+    $(document).ajaxError(function(e, xhr) {
+        if (xhr.status === 401) {
+            window.location.assign("/login?next=/");
+        }
+    });
+    // state-changing requests echo the csrf cookie back in a header
+    $.ajaxPrefilter(function(options, original, xhr) {
+        if (!/^(GET|HEAD|OPTIONS)$/i.test(options.type)) {
+            xhr.setRequestHeader("X-CSRF-Token", csrfToken());
+            xhr.setRequestHeader("X-May-Client", clientId);
+        }
+    });
     MainModule.init();
     NavModule.init();
+    SearchModule.init();
     msgInit();
 });
 
+function csrfToken() {
+    const c = document.cookie.split("; ").find((c) => c.startsWith("may_csrf="));
+    return c ? decodeURIComponent(c.substring("may_csrf=".length)) : "";
+}
+
 function initNext(handler) {
     const evts = {
+        "audit": AuditModule,
         "entity": EntityModule,
         "form": FormModule,
         "home": HomeModule,
-        "plan": PlanFormModule
+        "plan": PlanFormModule,
+        "recycle": RecycleModule
     };
     evts[handler].init();
 }
@@ -76,7 +97,7 @@ const HomeModule = (function($) {
             }
         }).fail(function(xhr, status, err) {
             endProgress();
-            notify("danger", `request failure: /home/ ${xhr.responseText}`);
+            notify("danger", `request failure: /home/ ${errorText(xhr)}`);
         });
     }
 
@@ -120,6 +141,12 @@ const NavModule = (function($) {
         e.target.blur();
         let route = $(this).attr("href");
         let handler = $(this).data("handler");
+        if (handler === "logout") {
+            $.ajax({ url: "/logout", method: "POST" }).always(function() {
+                window.location.assign("/login");
+            });
+            return;
+        }
         $.ajax({
             url: `/${route}/`,
             method: "GET",
@@ -129,10 +156,12 @@ const NavModule = (function($) {
                 return
             },
         }).done(function(markup) {
+            // leaving an open plan page ends its event stream
+            PlanPage.teardown();
             MainModule.setContent(markup);
             initNext(handler);
         }).fail(function(xhr, status, err) {
-            notify("danger", `request failure: ${route} ${xhr.responseText}`);
+            notify("danger", `request failure: ${route} ${errorText(xhr)}`);
         });
     }
 
@@ -146,6 +175,139 @@ const NavModule = (function($) {
     }
 })(jQuery);
 
+// type-ahead search in the navbar, a result opens the entity form or the
+// plan page
+const SearchModule = (function($) {
+    let ele = {};
+    let timer = null;
+    let request = null;
+
+    const labels = {
+        "employee": "Employee",
+        "project": "Project",
+        "network": "Network",
+        "material": "Material",
+        "planpage": "Plan Page"
+    };
+    const paths = {
+        "employee": "employees",
+        "project": "projects",
+        "network": "networks",
+        "material": "material"
+    };
+
+    function close() {
+        ele.search.removeClass("is-active");
+        ele.results.empty();
+    }
+
+    function handleInput() {
+        clearTimeout(timer);
+        timer = setTimeout(search, 200);
+    }
+
+    function handleKey(e) {
+        if (e.key === "Escape") {
+            close();
+        } else if (e.key === "Enter") {
+            e.preventDefault();
+            ele.results.find("a.dropdown-item").first().trigger("click");
+        }
+    }
+
+    function search() {
+        let q = ele.input.val().trim();
+        if (request) {
+            request.abort();
+        }
+        if (q.length === 0) {
+            close();
+            return
+        }
+        request = $.ajax({
+            url: "/api/v1/search",
+            method: "GET",
+            data: { "q": q },
+            dataType: "json",
+        }).done(function(results) {
+            ele.results.empty();
+            if (results.length === 0) {
+                ele.results.append($("<div>", { "class": "dropdown-item" }).text("No matches"));
+            }
+            for (const r of results) {
+                let item = $("<a>", { "class": "dropdown-item", "href": "" })
+                    .data("result", r)
+                    .append($("<strong>").text(r.title))
+                    .append($("<span>", { "class": "tag is-light ml-2" }).text(labels[r.type]));
+                if (r.snippet) {
+                    item.append($("<p>", { "class": "is-size-7" }).text(r.snippet));
+                }
+                ele.results.append(item);
+            }
+            ele.search.addClass("is-active");
+        }).fail(function(xhr, status, err) {
+            if (status !== "abort") {
+                notify("danger", `request failure: /api/v1/search ${errorText(xhr)}`);
+            }
+        });
+    }
+
+    function handleSelect(e) {
+        e.stopPropagation();
+        e.preventDefault();
+        let r = $(this).data("result");
+        close();
+        ele.input.val("");
+
+        let req = {
+            url: `/${paths[r.type]}/${r.id}/`,
+            method: "GET",
+            next: function() { initNext("form"); }
+        };
+        if (r.type === "planpage") {
+            req = {
+                url: "/plan/",
+                method: "POST",
+                data: { "load_plan": r.id },
+                next: function() { PlanPage.init(); }
+            };
+        }
+        $.ajax({
+            url: req.url,
+            method: req.method,
+            data: req.data,
+            dataType: "html",
+            beforeSend: function() {
+                showProgress();
+            },
+        }).done(function(markup) {
+            endProgress();
+            PlanPage.teardown();
+            MainModule.setContent(markup);
+            req.next();
+        }).fail(function(xhr, status, err) {
+            endProgress();
+            notify("danger", `request failure: ${req.url} ${errorText(xhr)}`);
+        });
+    }
+
+    return {
+        init() {
+            ele.search = $("#global-search");
+            ele.input = $("#global-search-input");
+            ele.results = $("#global-search-results");
+            ele.input.on("input", handleInput);
+            ele.input.on("keydown", handleKey);
+            ele.results.on("click", "a.dropdown-item", handleSelect);
+            $(document).on("click", function(e) {
+                if (!$(e.target).closest("#global-search").length) {
+                    close();
+                }
+            });
+        }
+    }
+})(jQuery);
+
 const EntityModule = (function($) {
     let ele = {};
 
@@ -172,6 +334,40 @@ const EntityModule = (function($) {
         makeRequest(ent, "0", "GET");
     }
 
+    function handleFilter(e) {
+        e.stopPropagation();
+        e.preventDefault();
+        show(ele.form.serialize());
+    }
+
+    function handlePage(e) {
+        e.stopPropagation();
+        e.preventDefault();
+        ele.form.find("input[name=offset]").val($(this).data("offset"));
+        show(ele.form.serialize());
+    }
+
+    // reloads the list with the filter, sort and paging query string
+    function show(query) {
+        let ent = ele.btnNew.attr("href");
+        $.ajax({
+            url: `/${ent}/?${query}`,
+            method: "GET",
+            dataType: "html",
+            beforeSend: function() {
+                showProgress();
+            }
+        }).done(function(markup) {
+            endProgress();
+            teardown();
+            MainModule.setContent(markup);
+            init();
+        }).fail(function(xhr, status, err) {
+            endProgress();
+            notify("danger", `request failure: /${ent}/ ${errorText(xhr)}`);
+        });
+    }
+
     function makeRequest(ent, id, reqMethod) {
         let handler = ele.tBody.data("handler");
         $.ajax({
@@ -189,7 +385,7 @@ const EntityModule = (function($) {
             initNext(handler);
         }).fail(function(xhr, status, err) {
             endProgress();
-            notify("danger", `request failure: /${ent}/${id}/ ${xhr.responseText}`);
+            notify("danger", `request failure: /${ent}/${id}/ ${errorText(xhr)}`);
         });
     }
 
@@ -199,16 +395,21 @@ const EntityModule = (function($) {
         ele.btnNew = $("#btn-new");
         ele.tBody = $("tbody");
         ele.eList = $("tbody tr");
+        ele.form = $("#entity-filter");
 
         ele.searchInput.on("keyup", filterTable);
         ele.tBody.on("click", "tr", handleUpdate);
         ele.btnNew.on("click", handleNew);
+        ele.form.on("submit", handleFilter);
+        ele.form.on("click", ".btn-page", handlePage);
     }
 
     function teardown() {
         ele.searchInput.off("keyup", filterTable);
         ele.tBody.off("click", "tr", handleUpdate);
         ele.btnNew.off("click", handleNew);
+        ele.form.off("submit", handleFilter);
+        ele.form.off("click", ".btn-page", handlePage);
     }
 
     return {
@@ -264,6 +465,15 @@ const FormModule = (function($) {
         HomeModule.navHome();
     }
 
+    function handleHistory(e) {
+        e.stopPropagation();
+        e.preventDefault();
+        let table = ele.btnHistory.attr("href");
+        let id = parseInt(ele.entId.val(), 10);
+        teardown();
+        AuditModule.show({ table: table, row: id });
+    }
+
     function checkValidField(e) {
         e.stopPropagation();
         let ele = $(this);
@@ -297,6 +507,7 @@ const FormModule = (function($) {
         ele.btnDelete.off("click", handleDelete);
         ele.btnCancel.off("click", handleCancel);
         ele.btnSubmit.off("click", handleUpdate);
+        ele.btnHistory.off("click", handleHistory);
         ele.form.off("blur", "input", checkValidField);
     }
 
@@ -319,7 +530,15 @@ const FormModule = (function($) {
         }).fail(function(xhr, status, err) {
             ele.fieldset.prop("disabled", false);
             endProgress();
-            notify("danger", `request failure: ${url} ${xhr.responseText}`);
+            if (xhr.getResponseHeader("X-May-Rejected")) {
+                // the form again with what is wrong next to each field
+                teardown();
+                MainModule.setContent(xhr.responseText);
+                FormModule.init();
+                notify("danger", "invalid form fields: see the highlighted fields");
+                return;
+            }
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
         });
     }
 
@@ -329,6 +548,7 @@ const FormModule = (function($) {
             ele.btnSubmit = $("#btn-submit");
             ele.btnDelete = $("#btn-delete");
             ele.btnCancel = $("#btn-cancel");
+            ele.btnHistory = $("#btn-history");
             ele.fieldset = $("fieldset");
             ele.form = $("form");
             ele.formInput = $("div.control input");
@@ -342,14 +562,173 @@ const FormModule = (function($) {
             let id = parseInt(ele.entId.val(), 10);
             if (id === 0) {
                 ele.btnDelete.addClass("is-hidden");
+                ele.btnHistory.addClass("is-hidden");
             } else {
                 ele.btnDelete.on("click", handleDelete);
+                ele.btnHistory.on("click", handleHistory);
             }
         },
         teardown
     }
 })(jQuery);
 
+const AuditModule = (function($) {
+    let ele = {};
+
+    function handleFilter(e) {
+        e.stopPropagation();
+        e.preventDefault();
+        teardown();
+        show(ele.form.serialize());
+    }
+
+    // loads the history page, params is an object or a query string
+    function show(params) {
+        let query = typeof params === "string" ? params : $.param(params);
+        $.ajax({
+            url: `/audit/?${query}`,
+            method: "GET",
+            dataType: "html",
+            beforeSend: function() {
+                showProgress();
+            }
+        }).done(function(markup) {
+            endProgress();
+            MainModule.setContent(markup);
+            init();
+        }).fail(function(xhr, status, err) {
+            endProgress();
+            notify("danger", `request failure: /audit/ ${errorText(xhr)}`);
+        });
+    }
+
+    function init() {
+        ele.form = $("#audit-filter");
+        ele.form.on("submit", handleFilter);
+    }
+
+    function teardown() {
+        if (ele.form) {
+            ele.form.off("submit", handleFilter);
+        }
+    }
+
+    return {
+        init,
+        show,
+        teardown
+    }
+})(jQuery);
+
+const RecycleModule = (function($) {
+    let ele = {};
+
+    function handleFilter(e) {
+        e.stopPropagation();
+        e.preventDefault();
+        teardown();
+        show(ele.form.serialize());
+    }
+
+    function entryId(btn) {
+        return $(btn).closest("tr").data("id");
+    }
+
+    function handleRestore(e) {
+        e.stopPropagation();
+        e.preventDefault();
+        makeRequest(`/recycle/${entryId(this)}/restore`, "POST", function(res) {
+            let msg = `restored ${res.restored.table} ${res.restored.row_id}`;
+            if (res.skipped > 0) {
+                msg += `, ${res.skipped} dependents no longer fit and were skipped`;
+            }
+            return msg;
+        });
+    }
+
+    function handlePurge(e) {
+        e.stopPropagation();
+        e.preventDefault();
+        let id = entryId(this);
+        showModalConfirm().then(() => {
+            makeRequest(`/recycle/${id}/`, "DELETE", () => "purged");
+        }).catch(() => {});
+    }
+
+    function handlePurgeAll(e) {
+        e.stopPropagation();
+        e.preventDefault();
+        showModalConfirm().then(() => {
+            makeRequest("/recycle/", "DELETE", () => "recycle bin emptied");
+        }).catch(() => {});
+    }
+
+    // runs the action then reloads the bin with the message from done
+    function makeRequest(url, reqMethod, done) {
+        $.ajax({
+            url: url,
+            method: reqMethod,
+            beforeSend: function() {
+                showProgress();
+            }
+        }).done(function(res) {
+            endProgress();
+            let msg = done(res);
+            teardown();
+            show(ele.form.serialize());
+            notify("success", msg);
+        }).fail(function(xhr, status, err) {
+            endProgress();
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
+        });
+    }
+
+    // loads the recycle bin page, params is an object or a query string
+    function show(params) {
+        let query = typeof params === "string" ? params : $.param(params);
+        $.ajax({
+            url: `/recycle/?${query}`,
+            method: "GET",
+            dataType: "html",
+            beforeSend: function() {
+                showProgress();
+            }
+        }).done(function(markup) {
+            endProgress();
+            MainModule.setContent(markup);
+            init();
+        }).fail(function(xhr, status, err) {
+            endProgress();
+            notify("danger", `request failure: /recycle/ ${errorText(xhr)}`);
+        });
+    }
+
+    function init() {
+        ele.form = $("#recycle-filter");
+        ele.entries = $("#recycle-entries");
+        ele.btnPurgeAll = $("#btn-purge-all");
+        ele.form.on("submit", handleFilter);
+        ele.entries.on("click", ".btn-restore", handleRestore);
+        ele.entries.on("click", ".btn-purge", handlePurge);
+        ele.btnPurgeAll.on("click", handlePurgeAll);
+    }
+
+    function teardown() {
+        if (ele.form) {
+            ele.form.off("submit", handleFilter);
+            ele.entries.off("click", ".btn-restore", handleRestore);
+            ele.entries.off("click", ".btn-purge", handlePurge);
+            ele.btnPurgeAll.off("click", handlePurgeAll);
+        }
+    }
+
+    return {
+        init,
+        show,
+        teardown
+    }
+})(jQuery);
+
 const PlanFormModule = (function($) {
     let ele = {};
 
@@ -471,7 +850,7 @@ const PlanFormModule = (function($) {
         }).fail(function(xhr, status, err) {
             ele.fieldset.prop("disabled", false);
             endProgress();
-            notify("danger", `request failure: ${url} ${xhr.responseText}`);
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
         });
     }
 
@@ -494,7 +873,7 @@ const PlanFormModule = (function($) {
         }).fail(function(xhr, status, err) {
             ele.fieldset.prop("disabled", false);
             endProgress();
-            notify("danger", `request failure: ${url} ${xhr.responseText}`);
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
         });
     }
 
@@ -546,6 +925,7 @@ const PlanPage = (function($) {
     let ele = {};
 
     let currentTab;
+    let events = null; // the page's live changes
 
     function checkValidField(e) {
         e.stopPropagation();
@@ -691,8 +1071,9 @@ const PlanPage = (function($) {
                     let hBtns = tBody.find(`button.hours[data-fiscal-period="${fp}"]`);
                     hBtns.each(function() {
                         let btnHours = new Big($(this).text());
-                        let rate = $(this).parent().siblings("td.rate").text();
-                        let btnCost = btnHours.times(rate);
+                        // the rate is empty when redacted for the user's role
+                        let rate = $(this).parent().siblings("td.rate").text().trim();
+                        let btnCost = rate ? btnHours.times(rate) : new Big(0);
                         hours = hours.plus(btnHours);
                         cost = cost.plus(btnCost);
                     });
@@ -852,7 +1233,7 @@ const PlanPage = (function($) {
             setupRowForm(planId, startDate, endDate);
         }).fail(function(xhr, status, err) {
             endProgress();
-            notify("danger", `request failure: ${url} ${xhr.responseText}`);
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
         });
     }
 
@@ -872,7 +1253,7 @@ const PlanPage = (function($) {
             calcTotals();
         }).fail(function(xhr, status, err) {
             endProgress();
-            notify("danger", `request failure: ${url} ${xhr.responseText}`);
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
         });
     }
 
@@ -901,7 +1282,7 @@ const PlanPage = (function($) {
             setupCal(selectedEle);
         }).fail(function(xhr, status, err) {
             endProgress();
-            notify("danger", `request failure: ${url} ${xhr.responseText}`);
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
         });
     }
 
@@ -1114,7 +1495,7 @@ const PlanPage = (function($) {
             if (currentTab) {
                 currentTab.data("content").removeClass("is-hidden");
             }
-            notify("danger", `request failure: ${url} ${xhr.responseText}`);
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
         });
     }
 
@@ -1138,14 +1519,36 @@ const PlanPage = (function($) {
             ele.entityList.data("selected", d);
         });
 
+        // active employees are looked up as the planner types, the
+        // selection is kept across searches
         let s = $("#emp-row-search-input");
-        let eList = $("#entity-list a");
-        s.on("keyup", function() {
-            let val = s.val().toLowerCase();
-            eList.filter(function() {
-                $(this).toggle($(this).text().toLowerCase().indexOf(val) > -1);
+        let ipt = $("#emp-row-ipt");
+        let timer = null;
+        function showEmployees() {
+            let params = { "q": s.val().trim(), "active": true, "limit": 50 };
+            if (ipt.val()) {
+                params["ipt"] = ipt.val();
+            }
+            fetchLookup("employees", params).done(function(options) {
+                let d = ele.entityList.data("selected");
+                ele.entityList.empty();
+                for (const o of options) {
+                    let a = $("<a>", { "class": "panel-block", "data-id": o.id }).text(o.name);
+                    if (d.includes(Number(o.id))) {
+                        a.addClass("has-background-primary");
+                    }
+                    ele.entityList.append(a);
+                }
+            }).fail(function(xhr, status, err) {
+                notify("danger", `request failure: /api/v1/employees/lookup ${errorText(xhr)}`);
             });
+        }
+        s.on("input", function() {
+            clearTimeout(timer);
+            timer = setTimeout(showEmployees, 200);
         });
+        ipt.on("change", showEmployees);
+        showEmployees();
 
         ele.btnPlanFormSubmit = $("#btn-add-employees");
         ele.btnPlanFormSubmit.on("click", function(e) {
@@ -1186,7 +1589,7 @@ const PlanPage = (function($) {
         const res = await Promise.allSettled(rows);
         for (const r of res) {
             if (r.status === "rejected") {
-                notify("danger", `error: ${r.reason.responseText}`);
+                notify("danger", `error: ${errorText(r.reason)}`);
                 continue
             }
             getTableRow(r.value["emp_id"], r.value["plan_id"], startDate, endDate);
@@ -1212,6 +1615,7 @@ const PlanPage = (function($) {
 
     function teardownRowForm() {
         $("#emp-row-search-input").off();
+        $("#emp-row-ipt").off();
         ele.entityList.off();
     }
 
@@ -1253,7 +1657,7 @@ const PlanPage = (function($) {
         }).fail(function(xhr, status, err) {
             //ele.fieldset.prop("disabled", false);
             endProgress();
-            notify("danger", `request failure: ${url} ${xhr.responseText}`);
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
         });
     }
 
@@ -1281,7 +1685,7 @@ const PlanPage = (function($) {
 
     function getNewPlan(tabname) {
         let url = "/evms/plan";
-        let formData = ele.form.serialize();
+        let formData = ele.form.serialize() + "&" + $.param({ page_id: ele.planPage.data("page-id") });
         $.ajax({
             url: url,
             method: "POST",
@@ -1298,7 +1702,7 @@ const PlanPage = (function($) {
         }).fail(function(xhr, status, err) {
             ele.fieldset.prop("disabled", false);
             endProgress();
-            notify("danger", `request failure: ${url} ${xhr.responseText}`);
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
         });
     }
 
@@ -1355,6 +1759,109 @@ const PlanPage = (function($) {
         return
     }
 
+    // saves the hours of every row on the current table
+    function handleSave(e) {
+        e.preventDefault();
+        e.stopPropagation();
+        if (!currentTab) {
+            return
+        }
+        let t = currentTab.data("content");
+        let tData = t.data("tableData");
+        t.find("tbody tr").each(function() {
+            let row = $(this).data("rowData");
+            if (row) {
+                tData.saveHours(row);
+            }
+        });
+    }
+
+    function handleUndo(e) {
+        e.preventDefault();
+        e.stopPropagation();
+        replayJournal("undo");
+    }
+
+    function handleRedo(e) {
+        e.preventDefault();
+        e.stopPropagation();
+        replayJournal("redo");
+    }
+
+    // undo/redo are journaled on the server per plan page, the response
+    // says which row changed so only that row is reloaded
+    function replayJournal(action) {
+        let url = `/api/${action}?page_id=${ele.planPage.data("page-id")}`;
+        $.ajax({
+            url: url,
+            method: "POST",
+            dataType: "json",
+            beforeSend: function() {
+                showProgress();
+            },
+        }).done(function(entry) {
+            endProgress();
+            reloadRow(entry.emp_id, entry.plan_id, entry.row_exists);
+            notify("success", `${action} ${entry.op} row`);
+        }).fail(function(xhr, status, err) {
+            endProgress();
+            notify("danger", `request failure: ${url} ${errorText(xhr)}`);
+        });
+    }
+
+    function reloadRow(empId, planId, exists) {
+        let table = ele.planPage.find(`div.table-container[data-plan-id='${planId}']`);
+        table.find(`tr[data-emp-id='${empId}'][data-scope-id='${planId}']`).remove();
+        if (!exists || table.length === 0) {
+            calcTotals();
+            return
+        }
+
+        let startDate = table.data("pop-start");
+        let endDate = table.data("pop-end");
+        $.ajax({
+            url: "/api/planrow",
+            method: "GET",
+            data: {
+                "start_date": startDate,
+                "end_date": endDate,
+                "emp_ids": empId,
+                "plan_ids": planId
+            },
+            dataType: "html",
+        }).done(function(res) {
+            table.find("tbody").prepend(res);
+            let row = table.find(`tr[data-emp-id='${empId}'][data-scope-id='${planId}']`);
+            initRowData(row, startDate, endDate);
+            calcTotals();
+        }).fail(function(xhr, status, err) {
+            notify("danger", `request failure: /api/planrow ${errorText(xhr)}`);
+        });
+    }
+
+    // someone else changed a row on the page, reload it unless it is
+    // already at that version
+    function handleRowEvent(exists) {
+        return function(d) {
+            let tr = ele.planPage.find(`tr[data-emp-id='${d.emp_id}'][data-scope-id='${d.plan_id}']`);
+            let row = tr.data("rowData");
+            if (exists && row && row.version === `"${d.version}"`) {
+                return
+            }
+            reloadRow(d.emp_id, d.plan_id, exists);
+            notify("info", "a row on this page was changed elsewhere");
+        }
+    }
+
+    function handleTargetEvent(d) {
+        ele.targetHoursInput.val(d.target_hours);
+        handleUpdateHours();
+        if (d.target_cost !== null) {
+            ele.targetCostInput.val(d.target_cost);
+            handleUpdateCost();
+        }
+    }
+
     function init() {
         // TODO: on table add/load need to make three requests:
         // table markup
@@ -1366,6 +1873,9 @@ const PlanPage = (function($) {
         ele.btnAdd = $("#btn-add-plan");
         ele.btnDel = $("#btn-delete-plan");
         ele.btnLoad = $("#btn-load-plan");
+        ele.btnSave = $("#btn-save-plan");
+        ele.btnUndo = $("#btn-undo");
+        ele.btnRedo = $("#btn-redo");
         ele.targetHours = $("#target-hours");
         ele.targetHoursInput = $("#target-hours-input");
         ele.targetHoursDelta = $("#target-hours-delta");
@@ -1382,11 +1892,30 @@ const PlanPage = (function($) {
         ele.btnAdd.on("click", handleGetNewPlanForm);
         ele.planPage.on("may:update-hours", updateHours);
         ele.btnLoad.on("click", loadPlanTable);
+        ele.btnSave.on("click", handleSave);
+        ele.btnUndo.on("click", handleUndo);
+        ele.btnRedo.on("click", handleRedo);
         currentTab = null;
+
+        if (events) {
+            events.close();
+        }
+        events = subscribePage(ele.planPage.data("page-id"), {
+            "row-added": handleRowEvent(true),
+            "row-updated": handleRowEvent(true),
+            "row-deleted": handleRowEvent(false),
+            "target": handleTargetEvent,
+        });
     }
 
     function teardown() {
-        ele.planPage.off();
+        if (ele.planPage) {
+            ele.planPage.off();
+        }
+        if (events) {
+            events.close();
+            events = null;
+        }
     }
 
     return {
@@ -38,3 +38,12 @@ export function showProgress() {
 export function endProgress() {
     $("#notification").empty();
 }
+
+// the message of a failed request: the error field of a json error body
+// (escaped, notify shows markup) or the html fragment as it is
+export function errorText(xhr) {
+    if (xhr.responseJSON && typeof xhr.responseJSON.error === "string") {
+        return $("<span>").text(xhr.responseJSON.error).html();
+    }
+    return xhr.responseText;
+}
@@ -3,7 +3,7 @@
 // the planHours array in each row, I think we should just associate
 // an object of this type to simiplify the calculate operations.
 
-import { notify } from './messages';
+import { notify, errorText } from './messages';
 import Big from 'big.js';
 Big.DP = 2; // max decimal precision
 Big.RM = Big.roundHalfUp; // rounding mode
@@ -125,7 +125,7 @@ class PlanTable {
             hours[d] = val;
             i++;
         }
-        savePlanHours(JSON.stringify(hours));
+        return savePlanHours(row, JSON.stringify(hours));
     }
 
     getStartIndex(monthStart) {
@@ -154,12 +154,15 @@ class PlanRow {
         this.empId = empId;
         this.planId = planId;
         this.planHours = null;
+        // ETag of the hours, updates are rejected once someone else saved
+        this.version = null;
     }
 
     async init(startDate, endDate) {
         return fetchPlanHours(this.empId, this.planId, startDate, endDate)
-            .then((res) => {
+            .then((res, status, xhr) => {
                 this.setPlanHours(res);
+                this.version = xhr.getResponseHeader("ETag");
             });
     }
 
@@ -186,21 +189,47 @@ class PlanRow {
     }
 }
 
-function savePlanHours(hours) {
-    let url = "";
-    $.ajax({
+function savePlanHours(row, hours) {
+    let url = `/api/planrow?emp_id=${row.empId}&plan_id=${row.planId}`;
+    return $.ajax({
         url: url,
         method: "PUT",
         contentType: "application/json",
+        headers: { "If-Match": row.version },
         data: hours,
-    }).done(function(res) {
+    }).done(function(res, status, xhr) {
+        row.version = xhr.getResponseHeader("ETag");
         notify("success", `Hours Updated`);
     }).fail(function(xhr, status, err) {
-        // need to import notify somehow here or figure out error handling
-        notify("danger", `request failure: ${url} ${xhr.responseText}`);
+        if (xhr.status === 409 && xhr.responseJSON) {
+            // someone saved the row first, reload it before saving again
+            let c = xhr.responseJSON;
+            let days = c.changes.map((d) => `${d.cal_date}: ${d.hours} (${d.actor})`).join(", ");
+            notify("warning", `${c.error}. Reload the row to see their changes: ${days}`);
+            return
+        }
+        notify("danger", `request failure: ${url} ${errorText(xhr)}`);
     });
 }
 
+// identifies this tab to the server, so the page's event stream doesn't
+// echo back the changes made here
+const clientId = Math.random().toString(36).slice(2) + Date.now().toString(36);
+
+// live row and target changes made by others on the plan page. handlers
+// maps the event names (row-added, row-updated, row-deleted, target) to
+// functions of the event data. The browser reconnects on its own after a
+// dropped stream, call close() on the result when leaving the page.
+function subscribePage(pageId, handlers) {
+    let source = new EventSource(`/api/events?page_id=${pageId}&client=${clientId}`);
+    for (const [name, fn] of Object.entries(handlers)) {
+        source.addEventListener(name, function(e) {
+            fn(JSON.parse(e.data));
+        });
+    }
+    return source;
+}
+
 function fetchProdHours(popStart, popEnd) {
     let url = "/api/prodhours";
     return $.ajax({
@@ -242,4 +271,15 @@ function fetchPlanHours(empId, planId, popStart, popEnd) {
     });
 }
 
-export { PlanRow, PlanTable };
+// dropdown options of an entity (ex: employees), see /api/v1/{entity}/lookup
+function fetchLookup(entity, params) {
+    let url = `/api/v1/${entity}/lookup`;
+    return $.ajax({
+        url: url,
+        method: "GET",
+        data: params,
+        dataType: "json",
+    });
+}
+
+export { PlanRow, PlanTable, clientId, fetchLookup, subscribePage };
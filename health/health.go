@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/metrics"
+)
+
+const checkTimeout = 2 * time.Second
+
+type Status struct {
+	Status string            `json:"status"` // ok, ready or unavailable
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func writeStatus(w http.ResponseWriter, code int, s Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	encoder := json.NewEncoder(w)
+	encoder.Encode(s)
+}
+
+// liveness: the process is up and serving requests
+func Healthz() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, Status{Status: "ok"})
+	})
+}
+
+// readiness: the database answers and its schema is the version this build
+// expects, otherwise 503 so a load balancer holds traffic back
+func Readyz(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		s := Status{Status: "ready", Checks: make(map[string]string)}
+		if err := db.PingContext(ctx); err != nil {
+			s.Checks["database"] = err.Error()
+		} else {
+			s.Checks["database"] = "ok"
+		}
+
+		v, err := database.GetSchemaVersion(db)
+		switch {
+		case err != nil:
+			s.Checks["schema"] = err.Error()
+		case v != database.SchemaVersion:
+			s.Checks["schema"] = fmt.Sprintf("version %d, expecting %d", v, database.SchemaVersion)
+		default:
+			s.Checks["schema"] = "ok"
+		}
+
+		code := http.StatusOK
+		for _, c := range s.Checks {
+			if c != "ok" {
+				s.Status = "unavailable"
+				code = http.StatusServiceUnavailable
+			}
+		}
+		writeStatus(w, code, s)
+	})
+}
+
+// gauges read from the database on every scrape
+func RegisterMetrics(db *sql.DB) {
+	metrics.NewGaugeFunc("may_db_open_connections",
+		"Database connections by state.", []string{"state"},
+		func() ([]metrics.Sample, error) {
+			st := db.Stats()
+			return []metrics.Sample{
+				{Labels: []string{"in_use"}, Value: float64(st.InUse)},
+				{Labels: []string{"idle"}, Value: float64(st.Idle)},
+			}, nil
+		})
+
+	metrics.NewGaugeFunc("may_plan_rows",
+		"Plan rows (employee and plan pairs) on each plan page.", []string{"page_id"},
+		func() ([]metrics.Sample, error) {
+			counts, err := database.PlanRowCounts(context.Background(), db)
+			if err != nil {
+				return nil, err
+			}
+			samples := make([]metrics.Sample, len(counts))
+			for i, c := range counts {
+				samples[i] = metrics.Sample{
+					Labels: []string{strconv.FormatInt(c.PageId, 10)},
+					Value:  float64(c.Rows),
+				}
+			}
+			return samples, nil
+		})
+}
@@ -0,0 +1,125 @@
+// Package live fans plan page changes out to everyone who has the page open.
+// Handlers publish after a change is committed, the /api/events stream of
+// each open page subscribes (see plan.Events).
+package live
+
+import (
+	"sync"
+
+	"github.com/james-mcallister/may/database"
+)
+
+// event names, the event field of the server-sent event
+const (
+	RowAdded   = "row-added"
+	RowUpdated = "row-updated"
+	RowDeleted = "row-deleted"
+	TargetSet  = "target"
+)
+
+// the browser tab a change came from, the frontend sends it on every request
+const ClientHeader = "X-May-Client"
+
+// events buffered per subscriber. A subscriber that falls further behind is
+// dropped, its stream ends and the browser reconnects.
+const buffer = 64
+
+type Event struct {
+	Name string
+	Data any
+	// the X-May-Client id of the browser tab that made the change, its own
+	// stream skips the event
+	Origin string
+}
+
+// a plan row was added, updated or deleted (also by undo and redo)
+type Row struct {
+	EmpId   int64  `json:"emp_id,string"`
+	PlanId  int64  `json:"plan_id,string"`
+	Version int64  `json:"version,string"`
+	Actor   string `json:"actor"`
+}
+
+// the page targets changed, the cost is redacted per subscriber
+type Target struct {
+	PageId      int64                `json:"page_id,string"`
+	TargetCost  database.NullFloat64 `json:"target_cost"`
+	TargetHours float64              `json:"target_hours"`
+	Actor       string               `json:"actor"`
+}
+
+type Hub struct {
+	mu     sync.Mutex
+	pages  map[int64]map[chan Event]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{pages: make(map[int64]map[chan Event]struct{})}
+}
+
+// the page's events until cancel is called or the hub closes, the channel
+// is closed either way
+func (h *Hub) Subscribe(pageId int64) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	subs, ok := h.pages[pageId]
+	if !ok {
+		subs = make(map[chan Event]struct{})
+		h.pages[pageId] = subs
+	}
+	subs[ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(pageId, ch)
+	}
+}
+
+// sends the event to the page's subscribers without waiting on any of them
+func (h *Hub) Publish(pageId int64, e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.pages[pageId] {
+		select {
+		case ch <- e:
+		default:
+			h.remove(pageId, ch)
+		}
+	}
+}
+
+// ends every stream, called on shutdown so the server doesn't wait on them
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for pageId, subs := range h.pages {
+		for ch := range subs {
+			h.remove(pageId, ch)
+		}
+	}
+}
+
+// with h.mu held
+func (h *Hub) remove(pageId int64, ch chan Event) {
+	subs, ok := h.pages[pageId]
+	if !ok {
+		return
+	}
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(h.pages, pageId)
+	}
+}
@@ -0,0 +1,193 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	RequestIDHeader = "X-Request-Id"
+	maxErrorLength  = 512
+)
+
+// json (the default) or text log lines at level
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// filled in while the request is handled and logged once it is done
+type entry struct {
+	id    string
+	user  string
+	route string
+	err   string
+}
+
+type ctxKey struct{}
+
+func entryFrom(ctx context.Context) *entry {
+	e, _ := ctx.Value(ctxKey{}).(*entry)
+	return e
+}
+
+// the id of the request being handled, also sent in the X-Request-Id header
+func RequestID(ctx context.Context) string {
+	if e := entryFrom(ctx); e != nil {
+		return e.id
+	}
+	return ""
+}
+
+// records the signed in user for the access log
+func SetUser(ctx context.Context, username string) {
+	if e := entryFrom(ctx); e != nil {
+		e.user = username
+	}
+}
+
+// records the route pattern (ex: GET /api/v1/employees/{id}) for the access
+// log
+func SetRoute(ctx context.Context, pattern string) {
+	if e := entryFrom(ctx); e != nil {
+		e.route = pattern
+	}
+}
+
+// the route pattern the request matched, empty for unknown paths and static
+// files
+func Route(ctx context.Context) string {
+	if e := entryFrom(ctx); e != nil {
+		return e.route
+	}
+	return ""
+}
+
+// records the error for responses not sent with http.Error (ex: a login
+// page re-rendered with a message)
+func SetError(ctx context.Context, msg string) {
+	if e := entryFrom(ctx); e != nil {
+		e.err = msg
+	}
+}
+
+// ids from a proxy in front of the server are kept when they look sane
+func validID(id string) bool {
+	if len(id) == 0 || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// captures the status, size and the start of error responses
+type recorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+	errMsg strings.Builder
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	// http.Error responses are plain text messages
+	if rec.status >= 400 && rec.errMsg.Len() < maxErrorLength &&
+		strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		rec.errMsg.Write(b[:min(len(b), maxErrorLength-rec.errMsg.Len())])
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += n
+	return n, err
+}
+
+func (rec *recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// lets http.ResponseController reach the underlying writer
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// middleware writing one access log line per request. Every request gets an
+// id returned in the X-Request-Id header; error responses are logged with
+// their message so a report from a user can be matched to the log.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			e := &entry{id: r.Header.Get(RequestIDHeader)}
+			if !validID(e.id) {
+				e.id = newID()
+			}
+			w.Header().Set(RequestIDHeader, e.id)
+
+			rec := &recorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), ctxKey{}, e)))
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			switch {
+			case rec.status >= 500:
+				level = slog.LevelError
+			case rec.status >= 400:
+				level = slog.LevelWarn
+			}
+
+			attrs := []slog.Attr{
+				slog.String("request_id", e.id),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", e.route),
+				slog.Int("status", rec.status),
+				slog.Int("size", rec.size),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("user", e.user),
+			}
+			if rec.errMsg.Len() > 0 {
+				e.err = strings.TrimSpace(rec.errMsg.String())
+			}
+			if len(e.err) > 0 {
+				attrs = append(attrs, slog.String("error", e.err))
+			}
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
@@ -3,13 +3,25 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
+	"flag"
+	"fmt"
 	"html/template"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"time"
+	"strings"
+
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/backup"
+	"github.com/james-mcallister/may/cli"
+	"github.com/james-mcallister/may/config"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/logging"
+	"github.com/james-mcallister/may/metrics"
+	"github.com/james-mcallister/may/tlscert"
 )
 
 //go:embed frontend/dist/*
@@ -23,8 +35,35 @@ func add(a, b int) int {
 	return a + b
 }
 
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 func main() {
-	d := NewDomain()
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd := args[0]
+		args = args[1:]
+		if cmd != "serve" {
+			os.Exit(cli.Run(cmd, args))
+		}
+	}
+	serve(args)
+}
+
+func serve(args []string) {
+	cfg, err := config.Load("may serve", args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "may: %v\n", err)
+		os.Exit(2)
+	}
+
+	database.SetTimeouts(database.Timeouts{Query: cfg.QueryTimeout, Report: cfg.ReportTimeout})
+	d := NewDomain(cfg.DBPath)
 	d.Init()
 
 	funcMap := template.FuncMap{
@@ -38,39 +77,103 @@ func main() {
 	}
 	staticFiles := http.FileServerFS(fsys)
 
-	logger := log.New(os.Stdout, "may:", log.LstdFlags|log.Lshortfile)
-	middlewareLog := Logger(logger)
+	logger := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(logger)
+	logger.Info("effective config", "config", cfg)
+
+	// first run: create the admin login (admin_password or generated)
+	password, err := auth.Bootstrap(context.Background(), d.db, cfg.AdminPassword)
+	if err != nil {
+		panic(err)
+	}
+	if len(password) > 0 && len(cfg.AdminPassword) == 0 {
+		logger.Info("created user admin", "password", password)
+	}
+
+	if cfg.TLSSelfSigned {
+		created, err := tlscert.EnsureSelfSigned(cfg.TLSCert, cfg.TLSKey, cfg.TLSHosts)
+		if err != nil {
+			fatal(logger, "self-signed certificate error", err)
+		}
+		if created {
+			logger.Info("created self-signed certificate", "cert", cfg.TLSCert, "hosts", cfg.TLSHosts)
+		}
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/", middlewareLog(staticFiles))
-	initRoutes(mux, logger, d)
+	mux.Handle("/", staticFiles)
+	initRoutes(mux, d, cfg)
 
-	// TODO: configurable options
 	srv := &http.Server{
-		Addr:         "127.0.0.1:54321",
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
-		IdleTimeout:  60 * time.Second,
-		Handler:      mux,
+		Addr:         cfg.Listen,
+		WriteTimeout: cfg.WriteTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		Handler:      logging.AccessLog(logger)(metrics.Middleware(auth.CSRF(mux))),
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
 	}
+	// event streams never go idle, end them so shutdown doesn't wait
+	srv.RegisterOnShutdown(d.hub.Close)
 
 	go func() {
-		logger.Printf("Starting server on port 54321...")
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
-			logger.Fatalf("server fatal error: %v", err)
+		var err error
+		if cfg.TLS() {
+			logger.Info("starting server", "url", "https://"+cfg.Listen)
+			err = srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			logger.Info("starting server", "url", "http://"+cfg.Listen)
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			fatal(logger, "server fatal error", err)
 		}
 	}()
 
+	backupCtx, stopBackups := context.WithCancel(context.Background())
+	defer stopBackups()
+	if cfg.BackupInterval > 0 {
+		logger.Info("scheduled backups", "dir", cfg.BackupDir, "interval", cfg.BackupInterval, "keep", cfg.BackupKeep)
+		go backup.Schedule(backupCtx, d.db, cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep, logger)
+	}
+
+	var redirectSrv *http.Server
+	if len(cfg.TLSRedirect) > 0 {
+		redirect, err := tlscert.Redirect(cfg.Listen)
+		if err != nil {
+			fatal(logger, "redirect server error", err)
+		}
+		redirectSrv = &http.Server{
+			Addr:         cfg.TLSRedirect,
+			WriteTimeout: cfg.WriteTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+			Handler:      logging.AccessLog(logger)(redirect),
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		}
+		go func() {
+			logger.Info("redirecting to https", "listen", cfg.TLSRedirect)
+			if err := redirectSrv.ListenAndServe(); err != http.ErrServerClosed {
+				fatal(logger, "redirect server fatal error", err)
+			}
+		}()
+	}
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, os.Kill)
 	<-c
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	stopBackups()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
+	if redirectSrv != nil {
+		if err := redirectSrv.Shutdown(ctx); err != nil {
+			fatal(logger, "redirect server shutdown failure", err)
+		}
+	}
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Fatalf("server shutdown failure: %+v", err)
+		fatal(logger, "server shutdown failure", err)
 	}
-	logger.Printf("graceful shutdown complete")
+	logger.Info("graceful shutdown complete")
 	os.Exit(0)
 }
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/james-mcallister/may/logging"
+)
+
+var (
+	httpRequests = NewCounterVec("may_http_requests_total",
+		"HTTP requests by route pattern and status code.", "route", "code")
+	httpDuration = NewHistogramVec("may_http_request_duration_seconds",
+		"HTTP request latency by route pattern.", DefaultBuckets, "route")
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// middleware counting requests and their latency by the route pattern the
+// request matched. Must run inside logging.AccessLog, which tracks the route.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		route := logging.Route(r.Context())
+		if len(route) == 0 {
+			// static files and unknown paths, kept out of the labels so a
+			// scan can't create a series per path
+			route = "other"
+		}
+		httpRequests.Inc(route, strconv.Itoa(rec.status))
+		httpDuration.Observe(time.Since(start).Seconds(), route)
+	})
+}
@@ -0,0 +1,248 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// a small Prometheus text format (version 0.0.4) registry with counters,
+// histograms and gauges computed on scrape. Metrics register themselves in
+// the default registry when created.
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	mu       sync.Mutex
+	registry []metric
+)
+
+func register(m metric) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry = append(registry, m)
+}
+
+// request latency buckets in seconds
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// {a="1",b="2"} for the label names and values, extra is appended as is
+func (d desc) labelString(values []string, extra string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, d.labels[i]+`="`+labelEscaper.Replace(v)+`"`)
+	}
+	if len(extra) > 0 {
+		pairs = append(pairs, extra)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (d desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s: expecting %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	v      float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, labels}, values: make(map[string]*counterValue)}
+	register(c)
+	return c
+}
+
+func (c *CounterVec) Add(v float64, labels ...string) {
+	c.checkLabels(labels)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := labelKey(labels)
+	cv, ok := c.values[key]
+	if !ok {
+		cv = &counterValue{labels: labels}
+		c.values[key] = cv
+	}
+	cv.v += v
+}
+
+func (c *CounterVec) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w, "counter")
+	for _, k := range sortedKeys(c.values) {
+		cv := c.values[k]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelString(cv.labels, ""), formatFloat(cv.v))
+	}
+}
+
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{desc: desc{name, help, labels}, buckets: buckets, values: make(map[string]*histogramValue)}
+	register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labels ...string) {
+	h.checkLabels(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := labelKey(labels)
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{labels: labels, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.sum += v
+	hv.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w, "histogram")
+	for _, k := range sortedKeys(h.values) {
+		hv := h.values[k]
+		var cumulative uint64
+		for i, b := range h.buckets {
+			cumulative += hv.counts[i]
+			le := `le="` + formatFloat(b) + `"`
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(hv.labels, le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(hv.labels, `le="+Inf"`), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(hv.labels, ""), formatFloat(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(hv.labels, ""), hv.count)
+	}
+}
+
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// a gauge read when the metrics are scraped
+type GaugeFunc struct {
+	desc
+	fn func() ([]Sample, error)
+}
+
+func NewGaugeFunc(name, help string, labels []string, fn func() ([]Sample, error)) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, labels}, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	samples, err := g.fn()
+	if err != nil {
+		// the rest of the scrape is still useful
+		fmt.Fprintf(w, "# %s error: %s\n", g.name, strings.ReplaceAll(err.Error(), "\n", " "))
+		return
+	}
+
+	g.header(w, "gauge")
+	for _, s := range samples {
+		g.checkLabels(s.Labels)
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelString(s.Labels, ""), formatFloat(s.Value))
+	}
+}
+
+// the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		metrics := make([]metric, len(registry))
+		copy(metrics, registry)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		bw := bufio.NewWriter(w)
+		for _, m := range metrics {
+			m.write(bw)
+		}
+		bw.Flush()
+	})
+}
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+)
+
+var dbQueryDuration = NewHistogramVec("may_db_query_duration_seconds",
+	"SQL statement durations by operation (select, insert, update, delete or other).", DefaultBuckets, "op")
+
+// wraps a database/sql driver so every statement is timed. Queries are
+// timed until their rows are closed since sqlite steps through the result
+// while the rows are read.
+func WrapDriver(d driver.Driver) driver.Driver {
+	return wrappedDriver{d}
+}
+
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "other"
+	}
+	op := strings.ToLower(fields[0])
+	switch op {
+	case "select", "insert", "update", "delete":
+		return op
+	case "with":
+		return "select"
+	}
+	return "other"
+}
+
+func observe(query string, start time.Time) {
+	dbQueryDuration.Observe(time.Since(start).Seconds(), operation(query))
+}
+
+type wrappedDriver struct {
+	driver.Driver
+}
+
+func (d wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{c}, nil
+}
+
+type conn struct {
+	driver.Conn
+}
+
+// the wrapped driver connection, ex: for sqlite's backup api
+func (c *conn) Unwrap() driver.Conn {
+	return c.Conn
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var s driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = p.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: s, query: query}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer observe(query, time.Now())
+	return e.ExecContext(ctx, query, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	r, err := q.QueryContext(ctx, query, args)
+	if err != nil {
+		observe(query, start)
+		return nil, err
+	}
+	return &rows{Rows: r, query: query, start: start}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+type stmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer observe(s.query, time.Now())
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	values, err := namedToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var r driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		r, err = q.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			r, err = s.Stmt.Query(values)
+		}
+	}
+	if err != nil {
+		observe(s.query, start)
+		return nil, err
+	}
+	return &rows{Rows: r, query: s.query, start: start}, nil
+}
+
+func namedToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		if len(a.Name) > 0 {
+			return nil, errors.New("named parameters are not supported by the driver")
+		}
+		values[i] = a.Value
+	}
+	return values, nil
+}
+
+type rows struct {
+	driver.Rows
+	query  string
+	start  time.Time
+	closed bool
+}
+
+func (r *rows) Close() error {
+	if !r.closed {
+		r.closed = true
+		observe(r.query, r.start)
+	}
+	return r.Rows.Close()
+}
@@ -0,0 +1,264 @@
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/respond"
+)
+
+// documentation for a registered route, keyed by its mux pattern
+type Route struct {
+	Tag      string
+	Summary  string
+	Query    []Parameter
+	Form     []Parameter // application/x-www-form-urlencoded body
+	Body     any         // value whose type describes the json request body
+	Response any         // value whose type describes the json response body
+	HTML     bool        // responds with an html fragment
+	Stream   bool        // responds with server-sent events
+	Status   int         // success status code, defaults to 200
+	Headers  map[string]Header
+	Public   bool // no session required
+}
+
+// builds the document from the patterns registered on the muxes (ex:
+// "GET /api/v1/employees/{id}"). Routes without an entry in the route docs
+// are still listed so the document never misses an endpoint.
+func New(patterns []string) Document {
+	d := Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "may",
+			Description: "Staff management and EVMS labor planning api",
+			Version:     "v1",
+		},
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+
+	docs := routeDocs()
+	for _, p := range patterns {
+		method, path, ok := strings.Cut(p, " ")
+		if !ok {
+			continue
+		}
+
+		r, found := docs[p]
+		if !found {
+			r = Route{Summary: "undocumented route"}
+		}
+		if len(r.Tag) == 0 {
+			r.Tag = defaultTag(path)
+		}
+
+		item, ok := d.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			d.Paths[path] = item
+		}
+		item[strings.ToLower(method)] = d.operation(method, path, r)
+	}
+	return d
+}
+
+func (d *Document) operation(method, path string, r Route) *Operation {
+	op := &Operation{
+		Tags:        []string{r.Tag},
+		Summary:     r.Summary,
+		OperationId: operationId(method, path),
+		Responses:   make(map[string]Response),
+	}
+
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			name := strings.Trim(seg, "{}")
+			schema := &Schema{Type: "string"}
+			if name == "id" {
+				schema.Format = "int64"
+			}
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   schema,
+			})
+		}
+	}
+	op.Parameters = append(op.Parameters, r.Query...)
+	unsafe := method != http.MethodGet && method != http.MethodHead
+	if unsafe {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        "X-CSRF-Token",
+			In:          "header",
+			Description: "value of the may_csrf cookie (or the csrf_token form field)",
+			Required:    true,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+
+	if r.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: d.schemaOf(r.Body)}},
+		}
+	} else if len(r.Form) > 0 {
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for _, f := range r.Form {
+			s.Properties[f.Name] = f.Schema
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/x-www-form-urlencoded": {Schema: s}},
+		}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	res := Response{Description: http.StatusText(status), Headers: r.Headers}
+	switch {
+	case r.Response != nil:
+		res.Content = map[string]MediaType{"application/json": {Schema: d.schemaOf(r.Response)}}
+	case r.HTML:
+		res.Content = map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}}
+	case r.Stream:
+		res.Content = map[string]MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}}
+	}
+	op.Responses[strconv.Itoa(status)] = res
+
+	// errors written by the respond package, see respond.WantsJSON
+	failed := map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}}
+	if strings.HasPrefix(path, "/api/") {
+		failed = map[string]MediaType{"application/json": {Schema: d.schemaOf(respond.ErrorBody{})}}
+	}
+	if len(op.Parameters) > 0 || op.RequestBody != nil {
+		op.Responses["400"] = Response{Description: "Invalid request", Content: failed}
+	}
+	if strings.Contains(path, "{id}") {
+		op.Responses["404"] = Response{Description: "No such row", Content: failed}
+	}
+	if unsafe {
+		op.Responses["409"] = Response{Description: "Duplicate value or stale state", Content: failed}
+		op.Responses["422"] = Response{Description: "Referenced row missing or in use", Content: failed}
+	}
+
+	plain := map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	if !r.Public {
+		op.Responses["401"] = Response{Description: "Not signed in", Content: plain}
+		op.Responses["403"] = Response{Description: "Role not allowed", Content: plain}
+	}
+	if unsafe {
+		desc := "CSRF token missing or invalid"
+		if !r.Public {
+			desc = "Role not allowed or CSRF token missing or invalid"
+		}
+		op.Responses["403"] = Response{Description: desc, Content: plain}
+	}
+	op.Responses["500"] = Response{Description: "Server error", Content: failed}
+	return op
+}
+
+// first path segment after the /api, /api/v1 or /evms prefix
+func defaultTag(path string) string {
+	for _, prefix := range []string{"/api/v1/", "/api/", "/evms/"} {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			seg, _, _ := strings.Cut(rest, "/")
+			return seg
+		}
+	}
+	return "default"
+}
+
+// GET /api/v1/employees/{id} -> get_api_v1_employees_id
+func operationId(method, path string) string {
+	r := strings.NewReplacer("/", "_", "{", "", "}", "")
+	return strings.ToLower(method) + r.Replace(strings.TrimSuffix(path, "/"))
+}
+
+// query params accepted by an entity list endpoint
+func ListParams(fields database.ListFields) []Parameter {
+	var params []Parameter
+
+	names := make([]string, 0, len(fields.Filters))
+	for k := range fields.Filters {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		f := fields.Filters[n]
+		p := Parameter{Name: n, In: "query", Description: "filter on " + f.Column}
+		switch f.Type {
+		case database.FilterBool:
+			p.Schema = &Schema{Type: "boolean"}
+		case database.FilterId:
+			p.Schema = &Schema{Type: "string"}
+			p.Description += ` (id or "none" for no value)`
+		case database.FilterDateFrom:
+			p.Schema = &Schema{Type: "string", Format: "date"}
+			p.Description = "on or after the date (" + f.Column + ")"
+		case database.FilterDateTo:
+			p.Schema = &Schema{Type: "string", Format: "date"}
+			p.Description = "on or before the date (" + f.Column + ")"
+		case database.FilterContains:
+			p.Schema = &Schema{Type: "string"}
+			p.Description = "words that all appear in the name, any case"
+		default:
+			p.Schema = &Schema{Type: "string"}
+		}
+		params = append(params, p)
+	}
+
+	sorts := make([]string, 0, len(fields.Sorts))
+	for k := range fields.Sorts {
+		sorts = append(sorts, k)
+	}
+	sort.Strings(sorts)
+
+	return append(params,
+		Parameter{Name: "sort", In: "query", Description: "sort key, default " + fields.DefaultSort, Schema: &Schema{Type: "string", Enum: sorts}},
+		Parameter{Name: "order", In: "query", Schema: &Schema{Type: "string", Enum: []string{"asc", "desc"}}},
+		Parameter{Name: "limit", In: "query", Description: "max rows, 0 for all", Schema: &Schema{Type: "integer"}},
+		Parameter{Name: "offset", In: "query", Schema: &Schema{Type: "integer"}},
+	)
+}
+
+// patterns are read on the first request so routes registered after this
+// handler are still documented
+func Spec(patterns *[]string) http.Handler {
+	var once sync.Once
+	var doc Document
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { doc = New(*patterns) })
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		encoder.Encode(doc)
+	})
+}
+
+type ViewerData struct {
+	SpecUrl string
+}
+
+// html page that renders the document without any external assets
+func Viewer(t *template.Template, specUrl string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := ViewerData{SpecUrl: specUrl}
+		if err := t.ExecuteTemplate(w, "openapi-viewer.html", data); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+}
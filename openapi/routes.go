@@ -0,0 +1,190 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/james-mcallister/may/api"
+	"github.com/james-mcallister/may/backup"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/health"
+	"github.com/james-mcallister/may/plan"
+)
+
+func dateRange(required bool) []Parameter {
+	return []Parameter{
+		{Name: "start_date", In: "query", Required: required, Description: "YYYY-MM-DD", Schema: &Schema{Type: "string", Format: "date"}},
+		{Name: "end_date", In: "query", Required: required, Description: "YYYY-MM-DD", Schema: &Schema{Type: "string", Format: "date"}},
+	}
+}
+
+func queryId(name string) Parameter {
+	return Parameter{Name: name, In: "query", Required: true, Schema: &Schema{Type: "string", Format: "int64"}}
+}
+
+func queryParam(name string) Parameter {
+	return Parameter{Name: name, In: "query", Schema: &Schema{Type: "string"}}
+}
+
+func formParam(name string) Parameter {
+	return Parameter{Name: name, Schema: &Schema{Type: "string"}}
+}
+
+var rowETag = map[string]Header{
+	"ETag": {
+		Description: "plan row version, send it in If-Match to update the row",
+		Schema:      &Schema{Type: "string"},
+	},
+}
+
+var totalCount = map[string]Header{
+	"X-Total-Count": {
+		Description: "rows matching the filters before limit/offset",
+		Schema:      &Schema{Type: "integer"},
+	},
+}
+
+// the five /api/v1 routes for an entity. A nil fields list has no query params.
+func crud(docs map[string]Route, path, tag string, entity any, list any, fields *database.ListFields) {
+	r := Route{Tag: tag, Summary: "List " + tag, Response: list, Headers: totalCount}
+	if fields != nil {
+		r.Query = ListParams(*fields)
+	}
+	docs["GET /api/v1/"+path] = r
+	docs["POST /api/v1/"+path] = Route{Tag: tag, Summary: "Create a record", Body: entity, Response: api.Created{}, Status: http.StatusCreated}
+	docs["GET /api/v1/"+path+"/{id}"] = Route{Tag: tag, Summary: "Get a record", Response: entity}
+	docs["PUT /api/v1/"+path+"/{id}"] = Route{Tag: tag, Summary: "Replace a record", Body: entity, Response: entity}
+	docs["DELETE /api/v1/"+path+"/{id}"] = Route{Tag: tag, Summary: "Delete a record", Status: http.StatusNoContent}
+}
+
+// html form routes used by the entity pages
+func forms(docs map[string]Route, path, tag string) {
+	docs["GET /"+path+"/"] = Route{Tag: tag, Summary: "Entity table page", HTML: true}
+	docs["POST /"+path+"/"] = Route{Tag: tag, Summary: "Create from the entity form"}
+	docs["GET /"+path+"/{id}/"] = Route{Tag: tag, Summary: "Entity form", HTML: true}
+	docs["PUT /"+path+"/{id}/"] = Route{Tag: tag, Summary: "Update from the entity form"}
+	docs["DELETE /"+path+"/{id}/"] = Route{Tag: tag, Summary: "Delete from the entity form"}
+}
+
+func routeDocs() map[string]Route {
+	docs := make(map[string]Route)
+
+	empFields := database.EmployeeListFields()
+	projFields := database.ProjectListFields()
+	netFields := database.NetworkListFields()
+	matFields := database.MaterialListFields()
+
+	crud(docs, "employees", "employees", database.Employee{}, []database.Employee{}, &empFields)
+	crud(docs, "compensation", "compensation", database.Compensation{}, []database.Compensation{}, nil)
+	crud(docs, "ipts", "ipts", database.Ipt{}, []database.Ipt{}, nil)
+	crud(docs, "material", "material", database.Material{}, []database.Material{}, &matFields)
+	crud(docs, "networks", "networks", database.Network{}, []database.Network{}, &netFields)
+	crud(docs, "projects", "projects", database.Project{}, []database.Project{}, &projFields)
+	crud(docs, "calendars", "calendars", database.Calendar{}, []database.Calendar{}, nil)
+	crud(docs, "planpages", "planpages", database.PlanPage{}, []database.PlanPage{}, nil)
+
+	for _, p := range []string{"employees", "compensation", "ipts", "material", "networks", "projects", "planpages"} {
+		docs["DELETE /api/v1/"+p+"/{id}"] = Route{Tag: p, Summary: "Move a record to the recycle bin", Status: http.StatusNoContent}
+	}
+
+	for _, p := range []string{"employees", "compensation", "ipts", "material", "networks", "projects", "calendars", "planpages"} {
+		params := ListParams(database.LookupFields(p))
+		for i := range params {
+			if params[i].Name == "limit" {
+				params[i].Description = "max rows, 1 to 200, default 20"
+			}
+		}
+		docs["GET /api/v1/"+p+"/lookup"] = Route{Tag: p, Summary: "Dropdown options, ?q= searches the names", Query: params, Response: []database.Dropdown{}}
+	}
+
+	crud(docs, "users", "users", api.UserRequest{}, []database.User{}, nil)
+	docs["GET /api/v1/users/{id}"] = Route{Tag: "users", Summary: "Get a record", Response: database.User{}}
+	docs["PUT /api/v1/users/{id}"] = Route{Tag: "users", Summary: "Replace a record, password is optional", Body: api.UserRequest{}, Response: database.User{}}
+
+	auditFields := database.AuditListFields()
+	docs["GET /api/v1/audit"] = Route{Tag: "audit", Summary: "Change history, newest first and 100 at a time by default",
+		Response: []database.AuditEntry{}, Headers: totalCount, Query: ListParams(auditFields)}
+	docs["GET /audit/"] = Route{Tag: "audit", Summary: "Change history page", HTML: true,
+		Query: []Parameter{queryParam("table"), queryParam("row"), queryParam("actor"), queryParam("from"), queryParam("to")}}
+
+	docs["GET /api/v1/search"] = Route{Tag: "search", Summary: "Ranked full-text search of employees, projects, networks, material and plan pages, every word matches as a prefix. 501 when built without fts5",
+		Response: []database.SearchResult{}, Query: []Parameter{
+			{Name: "q", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+			{Name: "type", In: "query", Description: "repeatable, defaults to every type", Schema: &Schema{Type: "string", Enum: database.SearchTypes()}},
+			{Name: "limit", In: "query", Description: "1 to 100, default 10", Schema: &Schema{Type: "integer"}},
+		}}
+
+	recycleFields := database.RecycleListFields()
+	docs["GET /api/v1/recycle"] = Route{Tag: "recycle", Summary: "Deleted records, newest first", Response: []database.BinEntry{}, Headers: totalCount, Query: ListParams(recycleFields)}
+	docs["POST /api/v1/recycle/{id}/restore"] = Route{Tag: "recycle", Summary: "Restore a deleted record with its dependents, 409 when it no longer fits", Response: api.RecycleRestored{}}
+	docs["DELETE /api/v1/recycle/{id}"] = Route{Tag: "recycle", Summary: "Purge a deleted record for good", Status: http.StatusNoContent}
+	docs["DELETE /api/v1/recycle"] = Route{Tag: "recycle", Summary: "Empty the recycle bin", Status: http.StatusNoContent}
+	docs["GET /recycle/"] = Route{Tag: "recycle", Summary: "Recycle bin page", HTML: true, Query: []Parameter{queryParam("table")}}
+	docs["POST /recycle/{id}/restore"] = Route{Tag: "recycle", Summary: "Restore a deleted record with its dependents", Response: api.RecycleRestored{}}
+	docs["DELETE /recycle/{id}/"] = Route{Tag: "recycle", Summary: "Purge a deleted record for good", Status: http.StatusNoContent}
+	docs["DELETE /recycle/"] = Route{Tag: "recycle", Summary: "Empty the recycle bin", Status: http.StatusNoContent}
+
+	docs["GET /api/v1/backups"] = Route{Tag: "backups", Summary: "List backups, newest first", Response: []backup.Info{}, Headers: totalCount}
+	docs["POST /api/v1/backups"] = Route{Tag: "backups", Summary: "Take a backup of the running database", Response: backup.Info{}, Status: http.StatusCreated}
+	docs["GET /api/v1/backups/{name}"] = Route{Tag: "backups", Summary: "Download a backup file"}
+	docs["POST /api/v1/backups/{name}/restore"] = Route{Tag: "backups", Summary: "Replace the database with a backup, taking a backup of it first", Response: api.Restored{}}
+
+	docs["GET /login"] = Route{Tag: "auth", Summary: "Sign in page", HTML: true, Public: true}
+	docs["POST /login"] = Route{Tag: "auth", Summary: "Start a session, sets the may_session cookie", Status: http.StatusSeeOther, Public: true,
+		Form: []Parameter{formParam("username"), formParam("password"), formParam("next")}}
+	docs["POST /logout"] = Route{Tag: "auth", Summary: "End the session", Status: http.StatusNoContent, Public: true}
+	docs["GET /login/oidc"] = Route{Tag: "auth", Summary: "Redirect to the single sign-on provider", Status: http.StatusFound, Public: true,
+		Query: []Parameter{queryParam("next")}}
+	docs["GET /login/oidc/callback"] = Route{Tag: "auth", Summary: "Single sign-on callback, sets the may_session cookie", Status: http.StatusSeeOther, Public: true,
+		Query: []Parameter{queryParam("code"), queryParam("state")}}
+	docs["GET /healthz"] = Route{Tag: "ops", Summary: "Liveness check", Response: health.Status{}, Public: true}
+	docs["GET /readyz"] = Route{Tag: "ops", Summary: "Readiness check: database ping and schema version, 503 when not ready", Response: health.Status{}, Public: true}
+	docs["GET /metrics"] = Route{Tag: "ops", Summary: "Prometheus metrics in the text exposition format", Public: true}
+	docs["GET /api/me"] = Route{Tag: "auth", Summary: "Signed in user", Response: database.User{}}
+	docs["PUT /api/me/password"] = Route{Tag: "auth", Summary: "Change your password", Body: api.PasswordChange{}, Status: http.StatusNoContent}
+
+	for _, p := range []string{"employees", "compensation", "ipts", "material", "networks", "projects"} {
+		forms(docs, p, "forms")
+	}
+
+	docs["GET /home/"] = Route{Tag: "pages", Summary: "Home page", HTML: true}
+	docs["GET /plan/"] = Route{Tag: "pages", Summary: "Plan page selector", HTML: true}
+	docs["PUT /plan/"] = Route{Tag: "pages", Summary: "New plan page form", HTML: true}
+	docs["POST /plan/"] = Route{Tag: "pages", Summary: "Create or load a plan page", HTML: true,
+		Form: []Parameter{formParam("load_plan"), formParam("name"), formParam("description")}}
+
+	docs["POST /evms/plan"] = Route{Tag: "evms", Summary: "Create a plan table", HTML: true,
+		Form: []Parameter{formParam("name"), formParam("start_date"), formParam("end_date"), formParam("page_id")}}
+	docs["GET /evms/plan"] = Route{Tag: "evms", Summary: "New plan table form", HTML: true}
+	docs["GET /evms/cal"] = Route{Tag: "evms", Summary: "Fiscal calendar for a period of performance", HTML: true, Query: dateRange(true)}
+
+	rowId := []Parameter{queryId("emp_id"), queryId("plan_id")}
+	docs["GET /api/prodhours"] = Route{Tag: "plan", Summary: "Productive hours per day", Query: dateRange(true), Response: []float64{}}
+	docs["GET /api/prodhoursidx"] = Route{Tag: "plan", Summary: "Date to productive hours index", Query: dateRange(true), Response: map[string]int{}}
+	docs["GET /api/newrow"] = Route{Tag: "plan", Summary: "Employee selector for a new plan row", HTML: true}
+	docs["GET /api/planhours"] = Route{Tag: "plan", Summary: "Planned hours per day for a plan row", Query: append(rowId, dateRange(true)...), Response: []float64{}, Headers: rowETag}
+	docs["GET /api/planrow"] = Route{Tag: "plan", Summary: "Plan table rows", HTML: true, Query: append([]Parameter{
+		{Name: "emp_ids", In: "query", Required: true, Description: "comma separated employee ids", Schema: &Schema{Type: "string"}},
+		{Name: "plan_ids", In: "query", Required: true, Description: "comma separated plan ids", Schema: &Schema{Type: "string"}},
+	}, dateRange(true)...)}
+	docs["POST /api/planrow"] = Route{Tag: "plan", Summary: "Add an employee to a plan", Query: append(rowId, dateRange(true)...), Response: plan.RowId{}, Headers: rowETag}
+	docs["DELETE /api/planrow"] = Route{Tag: "plan", Summary: "Remove an employee from a plan", Query: rowId}
+	pageId := []Parameter{queryId("page_id")}
+	docs["GET /api/journal"] = Route{Tag: "plan", Summary: "Undo history of a plan page, newest first", Query: pageId, Response: []database.JournalEntry{}}
+	docs["POST /api/undo"] = Route{Tag: "plan", Summary: "Undo the latest plan row change on the page, 409 when there is none", Query: pageId, Response: database.JournalEntry{}}
+	docs["POST /api/redo"] = Route{Tag: "plan", Summary: "Redo the latest undone plan row change on the page, 409 when there is none", Query: pageId, Response: database.JournalEntry{}}
+	docs["GET /api/events"] = Route{Tag: "plan", Summary: "Server-sent events of the page's plan rows (row-added, row-updated, row-deleted with a live.Row) and targets (target with a live.Target). Changes sent with a matching X-May-Client header are skipped",
+		Query: append(pageId, queryParam("client")), Stream: true}
+	docs["PUT /api/planrow"] = Route{Tag: "plan", Summary: "Update planned hours, keyed by date. 409 with a plan.RowConflict body when the row changed since the If-Match version, 428 without it",
+		Query: append([]Parameter{{Name: "If-Match", In: "header", Required: true, Description: "ETag from /api/planhours", Schema: &Schema{Type: "string"}}}, rowId...),
+		Body:  map[string]float64{}, Headers: rowETag}
+
+	docs["GET /api/org"] = Route{Tag: "reports", Summary: "Org chart from reports_to", Response: database.OrgChart{}}
+	docs["GET /api/org/rollup"] = Route{Tag: "reports", Summary: "Plan hours, FTE and cost per manager", Response: []database.ManagerRollup{},
+		Query: append(dateRange(true), Parameter{Name: "manager_id", In: "query", Description: "defaults to every manager", Schema: &Schema{Type: "string", Format: "int64"}})}
+	docs["GET /api/ipt/rollup"] = Route{Tag: "reports", Summary: "Staffing and cost per IPT", Query: dateRange(true), Response: []database.IptRollup{}}
+	docs["GET /api/ipt/{id}/employees"] = Route{Tag: "reports", Summary: "Staffing and cost per employee in an IPT", Query: dateRange(true), Response: []database.EmployeeRollup{}}
+
+	docs["GET /api/openapi.json"] = Route{Tag: "docs", Summary: "This document", Response: map[string]any{}}
+	docs["GET /api/docs"] = Route{Tag: "docs", Summary: "API documentation viewer", HTML: true}
+	return docs
+}
@@ -0,0 +1,101 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/james-mcallister/may/database"
+)
+
+var (
+	nullInt64Type   = reflect.TypeOf(database.NullInt64{})
+	nullFloat64Type = reflect.TypeOf(database.NullFloat64{})
+)
+
+// builds a schema for the json encoding of v. Named struct types are added
+// to the components and referenced so recursive types (OrgNode) terminate.
+func (d *Document) schemaOf(v any) *Schema {
+	if v == nil {
+		return nil
+	}
+	return d.schemaFor(reflect.TypeOf(v), false)
+}
+
+func (d *Document) schemaFor(t reflect.Type, asString bool) *Schema {
+	if t == nullInt64Type {
+		return &Schema{Type: "string", Format: "int64", Nullable: true}
+	}
+	if t == nullFloat64Type {
+		return &Schema{Type: "number", Nullable: true, Description: "null when redacted for the user's role"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return d.schemaFor(t.Elem(), asString)
+	case reflect.Bool:
+		if asString {
+			return &Schema{Type: "string", Enum: []string{"true", "false"}}
+		}
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if asString {
+			return &Schema{Type: "string", Format: "int64"}
+		}
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		if asString {
+			return &Schema{Type: "string", Format: "decimal"}
+		}
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaFor(t.Elem(), false)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem(), false)}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return d.structSchema(t)
+		}
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// placeholder first so self references resolve to the ref
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.addFields(s, t)
+	return s
+}
+
+// follows encoding/json rules for field names, omitted fields and embedding
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct && f.Type != nullInt64Type && f.Type != nullFloat64Type {
+			d.addFields(s, f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = d.schemaFor(f.Type, strings.Contains(opts, "string"))
+	}
+}
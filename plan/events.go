@@ -0,0 +1,109 @@
+package plan
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/live"
+	"github.com/james-mcallister/may/respond"
+)
+
+// comment lines sent while the page is quiet so proxies keep the stream open
+const heartbeat = 25 * time.Second
+
+// server-sent events of the plan page's row and target changes. Changes
+// made from the tab given by ?client= are not sent back to it.
+func Events(hub *live.Hub) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		pageId, err := strconv.ParseInt(params.Get("page_id"), 10, 64)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+		client := params.Get("client")
+		showCost := auth.CanSeeCost(r.Context())
+
+		// the stream outlives the server write timeout
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		events, cancel := hub.Subscribe(pageId)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 3000\n\n")
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if len(client) > 0 && e.Origin == client {
+					continue
+				}
+				if t, ok := e.Data.(live.Target); ok && !showCost {
+					t.TargetCost.Valid = false
+					e.Data = t
+				}
+				data, err := json.Marshal(e.Data)
+				if err != nil {
+					slog.Error("event encode error", "event", e.Name, "err", err)
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Name, data)
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func actor(r *http.Request) string {
+	if u, ok := auth.UserFrom(r.Context()); ok {
+		return u.Username
+	}
+	return ""
+}
+
+// tells the plan row's page a row changed, rows of tables without a page
+// have no one to tell
+func publishRow(hub *live.Hub, db *sql.DB, r *http.Request, name string, empId, planId, version int64) {
+	pageId, ok, err := database.PlanPageOf(r.Context(), db, planId)
+	if err != nil {
+		slog.Error("plan row event error", "plan_id", planId, "err", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	hub.Publish(pageId, live.Event{
+		Name:   name,
+		Data:   live.Row{EmpId: empId, PlanId: planId, Version: version, Actor: actor(r)},
+		Origin: r.Header.Get(live.ClientHeader),
+	})
+}
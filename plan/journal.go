@@ -0,0 +1,85 @@
+package plan
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/live"
+	"github.com/james-mcallister/may/respond"
+)
+
+func writeEntry(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	encoder.Encode(v)
+}
+
+// the plan page's undo history, newest first
+func Journal(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageId, err := strconv.ParseInt(r.URL.Query().Get("page_id"), 10, 64)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		entries, err := database.PlanJournalEntries(r.Context(), db, pageId)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if entries == nil {
+			entries = []database.JournalEntry{}
+		}
+
+		writeEntry(w, entries)
+	})
+}
+
+// reverses the latest plan row change on the page, responds with the entry
+// so the page can reload that row
+func Undo(db *sql.DB, hub *live.Hub) http.Handler {
+	return replay(db, hub, database.UndoPlanChange)
+}
+
+// applies the latest undone change on the page again
+func Redo(db *sql.DB, hub *live.Hub) http.Handler {
+	return replay(db, hub, database.RedoPlanChange)
+}
+
+func replay(db *sql.DB, hub *live.Hub, fn func(context.Context, *sql.DB, int64) (database.JournalEntry, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageId, err := strconv.ParseInt(r.URL.Query().Get("page_id"), 10, 64)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		entry, err := fn(r.Context(), db, pageId)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		publishRow(hub, db, r, entryEvent(entry), entry.EmpId, entry.PlanId, entry.Version)
+
+		writeEntry(w, entry)
+	})
+}
+
+// what the replayed entry did to the row, ex: undoing a delete adds it back
+func entryEvent(e database.JournalEntry) string {
+	switch {
+	case e.Op == database.JournalUpdate:
+		return live.RowUpdated
+	case e.RowExists:
+		return live.RowAdded
+	}
+	return live.RowDeleted
+}
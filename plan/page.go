@@ -3,6 +3,8 @@ package plan
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"io"
 	"net/http"
@@ -10,7 +12,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/james-mcallister/may/auth"
 	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/live"
+	"github.com/james-mcallister/may/respond"
 )
 
 type LoadPlan struct {
@@ -22,14 +27,14 @@ func Select(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 
 		data := LoadPlan{}
-		data.Plans, err = database.NewDropdown(db, database.PlanPageDropdownQuery())
+		data.Plans, err = database.NewDropdown(r.Context(), db, database.PlanPageDropdownQuery())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err := t.ExecuteTemplate(w, "form-plan-select.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
@@ -44,23 +49,28 @@ func New(t *template.Template, db *sql.DB) http.Handler {
 		var err error
 
 		data := NewPlanData{}
-		data.Emps, err = database.NewDropdown(db, database.EmployeeDropdownQuery())
+		data.Emps, err = database.NewDropdown(r.Context(), db, database.EmployeeDropdownQuery())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 		if err := t.ExecuteTemplate(w, "form-plan-new.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
 }
 
+type PageData struct {
+	database.PlanPage
+	ShowCost bool
+}
+
 func Page(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
@@ -68,29 +78,40 @@ func Page(t *template.Template, db *sql.DB) http.Handler {
 		if r.PostForm.Has("load_plan") && len(r.FormValue("load_plan")) > 0 {
 			planPageId, err := strconv.ParseInt(r.FormValue("load_plan"), 10, 64)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.BadRequest(w, r, err)
 				return
 			}
-			data, err = database.GetPlanPage(db, planPageId)
+			data, err = database.GetPlanPage(r.Context(), db, planPageId)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
 		} else {
+			// loading a page is read only, creating one needs a planner
+			if !auth.HasRole(r.Context(), auth.Planner) {
+				respond.Message(w, r, http.StatusForbidden, "forbidden: creating a plan page requires the planner role")
+				return
+			}
 			data = database.PlanPage{
 				Title:       r.FormValue("name"),
 				Description: r.FormValue("description"),
+				TargetCost:  database.NewNullFloat64(0),
 			}
 
-			_, err = database.InsertPlanPage(db, data)
+			data.Id, err = database.InsertPlanPage(r.Context(), db, data)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				respond.Error(w, r, err)
 				return
 			}
 		}
 
-		if err = t.ExecuteTemplate(w, "plan-page.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		page := PageData{PlanPage: data, ShowCost: auth.CanSeeCost(r.Context())}
+		if !page.ShowCost {
+			page.Redact()
+		}
+
+		if err = t.ExecuteTemplate(w, "plan-page.html", page); err != nil {
+			respond.Error(w, r, err)
 			return
 		}
 	})
@@ -109,7 +130,7 @@ func PlanRow(t *template.Template, db *sql.DB) http.Handler {
 		for i, val := range empIdsStr {
 			empIds[i], err = strconv.ParseInt(val, 10, 64)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				respond.BadRequest(w, r, err)
 				return
 			}
 		}
@@ -120,7 +141,7 @@ func PlanRow(t *template.Template, db *sql.DB) http.Handler {
 		for i, val := range planIdsStr {
 			planIds[i], err = strconv.ParseInt(val, 10, 64)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				respond.BadRequest(w, r, err)
 				return
 			}
 		}
@@ -129,18 +150,21 @@ func PlanRow(t *template.Template, db *sql.DB) http.Handler {
 		endDate := params.Get("end_date")
 
 		if !ValidateDateFormat(startDate) || !ValidateDateFormat(endDate) {
-			http.Error(w, "Invalid query params: start/end date", http.StatusBadRequest)
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
 			return
 		}
 
-		planRow, err := database.GetPlanRows(db, empIds, planIds, startDate, endDate)
+		planRow, err := database.GetPlanRows(r.Context(), db, empIds, planIds, startDate, endDate)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
+		if !auth.CanSeeCost(r.Context()) {
+			database.RedactAll(planRow)
+		}
 
 		if err := t.ExecuteTemplate(w, "plan-emp-row.html", planRow); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
@@ -153,28 +177,35 @@ func PlanHours(db *sql.DB) http.Handler {
 		params := r.URL.Query()
 		empId, err := strconv.ParseInt(params.Get("emp_id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 		planId, err := strconv.ParseInt(params.Get("plan_id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 		startDate := params.Get("start_date")
 		endDate := params.Get("end_date")
 
 		if !ValidateDateFormat(startDate) || !ValidateDateFormat(endDate) {
-			http.Error(w, "Invalid query params: start/end date", http.StatusBadRequest)
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
 			return
 		}
 
-		planHours, err := database.GetPlanHours(db, empId, planId, startDate, endDate)
+		planHours, err := database.GetPlanHours(r.Context(), db, empId, planId, startDate, endDate)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
+			return
+		}
+		version, err := database.GetPlanRowVersion(r.Context(), db, empId, planId)
+		if err != nil {
+			respond.Error(w, r, err)
 			return
 		}
 
+		// sent back in If-Match to update the row
+		w.Header().Set("ETag", rowETag(version))
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 
@@ -192,13 +223,13 @@ func ProdHours(db *sql.DB) http.Handler {
 		endDate := params.Get("end_date")
 
 		if !ValidateDateFormat(startDate) || !ValidateDateFormat(endDate) {
-			http.Error(w, "Invalid query params: start/end date", http.StatusBadRequest)
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
 			return
 		}
 
-		prodHours, err := database.GetProdHours(db, startDate, endDate)
+		prodHours, err := database.GetProdHours(r.Context(), db, startDate, endDate)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -219,13 +250,13 @@ func ProdHoursIdx(db *sql.DB) http.Handler {
 		endDate := params.Get("end_date")
 
 		if !ValidateDateFormat(startDate) || !ValidateDateFormat(endDate) {
-			http.Error(w, "Invalid query params: start/end date", http.StatusBadRequest)
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
 			return
 		}
 
-		prodHours, err := database.GetProdHoursIndex(db, startDate, endDate)
+		prodHours, err := database.GetProdHoursIndex(r.Context(), db, startDate, endDate)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -237,6 +268,29 @@ func ProdHoursIdx(db *sql.DB) http.Handler {
 	})
 }
 
+// 409 response body for an update based on a stale version
+type RowConflict struct {
+	Error string `json:"error"`
+	*database.PlanRowConflict
+}
+
+func rowETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// the plan row version from the If-Match header
+func parseIfMatch(r *http.Request) (int64, error) {
+	tag := strings.TrimPrefix(strings.TrimSpace(r.Header.Get("If-Match")), "W/")
+	if len(tag) == 0 {
+		return 0, fmt.Errorf("If-Match header required: the ETag from /api/planhours")
+	}
+	version, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid If-Match header %q: expecting the ETag from /api/planhours", tag)
+	}
+	return version, nil
+}
+
 func ValidateDateFormat(dateString string) bool {
 	format := "2006-01-02"
 
@@ -245,59 +299,67 @@ func ValidateDateFormat(dateString string) bool {
 }
 
 type PlanTable struct {
-	Plan    database.Plan        `json:"plan"`
-	Months  []database.PlanMonth `json:"months"`
-	EmpRows []database.TableRow  `json:"emp_rows"`
+	Plan     database.Plan        `json:"plan"`
+	Months   []database.PlanMonth `json:"months"`
+	EmpRows  []database.TableRow  `json:"emp_rows"`
+	ShowCost bool                 `json:"-"`
 }
 
 func NewPlanTable(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
 		if !ValidateDateFormat(r.FormValue("start_date")) || !ValidateDateFormat(r.FormValue("end_date")) {
-			http.Error(w, "Invalid query params: start/end date", http.StatusBadRequest)
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
 			return
 		}
 
 		if r.PostForm.Has("name") && len(r.FormValue("name")) <= 0 {
-			http.Error(w, "Invalid query params: name", http.StatusBadRequest)
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: name")
 			return
 		}
 
-		// TODO: need to add param for PlanPage id (int64)
-		// this might actually not be needed until save is clicked.
-		// then save will associate all the plans on the page with the page
-		// this gives flexibility for which tables are used on which page
 		tab := database.Plan{
 			Name:      r.FormValue("name"),
 			StartDate: r.FormValue("start_date"),
 			EndDate:   r.FormValue("end_date"),
 		}
+		// the page the table is added on, its row changes are journaled
+		// there for undo
+		if len(r.FormValue("page_id")) > 0 {
+			pageId, err := strconv.ParseInt(r.FormValue("page_id"), 10, 64)
+			if err != nil {
+				respond.Message(w, r, http.StatusBadRequest, "Invalid query params: page_id")
+				return
+			}
+			tab.Page = database.NewNullInt64(pageId)
+		}
 
-		tId, err := database.InsertPlan(db, tab)
+		tId, err := database.InsertPlan(r.Context(), db, tab)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 		tab.Id = tId
 
 		data := PlanTable{
-			Plan: tab,
+			Plan:     tab,
+			ShowCost: auth.CanSeeCost(r.Context()),
 		}
 
-		m, err := database.GetPlanMonths(db, tab.StartDate, tab.EndDate)
+		m, err := database.GetPlanMonths(r.Context(), db, tab.StartDate, tab.EndDate)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 		data.Months = m
 
 		if err = t.ExecuteTemplate(w, "plan-table.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
@@ -306,7 +368,7 @@ func NewPlanTable(t *template.Template, db *sql.DB) http.Handler {
 func NewPlanForm(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if err := t.ExecuteTemplate(w, "form-plan-new-table.html", nil); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
@@ -317,40 +379,43 @@ type RowId struct {
 	PlanId int64 `json:"plan_id,string"`
 }
 
-func NewPlanRow(db *sql.DB) http.Handler {
+func NewPlanRow(db *sql.DB, hub *live.Hub) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
 		params := r.URL.Query()
 		empId, err := strconv.ParseInt(params.Get("emp_id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 		planId, err := strconv.ParseInt(params.Get("plan_id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 		startDate := params.Get("start_date")
 		endDate := params.Get("end_date")
 
 		if !ValidateDateFormat(startDate) || !ValidateDateFormat(endDate) {
-			http.Error(w, "Invalid query params: start/end date", http.StatusBadRequest)
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
 			return
 		}
 
-		_, err = database.InitPlanRow(db, empId, planId, startDate, endDate)
+		version, err := database.InitPlanRow(r.Context(), db, empId, planId, startDate, endDate)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
+		publishRow(hub, db, r, live.RowAdded, empId, planId, version)
+
 		rowId := RowId{
 			EmpId:  empId,
 			PlanId: planId,
 		}
 
+		w.Header().Set("ETag", rowETag(version))
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 
@@ -363,71 +428,83 @@ func NewPlanRowForm(t *template.Template, db *sql.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
-		data, err := database.NewDropdown(db, database.EmployeeDropdownQuery())
+		// the employees are looked up as the planner types, the IPTs
+		// narrow the lookup
+		data, err := database.NewDropdown(r.Context(), db, database.IptDropdownQuery())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
 		if err = t.ExecuteTemplate(w, "emp-row-select.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
 }
 
-func DeleteRow(db *sql.DB) http.Handler {
+func DeleteRow(db *sql.DB, hub *live.Hub) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
 		params := r.URL.Query()
 		empId, err := strconv.ParseInt(params.Get("emp_id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 		planId, err := strconv.ParseInt(params.Get("plan_id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
-		_, err = database.DeletePlanRow(db, empId, planId)
+		version, err := database.DeletePlanRow(r.Context(), db, empId, planId)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
+		if version > 0 {
+			publishRow(hub, db, r, live.RowDeleted, empId, planId, version)
+		}
 
 		w.WriteHeader(http.StatusOK)
 	})
 }
 
-func UpdateRow(db *sql.DB) http.Handler {
+func UpdateRow(db *sql.DB, hub *live.Hub) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var err error
 
 		params := r.URL.Query()
 		empId, err := strconv.ParseInt(params.Get("emp_id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 		planId, err := strconv.ParseInt(params.Get("plan_id"), 10, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		// the version the hours were read at, from the planhours ETag
+		version, err := parseIfMatch(r)
+		if err != nil {
+			respond.Message(w, r, http.StatusPreconditionRequired, err.Error())
 			return
 		}
 
 		body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20)) // 10MB limit
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 		defer r.Body.Close()
 
 		var data map[string]float64
 		if err := json.Unmarshal(body, &data); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.BadRequest(w, r, err)
 			return
 		}
 
@@ -442,11 +519,25 @@ func UpdateRow(db *sql.DB) http.Handler {
 			i++
 		}
 
-		if err = database.UpdatePlanRow(db, empId, planId, rows); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		version, err = database.UpdatePlanRow(r.Context(), db, empId, planId, version, rows)
+		var conflict *database.PlanRowConflict
+		if errors.As(err, &conflict) {
+			w.Header().Set("ETag", rowETag(conflict.Version))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+
+			encoder := json.NewEncoder(w)
+			encoder.Encode(RowConflict{Error: conflict.Error(), PlanRowConflict: conflict})
 			return
 		}
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		publishRow(hub, db, r, live.RowUpdated, empId, planId, version)
 
+		w.Header().Set("ETag", rowETag(version))
 		w.WriteHeader(http.StatusOK)
 	})
 }
@@ -461,20 +552,20 @@ func Calendar(t *template.Template, db *sql.DB) http.Handler {
 		fiscalPeriod := params.Get("fiscal_period")
 
 		if !ValidateDateFormat(popStart) || !ValidateDateFormat(popEnd) {
-			http.Error(w, "Invalid query params: start/end date", http.StatusBadRequest)
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
 			return
 		}
 
 		if len(fiscalPeriod) != 6 {
-			http.Error(w, "Invalid query params: start/end date", http.StatusBadRequest)
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
 			return
 		}
 
 		data := database.NewCal()
 		data.Period = MonthDay(fiscalPeriod)
-		data.Days, err = database.GetCalData(db, popStart, popEnd, fiscalPeriod)
+		data.Days, err = database.GetCalData(r.Context(), db, popStart, popEnd, fiscalPeriod)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 
@@ -485,7 +576,7 @@ func Calendar(t *template.Template, db *sql.DB) http.Handler {
 		data.Inner = make([]int, numDays)
 
 		if err = t.ExecuteTemplate(w, "plan-calendar.html", data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			respond.Error(w, r, err)
 			return
 		}
 	})
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/plan"
+	"github.com/james-mcallister/may/respond"
+)
+
+func IptRollup(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		startDate := params.Get("start_date")
+		endDate := params.Get("end_date")
+
+		if !plan.ValidateDateFormat(startDate) || !plan.ValidateDateFormat(endDate) {
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
+			return
+		}
+
+		rollups, err := database.GetIptRollups(r.Context(), db, startDate, endDate)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		if !auth.CanSeeCost(r.Context()) {
+			database.RedactAll(rollups)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		encoder.Encode(rollups)
+	})
+}
+
+// drill down from an IPT rollup to the employees assigned to the IPT
+func IptEmployees(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			respond.BadRequest(w, r, err)
+			return
+		}
+
+		params := r.URL.Query()
+		startDate := params.Get("start_date")
+		endDate := params.Get("end_date")
+
+		if !plan.ValidateDateFormat(startDate) || !plan.ValidateDateFormat(endDate) {
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
+			return
+		}
+
+		emps, err := database.GetIptEmployeeRollups(r.Context(), db, id, startDate, endDate)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		if !auth.CanSeeCost(r.Context()) {
+			database.RedactAll(emps)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		encoder.Encode(emps)
+	})
+}
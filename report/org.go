@@ -0,0 +1,81 @@
+package report
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/james-mcallister/may/auth"
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/plan"
+	"github.com/james-mcallister/may/respond"
+)
+
+func OrgChart(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chart, err := database.GetOrgChart(r.Context(), db)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		encoder.Encode(chart)
+	})
+}
+
+// rolls up plan hours, FTE and cost for a manager's organisation. If the
+// manager_id param is empty every employee with direct reports is included.
+func OrgRollup(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		params := r.URL.Query()
+		startDate := params.Get("start_date")
+		endDate := params.Get("end_date")
+
+		if !plan.ValidateDateFormat(startDate) || !plan.ValidateDateFormat(endDate) {
+			respond.Message(w, r, http.StatusBadRequest, "Invalid query params: start/end date")
+			return
+		}
+
+		var managerIds []int64
+		if params.Has("manager_id") {
+			id, err := strconv.ParseInt(params.Get("manager_id"), 10, 64)
+			if err != nil {
+				respond.BadRequest(w, r, err)
+				return
+			}
+			managerIds = append(managerIds, id)
+		} else {
+			managerIds, err = database.AllManagers(r.Context(), db)
+			if err != nil {
+				respond.Error(w, r, err)
+				return
+			}
+		}
+
+		rollups := make([]database.ManagerRollup, len(managerIds))
+		for i, id := range managerIds {
+			rollups[i], err = database.GetManagerRollup(r.Context(), db, id, startDate, endDate)
+			if err != nil {
+				respond.Error(w, r, err)
+				return
+			}
+		}
+
+		if !auth.CanSeeCost(r.Context()) {
+			database.RedactAll(rollups)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		encoder := json.NewEncoder(w)
+		encoder.Encode(rollups)
+	})
+}
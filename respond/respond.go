@@ -0,0 +1,92 @@
+// Package respond writes error responses. The status comes from the kind of
+// database error (see database.ErrNotFound and the others), the body is json
+// for api clients and an html fragment for the pages, which show it in the
+// notification area.
+package respond
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/james-mcallister/may/database"
+	"github.com/james-mcallister/may/logging"
+)
+
+// the json error body
+type ErrorBody struct {
+	Error  string               `json:"error"`
+	Status int                  `json:"status"`
+	Fields database.FieldErrors `json:"fields,omitempty"` // what is wrong with each invalid field
+}
+
+// http status for an error, 500 for errors without a kind
+func Status(err error) int {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrConstraint):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, database.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, database.ErrForbidden):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	var fields database.FieldErrors
+	errors.As(err, &fields)
+	write(w, r, ErrorBody{Error: err.Error(), Status: Status(err), Fields: fields})
+}
+
+// a request value that doesn't parse, ex: a path id or a form number
+func BadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	Error(w, r, database.Invalid(err))
+}
+
+// an error response with the status given
+func Message(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	write(w, r, ErrorBody{Error: msg, Status: status})
+}
+
+func write(w http.ResponseWriter, r *http.Request, body ErrorBody) {
+	// the access log only reads plain text bodies
+	logging.SetError(r.Context(), body.Error)
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	if WantsJSON(r) {
+		h.Set("Content-Type", "application/json")
+		w.WriteHeader(body.Status)
+		json.NewEncoder(w).Encode(body)
+		return
+	}
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(body.Status)
+	fmt.Fprintf(w, "<span class=\"may-error\" data-status=\"%d\">%s</span>\n", body.Status, html.EscapeString(body.Error))
+}
+
+// json when asked for in Accept, html when that is asked for instead and
+// otherwise json for the /api routes
+func WantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, "application/json") {
+		return true
+	}
+	if strings.Contains(accept, "text/html") {
+		return false
+	}
+	// the path before any prefix was stripped off by a mux
+	return strings.HasPrefix(r.RequestURI, "/api/")
+}